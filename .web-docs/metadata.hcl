@@ -12,9 +12,54 @@ integration {
     name = "Proxmox Clone"
     slug = "clone"
   }
+  component {
+    type = "builder"
+    name = "Proxmox Import"
+    slug = "import"
+  }
   component {
     type = "builder"
     name = "Proxmox ISO"
     slug = "iso"
   }
+  component {
+    type = "builder"
+    name = "Proxmox LXC"
+    slug = "lxc"
+  }
+  component {
+    type = "builder"
+    name = "Proxmox OVA"
+    slug = "ova"
+  }
+  component {
+    type = "builder"
+    name = "Proxmox Restore"
+    slug = "restore"
+  }
+  component {
+    type = "data-source"
+    name = "Proxmox Node"
+    slug = "node"
+  }
+  component {
+    type = "data-source"
+    name = "Proxmox Template"
+    slug = "template"
+  }
+  component {
+    type = "post-processor"
+    name = "Proxmox Distribute"
+    slug = "distribute"
+  }
+  component {
+    type = "post-processor"
+    name = "Proxmox Import"
+    slug = "import"
+  }
+  component {
+    type = "post-processor"
+    name = "Proxmox vzdump"
+    slug = "vzdump"
+  }
 }
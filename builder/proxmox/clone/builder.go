@@ -35,7 +35,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("clone-config", &b.config)
 
 	preSteps := []multistep.Step{
-		&StepSshKeyPair{
+		&proxmox.StepSshKeyPair{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
 		},
@@ -44,13 +44,21 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	postSteps := []multistep.Step{}
 
 	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &cloneVMCreator{})
+	// The source VM is only known by name when clone_vm is set, any VM will do then
+	clonePath := "/vms"
+	if b.config.CloneVMID != 0 {
+		clonePath = fmt.Sprintf("/vms/%d", b.config.CloneVMID)
+	}
+	sb.RequiredPrivileges = []proxmox.PrivilegeRequirement{
+		{Feature: "clone_vm", Paths: []string{clonePath}, Privileges: []string{"VM.Clone", "VM.Audit"}},
+	}
 	return sb.Run(ctx, ui, hook, state)
 }
 
 type cloneVMCreator struct{}
 
-func (*cloneVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
-	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
+func (*cloneVMCreator) Create(ctx context.Context, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmox.Client)
 	c := state.Get("clone-config").(*Config)
 	comm := state.Get("config").(*proxmox.Config).Comm
 
@@ -95,13 +103,42 @@ func (*cloneVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQ
 		}
 	}
 
-	err := config.CloneVm(sourceVmr, vmRef, client)
+	state.Put("clone_source_vm_id", sourceVmr.VmId())
+
+	err := cloneVM(ctx, state, sourceVmr, vmRef, config)
 	if err != nil {
 		return err
 	}
-	_, err = config.Update(false, vmRef, client)
+	_, err = config.Update(false, vmRef, client.Client)
 	if err != nil {
-		return err
+		return proxmox.ClassifyError(err, "")
 	}
 	return nil
 }
+
+// cloneVM clones the source VM like ConfigQemu.CloneVm does, following the
+// progress of the clone task.
+func cloneVM(ctx context.Context, state multistep.StateBag, sourceVmr *proxmoxapi.VmRef, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(proxmox.TaskClient)
+	c := state.Get("clone-config").(*Config)
+
+	vmRef.SetVmType("qemu")
+	params := map[string]interface{}{
+		"newid":  vmRef.VmId(),
+		"target": vmRef.Node(),
+		"name":   config.Name,
+		"full":   *config.FullClone,
+	}
+	if vmRef.Pool() != "" {
+		params["pool"] = vmRef.Pool()
+	}
+	if storage, ok := config.QemuDisks[0]["storage"].(string); ok && storage != "" && *config.FullClone == 1 {
+		params["storage"] = storage
+	}
+
+	ui.Say(fmt.Sprintf("Cloning VM %d to %d", sourceVmr.VmId(), vmRef.VmId()))
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
+	_, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/qemu/%d/clone", sourceVmr.Node(), sourceVmr.VmId()))
+	return err
+}
@@ -11,121 +11,152 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string                       `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string                       `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion         *string                       `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug               *bool                         `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool                         `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string                       `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string             `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string                      `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	HTTPDir                   *string                       `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
-	HTTPContent               map[string]string             `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
-	HTTPPortMin               *int                          `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
-	HTTPPortMax               *int                          `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
-	HTTPAddress               *string                       `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
-	HTTPInterface             *string                       `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
-	BootGroupInterval         *string                       `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait                  *string                       `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand               []string                      `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	BootKeyInterval           *string                       `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
-	Type                      *string                       `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string                       `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string                       `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int                          `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string                       `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string                       `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string                       `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string                       `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string                       `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int                          `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string                      `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool                         `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string                      `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string                       `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string                       `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool                         `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string                       `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string                       `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool                         `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool                         `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int                          `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string                       `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int                          `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool                         `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string                       `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string                       `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool                         `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string                       `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string                       `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string                       `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string                       `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int                          `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string                       `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string                       `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string                       `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string                       `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string                      `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string                      `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte                        `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte                        `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string                       `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string                       `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string                       `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool                         `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int                          `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string                       `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool                         `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool                         `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool                         `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	ProxmoxURLRaw             *string                       `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
-	SkipCertValidation        *bool                         `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
-	Username                  *string                       `mapstructure:"username" cty:"username" hcl:"username"`
-	Password                  *string                       `mapstructure:"password" cty:"password" hcl:"password"`
-	Token                     *string                       `mapstructure:"token" cty:"token" hcl:"token"`
-	Node                      *string                       `mapstructure:"node" cty:"node" hcl:"node"`
-	Pool                      *string                       `mapstructure:"pool" cty:"pool" hcl:"pool"`
-	TaskTimeout               *string                       `mapstructure:"task_timeout" cty:"task_timeout" hcl:"task_timeout"`
-	VMName                    *string                       `mapstructure:"vm_name" cty:"vm_name" hcl:"vm_name"`
-	VMID                      *int                          `mapstructure:"vm_id" cty:"vm_id" hcl:"vm_id"`
-	Tags                      *string                       `mapstructure:"tags" cty:"tags" hcl:"tags"`
-	Boot                      *string                       `mapstructure:"boot" cty:"boot" hcl:"boot"`
-	Memory                    *int                          `mapstructure:"memory" cty:"memory" hcl:"memory"`
-	BalloonMinimum            *int                          `mapstructure:"ballooning_minimum" cty:"ballooning_minimum" hcl:"ballooning_minimum"`
-	Cores                     *int                          `mapstructure:"cores" cty:"cores" hcl:"cores"`
-	CPUType                   *string                       `mapstructure:"cpu_type" cty:"cpu_type" hcl:"cpu_type"`
-	Sockets                   *int                          `mapstructure:"sockets" cty:"sockets" hcl:"sockets"`
-	Numa                      *bool                         `mapstructure:"numa" cty:"numa" hcl:"numa"`
-	OS                        *string                       `mapstructure:"os" cty:"os" hcl:"os"`
-	BIOS                      *string                       `mapstructure:"bios" cty:"bios" hcl:"bios"`
-	EFIConfig                 *proxmox.FlatefiConfig        `mapstructure:"efi_config" cty:"efi_config" hcl:"efi_config"`
-	EFIDisk                   *string                       `mapstructure:"efidisk" cty:"efidisk" hcl:"efidisk"`
-	Machine                   *string                       `mapstructure:"machine" cty:"machine" hcl:"machine"`
-	Rng0                      *proxmox.Flatrng0Config       `mapstructure:"rng0" cty:"rng0" hcl:"rng0"`
-	TPMConfig                 *proxmox.FlattpmConfig        `mapstructure:"tpm_config" cty:"tpm_config" hcl:"tpm_config"`
-	VGA                       *proxmox.FlatvgaConfig        `mapstructure:"vga" cty:"vga" hcl:"vga"`
-	NICs                      []proxmox.FlatNICConfig       `mapstructure:"network_adapters" cty:"network_adapters" hcl:"network_adapters"`
-	Disks                     []proxmox.FlatdiskConfig      `mapstructure:"disks" cty:"disks" hcl:"disks"`
-	PCIDevices                []proxmox.FlatpciDeviceConfig `mapstructure:"pci_devices" cty:"pci_devices" hcl:"pci_devices"`
-	Serials                   []string                      `mapstructure:"serials" cty:"serials" hcl:"serials"`
-	Agent                     *bool                         `mapstructure:"qemu_agent" cty:"qemu_agent" hcl:"qemu_agent"`
-	SCSIController            *string                       `mapstructure:"scsi_controller" cty:"scsi_controller" hcl:"scsi_controller"`
-	Onboot                    *bool                         `mapstructure:"onboot" cty:"onboot" hcl:"onboot"`
-	DisableKVM                *bool                         `mapstructure:"disable_kvm" cty:"disable_kvm" hcl:"disable_kvm"`
-	TemplateName              *string                       `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
-	TemplateDescription       *string                       `mapstructure:"template_description" cty:"template_description" hcl:"template_description"`
-	CloudInit                 *bool                         `mapstructure:"cloud_init" cty:"cloud_init" hcl:"cloud_init"`
-	CloudInitStoragePool      *string                       `mapstructure:"cloud_init_storage_pool" cty:"cloud_init_storage_pool" hcl:"cloud_init_storage_pool"`
-	CloudInitDiskType         *string                       `mapstructure:"cloud_init_disk_type" cty:"cloud_init_disk_type" hcl:"cloud_init_disk_type"`
-	ISOs                      []proxmox.FlatISOsConfig      `mapstructure:"additional_iso_files" cty:"additional_iso_files" hcl:"additional_iso_files"`
-	VMInterface               *string                       `mapstructure:"vm_interface" cty:"vm_interface" hcl:"vm_interface"`
-	AdditionalArgs            *string                       `mapstructure:"qemu_additional_args" cty:"qemu_additional_args" hcl:"qemu_additional_args"`
-	CloneVM                   *string                       `mapstructure:"clone_vm" required:"true" cty:"clone_vm" hcl:"clone_vm"`
-	CloneVMID                 *int                          `mapstructure:"clone_vm_id" required:"true" cty:"clone_vm_id" hcl:"clone_vm_id"`
-	FullClone                 *bool                         `mapstructure:"full_clone" required:"false" cty:"full_clone" hcl:"full_clone"`
-	Nameserver                *string                       `mapstructure:"nameserver" required:"false" cty:"nameserver" hcl:"nameserver"`
-	Searchdomain              *string                       `mapstructure:"searchdomain" required:"false" cty:"searchdomain" hcl:"searchdomain"`
-	Ipconfigs                 []FlatcloudInitIpconfig       `mapstructure:"ipconfig" required:"false" cty:"ipconfig" hcl:"ipconfig"`
+	PackerBuildName           *string                             `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType         *string                             `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion         *string                             `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug               *bool                               `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce               *bool                               `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError             *string                             `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars            map[string]string                   `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars       []string                            `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HTTPDir                   *string                             `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
+	HTTPContent               map[string]string                   `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
+	HTTPPortMin               *int                                `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
+	HTTPPortMax               *int                                `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
+	HTTPAddress               *string                             `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
+	HTTPInterface             *string                             `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	BootGroupInterval         *string                             `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait                  *string                             `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand               []string                            `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	BootKeyInterval           *string                             `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	Type                      *string                             `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect        *string                             `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                   *string                             `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                   *int                                `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername               *string                             `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword               *string                             `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName            *string                             `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName   *string                             `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType   *string                             `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits   *int                                `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                []string                            `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys    *bool                               `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos               []string                            `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile         *string                             `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile        *string                             `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                    *bool                               `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                *string                             `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout            *string                             `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth              *bool                               `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding *bool                               `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts      *int                                `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost            *string                             `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort            *int                                `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth       *bool                               `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername        *string                             `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword        *string                             `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive     *bool                               `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile  *string                             `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile *string                             `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod     *string                             `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost              *string                             `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort              *int                                `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername          *string                             `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword          *string                             `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval      *string                             `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout       *string                             `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels          []string                            `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels           []string                            `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey              []byte                              `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey             []byte                              `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                 *string                             `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword             *string                             `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                 *string                             `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy              *bool                               `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                 *int                                `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout              *string                             `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL               *bool                               `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure             *bool                               `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM              *bool                               `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	ProxmoxURLRaw             *string                             `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
+	Profile                   *string                             `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile           *string                             `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	SkipCertValidation        *bool                               `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
+	CAFile                    *string                             `mapstructure:"ca_file" cty:"ca_file" hcl:"ca_file"`
+	CAPEM                     *string                             `mapstructure:"ca_pem" cty:"ca_pem" hcl:"ca_pem"`
+	TLSFingerprint            *string                             `mapstructure:"tls_fingerprint" cty:"tls_fingerprint" hcl:"tls_fingerprint"`
+	ClientCertFile            *string                             `mapstructure:"client_cert_file" cty:"client_cert_file" hcl:"client_cert_file"`
+	ClientKeyFile             *string                             `mapstructure:"client_key_file" cty:"client_key_file" hcl:"client_key_file"`
+	APIProxyURL               *string                             `mapstructure:"api_proxy_url" cty:"api_proxy_url" hcl:"api_proxy_url"`
+	APIExtraHeaders           map[string]string                   `mapstructure:"api_extra_headers" cty:"api_extra_headers" hcl:"api_extra_headers"`
+	Username                  *string                             `mapstructure:"username" cty:"username" hcl:"username"`
+	Password                  *string                             `mapstructure:"password" cty:"password" hcl:"password"`
+	Token                     *string                             `mapstructure:"token" cty:"token" hcl:"token"`
+	OTP                       *string                             `mapstructure:"otp" cty:"otp" hcl:"otp"`
+	TOTPSecret                *string                             `mapstructure:"totp_secret" cty:"totp_secret" hcl:"totp_secret"`
+	TaskTimeout               *string                             `mapstructure:"task_timeout" cty:"task_timeout" hcl:"task_timeout"`
+	TransferTimeout           *string                             `mapstructure:"transfer_timeout" cty:"transfer_timeout" hcl:"transfer_timeout"`
+	APIRetry                  *proxmox.FlatRetryConfig            `mapstructure:"api_retry" cty:"api_retry" hcl:"api_retry"`
+	Node                      *string                             `mapstructure:"node" cty:"node" hcl:"node"`
+	Pool                      *string                             `mapstructure:"pool" cty:"pool" hcl:"pool"`
+	VMName                    *string                             `mapstructure:"vm_name" cty:"vm_name" hcl:"vm_name"`
+	VMID                      *int                                `mapstructure:"vm_id" cty:"vm_id" hcl:"vm_id"`
+	VMIDRange                 *string                             `mapstructure:"vm_id_range" cty:"vm_id_range" hcl:"vm_id_range"`
+	Tags                      *string                             `mapstructure:"tags" cty:"tags" hcl:"tags"`
+	Boot                      *string                             `mapstructure:"boot" cty:"boot" hcl:"boot"`
+	Memory                    *int                                `mapstructure:"memory" cty:"memory" hcl:"memory"`
+	BalloonMinimum            *int                                `mapstructure:"ballooning_minimum" cty:"ballooning_minimum" hcl:"ballooning_minimum"`
+	Cores                     *int                                `mapstructure:"cores" cty:"cores" hcl:"cores"`
+	CPUType                   *string                             `mapstructure:"cpu_type" cty:"cpu_type" hcl:"cpu_type"`
+	Sockets                   *int                                `mapstructure:"sockets" cty:"sockets" hcl:"sockets"`
+	Numa                      *bool                               `mapstructure:"numa" cty:"numa" hcl:"numa"`
+	OS                        *string                             `mapstructure:"os" cty:"os" hcl:"os"`
+	BIOS                      *string                             `mapstructure:"bios" cty:"bios" hcl:"bios"`
+	EFIConfig                 *proxmox.FlatefiConfig              `mapstructure:"efi_config" cty:"efi_config" hcl:"efi_config"`
+	EFIDisk                   *string                             `mapstructure:"efidisk" cty:"efidisk" hcl:"efidisk"`
+	Machine                   *string                             `mapstructure:"machine" cty:"machine" hcl:"machine"`
+	Rng0                      *proxmox.Flatrng0Config             `mapstructure:"rng0" cty:"rng0" hcl:"rng0"`
+	TPMConfig                 *proxmox.FlattpmConfig              `mapstructure:"tpm_config" cty:"tpm_config" hcl:"tpm_config"`
+	VGA                       *proxmox.FlatvgaConfig              `mapstructure:"vga" cty:"vga" hcl:"vga"`
+	NICs                      []proxmox.FlatNICConfig             `mapstructure:"network_adapters" cty:"network_adapters" hcl:"network_adapters"`
+	Disks                     []proxmox.FlatdiskConfig            `mapstructure:"disks" cty:"disks" hcl:"disks"`
+	PCIDevices                []proxmox.FlatpciDeviceConfig       `mapstructure:"pci_devices" cty:"pci_devices" hcl:"pci_devices"`
+	Serials                   []string                            `mapstructure:"serials" cty:"serials" hcl:"serials"`
+	Agent                     *bool                               `mapstructure:"qemu_agent" cty:"qemu_agent" hcl:"qemu_agent"`
+	SCSIController            *string                             `mapstructure:"scsi_controller" cty:"scsi_controller" hcl:"scsi_controller"`
+	Onboot                    *bool                               `mapstructure:"onboot" cty:"onboot" hcl:"onboot"`
+	DisableKVM                *bool                               `mapstructure:"disable_kvm" cty:"disable_kvm" hcl:"disable_kvm"`
+	SkipPermissionCheck       *bool                               `mapstructure:"skip_permission_check" cty:"skip_permission_check" hcl:"skip_permission_check"`
+	ShutdownCommand           *string                             `mapstructure:"shutdown_command" cty:"shutdown_command" hcl:"shutdown_command"`
+	ShutdownTimeout           *string                             `mapstructure:"shutdown_timeout" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	OutputMode                *string                             `mapstructure:"output_mode" cty:"output_mode" hcl:"output_mode"`
+	SnapshotName              *string                             `mapstructure:"snapshot_name" cty:"snapshot_name" hcl:"snapshot_name"`
+	TemplateName              *string                             `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
+	TemplateIDRange           *string                             `mapstructure:"template_id_range" cty:"template_id_range" hcl:"template_id_range"`
+	ForceMode                 *string                             `mapstructure:"force_mode" cty:"force_mode" hcl:"force_mode"`
+	ForceKeepReplaced         *bool                               `mapstructure:"force_keep_replaced" cty:"force_keep_replaced" hcl:"force_keep_replaced"`
+	ForceKeepVMID             *bool                               `mapstructure:"force_keep_vm_id" cty:"force_keep_vm_id" hcl:"force_keep_vm_id"`
+	TemplateVersion           *string                             `mapstructure:"template_version" cty:"template_version" hcl:"template_version"`
+	TemplateVersionIDBase     *int                                `mapstructure:"template_version_id_base" cty:"template_version_id_base" hcl:"template_version_id_base"`
+	RetainVersions            *int                                `mapstructure:"retain_versions" cty:"retain_versions" hcl:"retain_versions"`
+	TemplateDescription       *string                             `mapstructure:"template_description" cty:"template_description" hcl:"template_description"`
+	TemplateDescriptionJSON   *bool                               `mapstructure:"template_description_json" cty:"template_description_json" hcl:"template_description_json"`
+	TemplateMetadata          map[string]string                   `mapstructure:"template_metadata" cty:"template_metadata" hcl:"template_metadata"`
+	TemplateProvisioners      []string                            `mapstructure:"template_provisioners" cty:"template_provisioners" hcl:"template_provisioners"`
+	TemplateTags              *string                             `mapstructure:"template_tags" cty:"template_tags" hcl:"template_tags"`
+	TemplateConfig            *proxmox.FlattemplateHardwareConfig `mapstructure:"template_config" cty:"template_config" hcl:"template_config"`
+	CloudInit                 *bool                               `mapstructure:"cloud_init" cty:"cloud_init" hcl:"cloud_init"`
+	CloudInitStoragePool      *string                             `mapstructure:"cloud_init_storage_pool" cty:"cloud_init_storage_pool" hcl:"cloud_init_storage_pool"`
+	CloudInitDiskType         *string                             `mapstructure:"cloud_init_disk_type" cty:"cloud_init_disk_type" hcl:"cloud_init_disk_type"`
+	ISOs                      []proxmox.FlatISOsConfig            `mapstructure:"additional_iso_files" cty:"additional_iso_files" hcl:"additional_iso_files"`
+	VMInterface               *string                             `mapstructure:"vm_interface" cty:"vm_interface" hcl:"vm_interface"`
+	AdditionalArgs            *string                             `mapstructure:"qemu_additional_args" cty:"qemu_additional_args" hcl:"qemu_additional_args"`
+	CloneVM                   *string                             `mapstructure:"clone_vm" required:"true" cty:"clone_vm" hcl:"clone_vm"`
+	CloneVMID                 *int                                `mapstructure:"clone_vm_id" required:"true" cty:"clone_vm_id" hcl:"clone_vm_id"`
+	FullClone                 *bool                               `mapstructure:"full_clone" required:"false" cty:"full_clone" hcl:"full_clone"`
+	Nameserver                *string                             `mapstructure:"nameserver" required:"false" cty:"nameserver" hcl:"nameserver"`
+	Searchdomain              *string                             `mapstructure:"searchdomain" required:"false" cty:"searchdomain" hcl:"searchdomain"`
+	Ipconfigs                 []FlatcloudInitIpconfig             `mapstructure:"ipconfig" required:"false" cty:"ipconfig" hcl:"ipconfig"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -208,15 +239,29 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
 		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
 		"proxmox_url":                  &hcldec.AttrSpec{Name: "proxmox_url", Type: cty.String, Required: false},
+		"profile":                      &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file":             &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
 		"insecure_skip_tls_verify":     &hcldec.AttrSpec{Name: "insecure_skip_tls_verify", Type: cty.Bool, Required: false},
+		"ca_file":                      &hcldec.AttrSpec{Name: "ca_file", Type: cty.String, Required: false},
+		"ca_pem":                       &hcldec.AttrSpec{Name: "ca_pem", Type: cty.String, Required: false},
+		"tls_fingerprint":              &hcldec.AttrSpec{Name: "tls_fingerprint", Type: cty.String, Required: false},
+		"client_cert_file":             &hcldec.AttrSpec{Name: "client_cert_file", Type: cty.String, Required: false},
+		"client_key_file":              &hcldec.AttrSpec{Name: "client_key_file", Type: cty.String, Required: false},
+		"api_proxy_url":                &hcldec.AttrSpec{Name: "api_proxy_url", Type: cty.String, Required: false},
+		"api_extra_headers":            &hcldec.AttrSpec{Name: "api_extra_headers", Type: cty.Map(cty.String), Required: false},
 		"username":                     &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
 		"password":                     &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
 		"token":                        &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"otp":                          &hcldec.AttrSpec{Name: "otp", Type: cty.String, Required: false},
+		"totp_secret":                  &hcldec.AttrSpec{Name: "totp_secret", Type: cty.String, Required: false},
+		"task_timeout":                 &hcldec.AttrSpec{Name: "task_timeout", Type: cty.String, Required: false},
+		"transfer_timeout":             &hcldec.AttrSpec{Name: "transfer_timeout", Type: cty.String, Required: false},
+		"api_retry":                    &hcldec.BlockSpec{TypeName: "api_retry", Nested: hcldec.ObjectSpec((*proxmox.FlatRetryConfig)(nil).HCL2Spec())},
 		"node":                         &hcldec.AttrSpec{Name: "node", Type: cty.String, Required: false},
 		"pool":                         &hcldec.AttrSpec{Name: "pool", Type: cty.String, Required: false},
-		"task_timeout":                 &hcldec.AttrSpec{Name: "task_timeout", Type: cty.String, Required: false},
 		"vm_name":                      &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
 		"vm_id":                        &hcldec.AttrSpec{Name: "vm_id", Type: cty.Number, Required: false},
+		"vm_id_range":                  &hcldec.AttrSpec{Name: "vm_id_range", Type: cty.String, Required: false},
 		"tags":                         &hcldec.AttrSpec{Name: "tags", Type: cty.String, Required: false},
 		"boot":                         &hcldec.AttrSpec{Name: "boot", Type: cty.String, Required: false},
 		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
@@ -241,8 +286,25 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"scsi_controller":              &hcldec.AttrSpec{Name: "scsi_controller", Type: cty.String, Required: false},
 		"onboot":                       &hcldec.AttrSpec{Name: "onboot", Type: cty.Bool, Required: false},
 		"disable_kvm":                  &hcldec.AttrSpec{Name: "disable_kvm", Type: cty.Bool, Required: false},
+		"skip_permission_check":        &hcldec.AttrSpec{Name: "skip_permission_check", Type: cty.Bool, Required: false},
+		"shutdown_command":             &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
+		"shutdown_timeout":             &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
+		"output_mode":                  &hcldec.AttrSpec{Name: "output_mode", Type: cty.String, Required: false},
+		"snapshot_name":                &hcldec.AttrSpec{Name: "snapshot_name", Type: cty.String, Required: false},
 		"template_name":                &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
+		"template_id_range":            &hcldec.AttrSpec{Name: "template_id_range", Type: cty.String, Required: false},
+		"force_mode":                   &hcldec.AttrSpec{Name: "force_mode", Type: cty.String, Required: false},
+		"force_keep_replaced":          &hcldec.AttrSpec{Name: "force_keep_replaced", Type: cty.Bool, Required: false},
+		"force_keep_vm_id":             &hcldec.AttrSpec{Name: "force_keep_vm_id", Type: cty.Bool, Required: false},
+		"template_version":             &hcldec.AttrSpec{Name: "template_version", Type: cty.String, Required: false},
+		"template_version_id_base":     &hcldec.AttrSpec{Name: "template_version_id_base", Type: cty.Number, Required: false},
+		"retain_versions":              &hcldec.AttrSpec{Name: "retain_versions", Type: cty.Number, Required: false},
 		"template_description":         &hcldec.AttrSpec{Name: "template_description", Type: cty.String, Required: false},
+		"template_description_json":    &hcldec.AttrSpec{Name: "template_description_json", Type: cty.Bool, Required: false},
+		"template_metadata":            &hcldec.AttrSpec{Name: "template_metadata", Type: cty.Map(cty.String), Required: false},
+		"template_provisioners":        &hcldec.AttrSpec{Name: "template_provisioners", Type: cty.List(cty.String), Required: false},
+		"template_tags":                &hcldec.AttrSpec{Name: "template_tags", Type: cty.String, Required: false},
+		"template_config":              &hcldec.BlockSpec{TypeName: "template_config", Nested: hcldec.ObjectSpec((*proxmox.FlattemplateHardwareConfig)(nil).HCL2Spec())},
 		"cloud_init":                   &hcldec.AttrSpec{Name: "cloud_init", Type: cty.Bool, Required: false},
 		"cloud_init_storage_pool":      &hcldec.AttrSpec{Name: "cloud_init_storage_pool", Type: cty.String, Required: false},
 		"cloud_init_disk_type":         &hcldec.AttrSpec{Name: "cloud_init_disk_type", Type: cty.String, Required: false},
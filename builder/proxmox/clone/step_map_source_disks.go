@@ -28,7 +28,7 @@ type cloneSource interface {
 	CheckVmRef(*proxmoxapi.VmRef) error
 }
 
-var _ cloneSource = &proxmoxapi.Client{}
+var _ cloneSource = &proxmox.Client{}
 
 func (s *StepMapSourceDisks) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
@@ -0,0 +1,338 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+)
+
+// Client is the Proxmox API client of proxmox-api-go, returning *APIError
+// errors from the calls used by this plugin so that they can be told apart
+// with errors.Is. Calls that can safely be repeated are retried according to
+// the api_retry policy.
+type Client struct {
+	*proxmox.Client
+	retry RetryConfig
+	// Authenticated HTTP client and URL of the API, for the requests
+	// proxmox-api-go can not make without waiting for their task
+	http   *http.Client
+	apiURL string
+}
+
+// CheckVmRef fills the node and type of the guest, or returns an ErrNotFound
+// error when it does not exist.
+func (c *Client) CheckVmRef(vmr *proxmox.VmRef) error {
+	return c.retry.Do(fmt.Sprintf("looking up guest %d", vmr.VmId()), func() error {
+		err := c.Client.CheckVmRef(vmr)
+		if err == nil {
+			return nil
+		}
+		exists, lerr := c.Client.VMIdExists(vmr.VmId())
+		if lerr != nil {
+			return ClassifyError(lerr, "")
+		}
+		if !exists {
+			return &APIError{Kind: ErrNotFound, Err: err}
+		}
+		return ClassifyError(err, "")
+	})
+}
+
+// GetVmRefsByName returns the guests with the given name, or an ErrNotFound
+// error when there is none.
+func (c *Client) GetVmRefsByName(vmName string) (vmrs []*proxmox.VmRef, err error) {
+	err = c.retry.Do(fmt.Sprintf("looking up guests named %s", vmName), func() error {
+		vmrs, err = c.Client.GetVmRefsByName(vmName)
+		if err == nil {
+			return nil
+		}
+		guests, lerr := c.Client.GetResourceList("vm")
+		if lerr != nil {
+			return ClassifyError(lerr, "")
+		}
+		for _, g := range guests {
+			if guest, _ := g.(map[string]interface{}); guest["name"] == vmName {
+				return ClassifyError(err, "")
+			}
+		}
+		return &APIError{Kind: ErrNotFound, Err: err}
+	})
+	return vmrs, err
+}
+
+func (c *Client) GetVersion() (version proxmox.Version, err error) {
+	err = c.retry.Do("fetching the Proxmox VE version", func() error {
+		version, err = c.Client.GetVersion()
+		return ClassifyError(err, "")
+	})
+	return version, err
+}
+
+func (c *Client) GetResourceList(resourceType string) (list []interface{}, err error) {
+	err = c.retry.Do("listing cluster resources", func() error {
+		list, err = c.Client.GetResourceList(resourceType)
+		return ClassifyError(err, "")
+	})
+	return list, err
+}
+
+func (c *Client) GetNextID(currentID int) (id int, err error) {
+	err = c.retry.Do("getting the next free VMID", func() error {
+		id, err = c.Client.GetNextID(currentID)
+		return ClassifyError(err, "")
+	})
+	return id, err
+}
+
+func (c *Client) GetVmConfig(vmr *proxmox.VmRef) (config map[string]interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("reading configuration of guest %d", vmr.VmId()), func() error {
+		config, err = c.Client.GetVmConfig(vmr)
+		return ClassifyError(err, "")
+	})
+	return config, err
+}
+
+func (c *Client) SetVmConfig(vmr *proxmox.VmRef, params map[string]interface{}) (exitStatus interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("updating configuration of VM %d", vmr.VmId()), func() error {
+		exitStatus, err = c.Client.SetVmConfig(vmr, params)
+		return ClassifyError(err, "")
+	})
+	return exitStatus, err
+}
+
+func (c *Client) SetLxcConfig(vmr *proxmox.VmRef, params map[string]interface{}) (exitStatus interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("updating configuration of container %d", vmr.VmId()), func() error {
+		exitStatus, err = c.Client.SetLxcConfig(vmr, params)
+		return ClassifyError(err, "")
+	})
+	return exitStatus, err
+}
+
+// ResizeQemuDiskRaw is not retried, sizes may be relative.
+func (c *Client) ResizeQemuDiskRaw(vmr *proxmox.VmRef, disk string, size string) (interface{}, error) {
+	exitStatus, err := c.Client.ResizeQemuDiskRaw(vmr, disk, size)
+	return exitStatus, ClassifyError(err, "")
+}
+
+func (c *Client) CreateQemuVm(node string, params map[string]interface{}) (string, error) {
+	exitStatus, err := c.Client.CreateQemuVm(node, params)
+	return exitStatus, ClassifyError(err, exitStatus)
+}
+
+func (c *Client) CloneQemuVm(vmr *proxmox.VmRef, params map[string]interface{}) (string, error) {
+	exitStatus, err := c.Client.CloneQemuVm(vmr, params)
+	return exitStatus, ClassifyError(err, exitStatus)
+}
+
+func (c *Client) CreateTemplate(vmr *proxmox.VmRef) error {
+	return c.retry.Do(fmt.Sprintf("converting guest %d to a template", vmr.VmId()), func() error {
+		return ClassifyError(c.Client.CreateTemplate(vmr), "")
+	})
+}
+
+func (c *Client) StartVm(vmr *proxmox.VmRef) (exitStatus string, err error) {
+	err = c.retry.Do(fmt.Sprintf("starting guest %d", vmr.VmId()), func() error {
+		exitStatus, err = c.Client.StartVm(vmr)
+		return ClassifyError(err, exitStatus)
+	})
+	return exitStatus, err
+}
+
+func (c *Client) StopVm(vmr *proxmox.VmRef) (exitStatus string, err error) {
+	err = c.retry.Do(fmt.Sprintf("stopping guest %d", vmr.VmId()), func() error {
+		exitStatus, err = c.Client.StopVm(vmr)
+		return ClassifyError(err, exitStatus)
+	})
+	return exitStatus, err
+}
+
+func (c *Client) ShutdownVm(vmr *proxmox.VmRef) (exitStatus string, err error) {
+	err = c.retry.Do(fmt.Sprintf("shutting down guest %d", vmr.VmId()), func() error {
+		exitStatus, err = c.Client.ShutdownVm(vmr)
+		return ClassifyError(err, exitStatus)
+	})
+	return exitStatus, err
+}
+
+func (c *Client) DeleteVm(vmr *proxmox.VmRef) (exitStatus string, err error) {
+	err = c.retry.Do(fmt.Sprintf("deleting guest %d", vmr.VmId()), func() error {
+		exitStatus, err = c.Client.DeleteVm(vmr)
+		return ClassifyError(err, exitStatus)
+	})
+	return exitStatus, err
+}
+
+func (c *Client) GetVmState(vmr *proxmox.VmRef) (state map[string]interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("reading the status of guest %d", vmr.VmId()), func() error {
+		state, err = c.Client.GetVmState(vmr)
+		return ClassifyError(err, "")
+	})
+	return state, err
+}
+
+func (c *Client) DeleteSnapshot(vmr *proxmox.VmRef, name string) (exitStatus string, err error) {
+	err = c.retry.Do(fmt.Sprintf("deleting snapshot %s of guest %d", name, vmr.VmId()), func() error {
+		exitStatus, err = proxmox.DeleteSnapshot(c.Client, vmr, proxmox.SnapshotName(name))
+		return ClassifyError(err, exitStatus)
+	})
+	return exitStatus, err
+}
+
+func (c *Client) Sendkey(vmr *proxmox.VmRef, qmKey string) error {
+	return ClassifyError(c.Client.Sendkey(vmr, qmKey), "")
+}
+
+func (c *Client) VzDump(vmr *proxmox.VmRef, params map[string]interface{}) (interface{}, error) {
+	exitStatus, err := c.Client.VzDump(vmr, params)
+	return exitStatus, ClassifyError(err, "")
+}
+
+func (c *Client) GetStorageConfig(id string) (config map[string]interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("reading configuration of storage %s", id), func() error {
+		config, err = c.Client.GetStorageConfig(id)
+		return ClassifyError(err, "")
+	})
+	return config, err
+}
+
+func (c *Client) GetStorageContent(vmr *proxmox.VmRef, storageName string) (content map[string]interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("listing content of storage %s", storageName), func() error {
+		content, err = c.Client.GetStorageContent(vmr, storageName)
+		return ClassifyError(err, "")
+	})
+	return content, err
+}
+
+// Upload uploads file to a storage, and returns the ID of the task moving it
+// into place, to be followed with TaskTracker.Wait. The upload is only
+// retried when file can be rewound.
+func (c *Client) Upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader) (upid string, err error) {
+	size, err := fileSize(file)
+	if err != nil {
+		return "", err
+	}
+	seeker, ok := file.(io.Seeker)
+	if !ok {
+		return c.upload(ctx, node, storage, contentType, filename, file, size)
+	}
+	err = c.retry.Do(fmt.Sprintf("uploading %s to storage %s", filename, storage), func() error {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		upid, err = c.upload(ctx, node, storage, contentType, filename, file, size)
+		return err
+	})
+	return upid, err
+}
+
+func (c *Client) upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader, size int64) (string, error) {
+	// The file is streamed between the multipart headers and trailer, the
+	// API requires the length of the whole body
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("content", contentType); err != nil {
+		return "", err
+	}
+	if _, err := w.CreateFormFile("filename", filename); err != nil {
+		return "", err
+	}
+	headerSize := buf.Len()
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	body := io.MultiReader(bytes.NewReader(buf.Bytes()[:headerSize]), file, bytes.NewReader(buf.Bytes()[headerSize:]))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/nodes/%s/storage/%s/upload", c.apiURL, node, storage), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(buf.Len()) + size
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", ClassifyError(err, "")
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", ClassifyError(err, "")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", ClassifyError(errors.New(resp.Status), string(respBody))
+	}
+	var data struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &data); err != nil || data.Data == "" {
+		return "", fmt.Errorf("unexpected upload response %q", respBody)
+	}
+	return data.Data, nil
+}
+
+// fileSize returns the number of bytes left to read from file.
+func fileSize(file io.Reader) (int64, error) {
+	switch f := file.(type) {
+	case interface{ Stat() (os.FileInfo, error) }:
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	case interface{ Len() int }:
+		return int64(f.Len()), nil
+	}
+	return 0, fmt.Errorf("the size of %T is unknown", file)
+}
+
+func (c *Client) DeleteVolume(vmr *proxmox.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error) {
+	err = c.retry.Do(fmt.Sprintf("deleting volume %s", volumeName), func() error {
+		exitStatus, err = c.Client.DeleteVolume(vmr, storageName, volumeName)
+		return ClassifyError(err, "")
+	})
+	return exitStatus, err
+}
+
+func (c *Client) GetItemList(url string) (list map[string]interface{}, err error) {
+	err = c.retry.Do("GET "+url, func() error {
+		list, err = c.Client.GetItemList(url)
+		return ClassifyError(err, "")
+	})
+	return list, err
+}
+
+func (c *Client) GetItemListInterfaceArray(url string) (list []interface{}, err error) {
+	err = c.retry.Do("GET "+url, func() error {
+		list, err = c.Client.GetItemListInterfaceArray(url)
+		return ClassifyError(err, "")
+	})
+	return list, err
+}
+
+// CreateItemReturnStatus posts to the API without waiting for the task it
+// starts, and returns the response body. Posts are not retried.
+func (c *Client) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	body, err := c.Client.CreateItemReturnStatus(params, url)
+	return body, ClassifyError(err, body)
+}
+
+func (c *Client) PostWithTask(params map[string]interface{}, url string) (string, error) {
+	exitStatus, err := c.Client.PostWithTask(params, url)
+	return exitStatus, ClassifyError(err, exitStatus)
+}
+
+func (c *Client) Delete(url string) error {
+	return ClassifyError(c.Client.Delete(url), "")
+}
@@ -4,6 +4,7 @@
 package proxmox
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
@@ -13,15 +14,26 @@ import (
 )
 
 type Artifact struct {
-	builderID     string
-	templateID    int
-	proxmoxClient *proxmox.Client
+	builderID string
+	// output_mode of the build: template, vm or snapshot
+	outputMode string
+	// ID of the template, of the VM, or of the VM the snapshot was taken of
+	vmID          int
+	snapshotName  string
+	proxmoxClient artifactDestroyer
 
 	// StateData should store data such as GeneratedData
 	// to be shared with post-processors
 	StateData map[string]interface{}
 }
 
+type artifactDestroyer interface {
+	DeleteVm(*proxmox.VmRef) (string, error)
+	DeleteSnapshot(*proxmox.VmRef, string) (string, error)
+}
+
+var _ artifactDestroyer = &Client{}
+
 // Artifact implements packersdk.Artifact
 var _ packersdk.Artifact = &Artifact{}
 
@@ -33,20 +45,53 @@ func (*Artifact) Files() []string {
 	return nil
 }
 
+// Id returns the VMID of the template or VM, including with
+// `output_mode = "snapshot"`.
 func (a *Artifact) Id() string {
-	return strconv.Itoa(a.templateID)
+	return strconv.Itoa(a.vmID)
 }
 
 func (a *Artifact) String() string {
-	return fmt.Sprintf("A template was created: %d", a.templateID)
+	switch a.outputMode {
+	case "vm":
+		return fmt.Sprintf("A virtual machine was created: %d", a.vmID)
+	case "snapshot":
+		return fmt.Sprintf("A snapshot was taken: %s of virtual machine %d", a.snapshotName, a.vmID)
+	}
+	return fmt.Sprintf("A template was created: %d", a.vmID)
 }
 
 func (a *Artifact) State(name string) interface{} {
+	switch name {
+	case "output_mode":
+		return a.outputMode
+	case "snapshot_name":
+		return a.snapshotName
+	}
 	return a.StateData[name]
 }
 
 func (a *Artifact) Destroy() error {
-	log.Printf("Destroying template: %d", a.templateID)
-	_, err := a.proxmoxClient.DeleteVm(proxmox.NewVmRef(a.templateID))
+	vmRef := proxmox.NewVmRef(a.vmID)
+	var what string
+	var err error
+	switch a.outputMode {
+	case "vm":
+		what = fmt.Sprintf("virtual machine %d", a.vmID)
+		log.Printf("Destroying %s", what)
+		_, err = a.proxmoxClient.DeleteVm(vmRef)
+	case "snapshot":
+		what = fmt.Sprintf("snapshot %s of virtual machine %d", a.snapshotName, a.vmID)
+		log.Printf("Destroying %s", what)
+		_, err = a.proxmoxClient.DeleteSnapshot(vmRef, a.snapshotName)
+	default:
+		what = fmt.Sprintf("template %d", a.vmID)
+		log.Printf("Destroying %s", what)
+		_, err = a.proxmoxClient.DeleteVm(vmRef)
+	}
+	if errors.Is(err, ErrNotFound) {
+		log.Printf("The %s is already gone", what)
+		return nil
+	}
 	return err
 }
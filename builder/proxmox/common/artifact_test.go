@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+type destroyerMock struct {
+	calls []string
+	err   error
+}
+
+func (m *destroyerMock) DeleteVm(vmr *proxmox.VmRef) (string, error) {
+	m.calls = append(m.calls, fmt.Sprintf("delete %d", vmr.VmId()))
+	return "", m.err
+}
+func (m *destroyerMock) DeleteSnapshot(vmr *proxmox.VmRef, name string) (string, error) {
+	m.calls = append(m.calls, fmt.Sprintf("delete snapshot %d %s", vmr.VmId(), name))
+	return "", m.err
+}
+
+var _ artifactDestroyer = &destroyerMock{}
+
+func TestArtifact(t *testing.T) {
+	cs := []struct {
+		name           string
+		outputMode     string
+		destroyErr     error
+		expectedString string
+		expectedCalls  []string
+		expectedErr    bool
+	}{
+		{
+			name:           "template",
+			outputMode:     "template",
+			expectedString: "A template was created: 100",
+			expectedCalls:  []string{"delete 100"},
+		},
+		{
+			name:           "vm",
+			outputMode:     "vm",
+			expectedString: "A virtual machine was created: 100",
+			expectedCalls:  []string{"delete 100"},
+		},
+		{
+			name:           "snapshot",
+			outputMode:     "snapshot",
+			expectedString: "A snapshot was taken: release of virtual machine 100",
+			expectedCalls:  []string{"delete snapshot 100 release"},
+		},
+		{
+			name:           "already destroyed",
+			outputMode:     "snapshot",
+			destroyErr:     &APIError{Kind: ErrNotFound, Err: fmt.Errorf("snapshot 'release' does not exist")},
+			expectedString: "A snapshot was taken: release of virtual machine 100",
+			expectedCalls:  []string{"delete snapshot 100 release"},
+		},
+		{
+			name:           "destroy error",
+			outputMode:     "vm",
+			destroyErr:     &APIError{Kind: ErrLocked, Err: fmt.Errorf("VM is locked (backup)")},
+			expectedString: "A virtual machine was created: 100",
+			expectedCalls:  []string{"delete 100"},
+			expectedErr:    true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &destroyerMock{err: c.destroyErr}
+			a := &Artifact{outputMode: c.outputMode, vmID: 100, snapshotName: "release", proxmoxClient: client}
+			require.Equal(t, "100", a.Id())
+			require.Equal(t, c.expectedString, a.String())
+			err := a.Destroy()
+			if c.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expectedCalls, client.calls)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Proxmox tickets are valid for two hours
+	ticketLifetime = 2 * time.Hour
+	// Tickets are renewed well before they expire, so that a build never
+	// uses an expired one
+	ticketRenewal = 90 * time.Minute
+)
+
+// ticketTransport authenticates requests with a Proxmox ticket. It logs in
+// with the password, completing the two-factor authentication challenge when
+// the user has TOTP enabled, and renews the ticket before it expires. When the
+// API answers 401, it logs in again and retries the request once.
+type ticketTransport struct {
+	base     http.RoundTripper
+	apiURL   string
+	username string
+	password string
+	// One-time code, only used for the first login
+	otp string
+	// Secret generating TOTP codes for every login, when set
+	totpSecret []byte
+	now        func() time.Time
+
+	mu        sync.Mutex
+	ticket    string
+	csrfToken string
+	issued    time.Time
+}
+
+func newTicketTransport(base http.RoundTripper, apiURL string, c *ClientConfig) (*ticketTransport, error) {
+	t := &ticketTransport{
+		base:     base,
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		username: c.Username,
+		password: c.Password,
+		otp:      c.OTP,
+		now:      time.Now,
+	}
+	if c.TOTPSecret != "" {
+		var err error
+		if t.totpSecret, err = decodeTOTPSecret(c.TOTPSecret); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *ticketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	err := t.refresh(false)
+	ticket, csrfToken := t.ticket, t.csrfToken
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(authenticate(req, ticket, csrfToken))
+	// The body of the request can only be sent again when it can be rewound
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	log.Printf("ticket rejected by the API, logging in again")
+	t.mu.Lock()
+	err = t.refresh(true)
+	ticket, csrfToken = t.ticket, t.csrfToken
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.base.RoundTrip(authenticate(req, ticket, csrfToken))
+}
+
+// authenticate returns a copy of the request carrying the ticket.
+func authenticate(req *http.Request, ticket string, csrfToken string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "PVEAuthCookie="+ticket)
+	if req.Method != http.MethodGet {
+		req.Header.Set("CSRFPreventionToken", csrfToken)
+	}
+	return req
+}
+
+// refresh gets a ticket when there is none, renews it when it nears its
+// expiry, and logs in again when it was rejected.
+func (t *ticketTransport) refresh(rejected bool) error {
+	age := t.now().Sub(t.issued)
+	if t.ticket != "" && !rejected && age < ticketRenewal {
+		return nil
+	}
+	// A valid ticket is renewed by logging in with it as password, which
+	// does not require a second factor
+	if t.ticket != "" && !rejected && age < ticketLifetime {
+		log.Printf("renewing ticket issued %s ago", age.Round(time.Second))
+		err := t.login(url.Values{"username": {t.username}, "password": {t.ticket}})
+		if err == nil {
+			return nil
+		}
+		log.Printf("error renewing ticket, logging in again: %s", err)
+	}
+	return t.login(url.Values{"username": {t.username}, "password": {t.password}, "new-format": {"1"}})
+}
+
+// login requests a ticket, and completes the two-factor authentication
+// challenge when the API asks for it.
+func (t *ticketTransport) login(params url.Values) error {
+	data, err := t.requestTicket(params)
+	if err != nil {
+		return err
+	}
+	if data.NeedTFA == 1 {
+		code := t.otp
+		if t.totpSecret != nil {
+			code = totp(t.totpSecret, t.now())
+		}
+		if code == "" {
+			return errors.New("two-factor authentication is enabled for the user, set otp or totp_secret")
+		}
+		// One-time codes cannot be used twice
+		t.otp = ""
+		data, err = t.requestTicket(url.Values{
+			"username":      {t.username},
+			"tfa-challenge": {data.Ticket},
+			"password":      {"totp:" + code},
+			"new-format":    {"1"},
+		})
+		if err != nil {
+			return fmt.Errorf("two-factor authentication failed: %w", err)
+		}
+	}
+	t.ticket, t.csrfToken, t.issued = data.Ticket, data.CSRFPreventionToken, t.now()
+	return nil
+}
+
+type ticketData struct {
+	Ticket              string `json:"ticket"`
+	CSRFPreventionToken string `json:"CSRFPreventionToken"`
+	NeedTFA             int    `json:"NeedTFA"`
+}
+
+func (t *ticketTransport) requestTicket(params url.Values) (*ticketData, error) {
+	req, err := http.NewRequest(http.MethodPost, t.apiURL+"/access/ticket", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, ClassifyError(err, "")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, ClassifyError(errors.New(resp.Status), string(body))
+	}
+	var ticketResp struct {
+		Data *ticketData `json:"data"`
+	}
+	if err := json.Unmarshal(body, &ticketResp); err != nil || ticketResp.Data == nil || ticketResp.Data.Ticket == "" {
+		return nil, fmt.Errorf("invalid login response: %s", body)
+	}
+	return ticketResp.Data, nil
+}
+
+// decodeTOTPSecret decodes a base32 TOTP secret, as shown by Proxmox when
+// enrolling it.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.NewReplacer(" ", "", "=", "").Replace(secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("totp_secret must be a base32 encoded secret")
+	}
+	return key, nil
+}
+
+// totp returns the 6 digits TOTP code of the time, as specified by RFC 6238
+// with the defaults Proxmox uses: SHA-1 and a period of 30 seconds.
+func totp(key []byte, now time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(now.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000)
+}
+
+// headerTransport adds headers to every request.
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) == 0 {
+		return t.base.RoundTrip(req)
+	}
+	// Round trippers must not modify the request
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
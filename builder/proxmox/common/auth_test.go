@@ -0,0 +1,170 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	// Test vectors of RFC 6238, truncated to 6 digits
+	key, err := decodeTOTPSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	require.NoError(t, err)
+	require.Equal(t, []byte("12345678901234567890"), key)
+
+	cs := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+	for unix, expected := range cs {
+		require.Equal(t, expected, totp(key, time.Unix(unix, 0)), "code at %d", unix)
+	}
+
+	key, err = decodeTOTPSecret("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	require.NoError(t, err)
+	require.Equal(t, []byte("12345678901234567890"), key)
+
+	_, err = decodeTOTPSecret("not base32!")
+	require.Error(t, err)
+}
+
+// mockTicketAPI is a Proxmox API requiring TOTP two-factor authentication.
+type mockTicketAPI struct {
+	t      *testing.T
+	key    []byte
+	now    time.Time
+	issued int
+	// Tickets accepted by the API
+	valid  map[string]bool
+	logins []string
+	// Tickets the requests to /version were sent with
+	used []string
+}
+
+func (m *mockTicketAPI) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/access/ticket" {
+		require.NoError(m.t, req.ParseForm())
+		user, password := req.PostForm.Get("username"), req.PostForm.Get("password")
+		var kind string
+		switch {
+		case user != "dummy@vmhost":
+		case req.PostForm.Get("tfa-challenge") == "partial" && password == "totp:"+totp(m.key, m.now):
+			kind = "tfa"
+		case password == "correct-horse-battery-staple":
+			m.logins = append(m.logins, "password")
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"ticket": "partial", "NeedTFA": 1},
+			})
+			return
+		case m.valid[password]:
+			kind = "renewal"
+		}
+		if kind == "" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		m.logins = append(m.logins, kind)
+		m.issued++
+		ticket := fmt.Sprintf("ticket-%d", m.issued)
+		m.valid[ticket] = true
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"ticket": ticket, "CSRFPreventionToken": "csrf-" + ticket},
+		})
+		return
+	}
+
+	ticket := strings.TrimPrefix(req.Header.Get("Authorization"), "PVEAuthCookie=")
+	if !m.valid[ticket] || (req.Method != http.MethodGet && req.Header.Get("CSRFPreventionToken") != "csrf-"+ticket) {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	m.used = append(m.used, ticket)
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"version": "8.2.2", "release": "8.2"},
+	})
+}
+
+func TestTicketTransport(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	key, _ := decodeTOTPSecret(secret)
+	api := &mockTicketAPI{t: t, key: key, now: time.Unix(1700000000, 0), valid: map[string]bool{}}
+	mockAPI := httptest.NewServer(api)
+	defer mockAPI.Close()
+
+	config := ClientConfig{
+		ProxmoxURLRaw: mockAPI.URL,
+		Username:      "dummy@vmhost",
+		Password:      "correct-horse-battery-staple",
+		TOTPSecret:    secret,
+	}
+	require.Empty(t, config.Prepare())
+	tickets, err := newTicketTransport(http.DefaultTransport, mockAPI.URL, &config)
+	require.NoError(t, err)
+	tickets.now = func() time.Time { return api.now }
+	pmClient, err := proxmox.NewClient(mockAPI.URL, &http.Client{Transport: tickets}, "", nil, "", 300)
+	require.NoError(t, err)
+	client := &Client{Client: pmClient}
+
+	_, err = client.GetVersion()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "tfa"}, api.logins)
+
+	// The ticket is renewed with itself when it nears its expiry
+	api.now = api.now.Add(100 * time.Minute)
+	_, err = client.GetVersion()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "tfa", "renewal"}, api.logins)
+
+	// Rejected tickets cause a new login, with a new TOTP code
+	api.now = api.now.Add(time.Minute)
+	api.valid = map[string]bool{}
+	_, err = client.GetVersion()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "tfa", "renewal", "password", "tfa"}, api.logins)
+	require.Equal(t, []string{"ticket-1", "ticket-2", "ticket-3"}, api.used)
+
+	// Non-GET requests carry the CSRF prevention token
+	_, err = client.CreateItemReturnStatus(map[string]interface{}{"foo": "bar"}, "/nodes/pve/qemu")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ticket-1", "ticket-2", "ticket-3", "ticket-3"}, api.used)
+}
+
+func TestTicketTransportOTP(t *testing.T) {
+	key, _ := decodeTOTPSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	api := &mockTicketAPI{t: t, key: key, now: time.Now(), valid: map[string]bool{}}
+	mockAPI := httptest.NewServer(api)
+	defer mockAPI.Close()
+
+	config := ClientConfig{
+		ProxmoxURLRaw: mockAPI.URL,
+		Username:      "dummy@vmhost",
+		Password:      "correct-horse-battery-staple",
+	}
+	require.Empty(t, config.Prepare())
+	_, err := config.NewClient(false)
+	require.ErrorContains(t, err, "two-factor authentication is enabled for the user, set otp or totp_secret")
+
+	config.OTP = totp(key, api.now)
+	_, err = config.NewClient(false)
+	require.NoError(t, err)
+
+	config.OTP = "000000"
+	if config.OTP == totp(key, api.now) {
+		config.OTP = "111111"
+	}
+	_, err = config.NewClient(false)
+	require.ErrorContains(t, err, "two-factor authentication failed")
+	require.ErrorIs(t, err, ErrPermissionDenied)
+}
@@ -7,6 +7,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -31,17 +33,38 @@ type Builder struct {
 	preSteps      []multistep.Step
 	postSteps     []multistep.Step
 	runner        multistep.Runner
-	proxmoxClient *proxmox.Client
+	proxmoxClient *Client
 	vmCreator     ProxmoxVMCreator
+
+	// CustomConnect registers additional communicator types, keyed by the
+	// communicator type name. See communicator.StepConnect.
+	CustomConnect map[string]multistep.Step
+	// RequiredStorage lists the storage pools used by the builder on top of
+	// those of Config, checked before anything is created.
+	RequiredStorage []StorageRequirement
+	// DiskContent is the content type the disk storage pools must allow.
+	// Defaults to images.
+	DiskContent string
+	// RequiredPrivileges lists the privileges needed by the builder on top of
+	// those of Config and RequiredStorage, checked before anything is created.
+	RequiredPrivileges []PrivilegeRequirement
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook, state multistep.StateBag) (packersdk.Artifact, error) {
 	var err error
-	b.proxmoxClient, err = newProxmoxClient(b.config)
+	b.proxmoxClient, err = b.config.NewClient(b.config.PackerDebug)
 	if err != nil {
 		return nil, err
 	}
 
+	if !b.config.SkipPermissionCheck {
+		storage := append(b.config.storageRequirements(b.DiskContent), b.RequiredStorage...)
+		reqs := append(b.config.privilegeRequirements(b.proxmoxClient, storage), b.RequiredPrivileges...)
+		if err := checkPermissions(b.proxmoxClient, reqs); err != nil {
+			return nil, fmt.Errorf("insufficient privileges for %s: %s", b.config.Username, err)
+		}
+	}
+
 	// Set up the state
 	state.Put("config", &b.config)
 	state.Put("proxmoxClient", b.proxmoxClient)
@@ -61,20 +84,26 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook,
 			Ctx:        b.config.Ctx,
 		},
 		&communicator.StepConnect{
-			Config:    comm,
-			Host:      commHost((*comm).Host()),
-			SSHConfig: (*comm).SSHConfigFunc(),
+			Config:        comm,
+			Host:          commHost((*comm).Host()),
+			SSHConfig:     (*comm).SSHConfigFunc(),
+			CustomConnect: b.CustomConnect,
 		},
 		&commonsteps.StepProvision{},
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
-		&stepRemoveCloudInitDrive{},
-		&stepConvertToTemplate{},
-		&stepFinalizeTemplateConfig{},
-		&stepSuccess{},
 	}
-	preSteps := b.preSteps
+	coreSteps = append(coreSteps, outputSteps(b.config.OutputMode)...)
+	coreSteps = append(coreSteps, &stepSuccess{})
+	// Validate the configuration against the cluster before creating anything
+	preSteps := []multistep.Step{
+		&stepValidateCluster{
+			storage:     b.RequiredStorage,
+			diskContent: b.DiskContent,
+		},
+	}
+	preSteps = append(preSteps, b.preSteps...)
 	for idx := range b.config.ISOs {
 		if b.config.ISOs[idx].ISODownloadPVE {
 			preSteps = append(preSteps,
@@ -118,22 +147,57 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook,
 		return nil, errors.New("build was cancelled")
 	}
 
-	// Verify that the template_id was set properly, otherwise we didn't progress through the last step
-	tplID, ok := state.Get("template_id").(int)
-	if !ok {
-		return nil, fmt.Errorf("template ID could not be determined")
-	}
-
 	artifact := &Artifact{
 		builderID:     b.id,
-		templateID:    tplID,
+		outputMode:    b.config.OutputMode,
 		proxmoxClient: b.proxmoxClient,
 		StateData:     map[string]interface{}{"generated_data": state.Get("generated_data")},
 	}
+	if b.config.OutputMode == "template" {
+		// Verify that the template_id was set properly, otherwise we didn't progress through the last step
+		tplID, ok := state.Get("template_id").(int)
+		if !ok {
+			return nil, fmt.Errorf("template ID could not be determined")
+		}
+		artifact.vmID = tplID
+	} else {
+		if _, ok := state.GetOk("success"); !ok {
+			return nil, fmt.Errorf("build did not complete")
+		}
+		artifact.vmID = state.Get("vmRef").(*proxmox.VmRef).VmId()
+		artifact.snapshotName = b.config.SnapshotName
+	}
 
 	return artifact, nil
 }
 
+// outputSteps returns the steps turning the provisioned VM into the output of
+// output_mode. The replaced template is only touched once the new template is
+// finalized, as a failure before would otherwise leave neither of them.
+func outputSteps(outputMode string) []multistep.Step {
+	switch outputMode {
+	case "vm":
+		return []multistep.Step{
+			&stepRemoveCloudInitDrive{},
+			&stepShutdownVM{},
+			&stepApplyTemplateConfig{},
+			&stepFinalizeTemplateConfig{},
+		}
+	case "snapshot":
+		return []multistep.Step{&stepTakeSnapshot{}}
+	default:
+		return []multistep.Step{
+			&stepRemoveCloudInitDrive{},
+			&stepShutdownVM{},
+			&stepApplyTemplateConfig{},
+			&stepConvertToTemplate{},
+			&stepFinalizeTemplateConfig{},
+			&stepReplaceTemplate{},
+			&stepRetainVersions{},
+		}
+	}
+}
+
 // Returns ssh_host or winrm_host (see communicator.Config.Host) config
 // parameter when set, otherwise gets the host IP from running VM
 func commHost(host string) func(state multistep.StateBag) (string, error) {
@@ -148,11 +212,17 @@ func commHost(host string) func(state multistep.StateBag) (string, error) {
 // Reads the first non-loopback interface's IP address from the VM.
 // qemu-guest-agent package must be installed on the VM
 func getVMIP(state multistep.StateBag) (string, error) {
-	client := state.Get("proxmoxClient").(*proxmox.Client)
+	client := state.Get("proxmoxClient").(*Client)
 	config := state.Get("config").(*Config)
 	vmRef := state.Get("vmRef").(*proxmox.VmRef)
 
-	ifs, err := client.GetVmAgentNetworkInterfaces(vmRef)
+	var ifs []proxmox.AgentNetworkInterface
+	var err error
+	if vmRef.GetVmType() == "lxc" {
+		ifs, err = getContainerInterfaces(client, vmRef)
+	} else {
+		ifs, err = client.GetVmAgentNetworkInterfaces(vmRef)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -188,3 +258,39 @@ func getVMIP(state multistep.StateBag) (string, error) {
 
 	return "", fmt.Errorf("Found no IP addresses on VM")
 }
+
+// Reads the network interfaces of a running LXC container. Unlike VMs, this
+// does not require an agent inside the guest.
+func getContainerInterfaces(client *Client, vmRef *proxmox.VmRef) ([]proxmox.AgentNetworkInterface, error) {
+	url := fmt.Sprintf("/nodes/%s/lxc/%d/interfaces", vmRef.Node(), vmRef.VmId())
+	data, err := client.GetItemConfigInterfaceArray(url, "container", "INTERFACES")
+	if err != nil {
+		return nil, err
+	}
+
+	ifs := []proxmox.AgentNetworkInterface{}
+	for _, raw := range data {
+		iface, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		netIf := proxmox.AgentNetworkInterface{}
+		if name, ok := iface["name"].(string); ok {
+			netIf.Name = name
+		}
+		for _, key := range []string{"inet", "inet6"} {
+			cidr, ok := iface[key].(string)
+			if !ok {
+				continue
+			}
+			ip, _, err := net.ParseCIDR(cidr)
+			if err != nil {
+				log.Printf("could not parse %s address %q of interface %s: %s", key, cidr, netIf.Name, err)
+				continue
+			}
+			netIf.IpAddresses = append(netIf.IpAddresses, ip)
+		}
+		ifs = append(ifs, netIf)
+	}
+	return ifs, nil
+}
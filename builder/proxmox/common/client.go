@@ -1,40 +1,356 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
+//go:generate packer-sdc struct-markdown
+
 package proxmox
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 )
 
-func newProxmoxClient(config Config) (*proxmox.Client, error) {
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: config.SkipCertValidation,
+// ClientConfig holds the settings used to connect to the Proxmox API. They are
+// shared by the builders and post-processors of this plugin.
+type ClientConfig struct {
+	// URL to the Proxmox API, including the full path,
+	// so `https://<server>:<port>/api2/json` for example.
+	// Can also be set via the `PROXMOX_URL` environment variable.
+	ProxmoxURLRaw string `mapstructure:"proxmox_url"`
+	proxmoxURL    *url.URL
+	// Name of the profile of the credentials file to read the connection
+	// settings from, so that they stay out of the template. A profile sets
+	// any of `proxmox_url`, `username`, `password`, `token`, `totp_secret`,
+	// `insecure_skip_tls_verify`, `ca_file`, `tls_fingerprint`,
+	// `client_cert_file`, `client_key_file`, `api_proxy_url` and `node`, the
+	// default node of the builders and the `proxmox-import` post-processor.
+	// Settings of the template take precedence over the profile, which takes
+	// precedence over the environment variables. The `password` and `token`
+	// of the profile are ignored when the template sets either one.
+	// Can also be set via the `PROXMOX_PROFILE` environment variable.
+	//
+	// ```ini
+	// [homelab]
+	// proxmox_url = https://pve.home.example.com:8006/api2/json
+	// username    = packer@pve!build
+	// token       = 01234567-89ab-cdef-0123-456789abcdef
+	// ca_file     = homelab-ca.pem
+	// node        = pve1
+	// ```
+	Profile string `mapstructure:"profile"`
+	// Path to the credentials file holding the profiles. Relative paths in a
+	// profile are relative to this file. Defaults to
+	// `~/.config/packer-proxmox/credentials`, or to
+	// `$XDG_CONFIG_HOME/packer-proxmox/credentials` when set.
+	// Can also be set via the `PROXMOX_CREDENTIALS_FILE` environment variable.
+	CredentialsFile string `mapstructure:"credentials_file"`
+	profileNode     string
+	// Skip validating the certificate.
+	SkipCertValidation config.Trilean `mapstructure:"insecure_skip_tls_verify"`
+	// Path to a PEM file of the certificate authorities that issued the
+	// certificate of the API, on top of the ones trusted by the system. Use
+	// it for clusters with certificates signed by an internal CA.
+	CAFile string `mapstructure:"ca_file"`
+	// Same as `ca_file`, with the PEM encoded certificates inline.
+	CAPEM string `mapstructure:"ca_pem"`
+	// SHA-256 fingerprint of the certificate of the API, as shown by Proxmox
+	// in the certificates of the node, for example `AB:CD:...:EF`. When set,
+	// the certificate must match the fingerprint and is not validated
+	// otherwise, which allows self-signed certificates to be used safely.
+	TLSFingerprint string `mapstructure:"tls_fingerprint"`
+	// Path to a PEM encoded client certificate, presented to a reverse proxy
+	// in front of the API requiring mutual TLS. Requires `client_key_file`.
+	ClientCertFile string `mapstructure:"client_cert_file"`
+	// Path to the PEM encoded private key of `client_cert_file`.
+	ClientKeyFile string `mapstructure:"client_key_file"`
+	// URL of the proxy to reach the API through, for example
+	// `http://proxy.example.com:3128`. Defaults to the proxy set by the
+	// `HTTPS_PROXY`, `HTTP_PROXY` and `NO_PROXY` environment variables.
+	APIProxyURL string `mapstructure:"api_proxy_url"`
+	// Headers added to every request to the API, uploads included, for
+	// example for a gateway in front of it. Their values are hidden from the
+	// logs.
+	APIExtraHeaders map[string]string `mapstructure:"api_extra_headers"`
+	// Username when authenticating to Proxmox, including
+	// the realm. For example `user@pve` to use the local Proxmox realm. When using
+	// token authentication, the username must include the token id after an exclamation
+	// mark. For example, `user@pve!tokenid`.
+	// Can also be set via the `PROXMOX_USERNAME` environment variable.
+	Username string `mapstructure:"username"`
+	// Password for the user.
+	// For API tokens please use `token`.
+	// Can also be set via the `PROXMOX_PASSWORD` environment variable.
+	// Either `password` or `token` must be specifed. If both are set,
+	// `token` takes precedence.
+	Password string `mapstructure:"password"`
+	// Token for authenticating API calls.
+	// This allows the API client to work with API tokens instead of user passwords.
+	// Can also be set via the `PROXMOX_TOKEN` environment variable.
+	// Either `password` or `token` must be specifed. If both are set,
+	// `token` takes precedence.
+	Token string `mapstructure:"token"`
+	// One-time code of the two-factor authentication of the user, when
+	// using a password. The code is only used once, the ticket obtained
+	// with it is renewed for the whole build.
+	OTP string `mapstructure:"otp"`
+	// Base32 encoded secret of the TOTP two-factor authentication of the
+	// user, as shown by Proxmox when enrolling it. Codes are generated
+	// whenever the plugin logs in, unlike with `otp`.
+	// Can also be set via the `PROXMOX_TOTP_SECRET` environment variable.
+	TOTPSecret string `mapstructure:"totp_secret"`
+	// `task_timeout` (duration string | ex: "10m") - The timeout for
+	//  Promox API operations, e.g. clones. Defaults to 1 minute.
+	TaskTimeout time.Duration `mapstructure:"task_timeout"`
+	// The timeout for the Proxmox tasks copying guest data, such as
+	// restores, backups, disk imports and uploads. Defaults to 1 hour.
+	TransferTimeout time.Duration `mapstructure:"transfer_timeout"`
+	// Retry policy of the API calls that fail because of a transient error
+	// or a locked guest. See [API Retry](#api-retry).
+	APIRetry RetryConfig `mapstructure:"api_retry"`
+}
+
+// Prepare reads the connection settings that are not set from the profile and
+// the environment, and validates them.
+func (c *ClientConfig) Prepare() []error {
+	if c.Profile == "" {
+		c.Profile = os.Getenv("PROXMOX_PROFILE")
+	}
+	if c.CredentialsFile == "" {
+		c.CredentialsFile = os.Getenv("PROXMOX_CREDENTIALS_FILE")
+	}
+	if c.Profile != "" {
+		log.Printf("reading connection settings from profile %q", c.Profile)
+		if err := c.loadProfile(); err != nil {
+			return []error{err}
+		}
+	}
+	if c.ProxmoxURLRaw == "" {
+		c.ProxmoxURLRaw = os.Getenv("PROXMOX_URL")
+	}
+	if c.Username == "" {
+		c.Username = os.Getenv("PROXMOX_USERNAME")
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv("PROXMOX_PASSWORD")
 	}
+	if c.Token == "" {
+		c.Token = os.Getenv("PROXMOX_TOKEN")
+	}
+	if c.TOTPSecret == "" {
+		c.TOTPSecret = os.Getenv("PROXMOX_TOTP_SECRET")
+	}
+	return c.prepare()
+}
+
+// PrepareWithoutEnvironment validates the connection settings as they are
+// set, for connections to another cluster than the one of the environment.
+// Neither the PROXMOX_* environment variables nor the profile they point to
+// are read, only a profile set explicitly.
+func (c *ClientConfig) PrepareWithoutEnvironment() []error {
+	if c.Profile != "" {
+		log.Printf("reading connection settings from profile %q", c.Profile)
+		if err := c.loadProfile(); err != nil {
+			return []error{err}
+		}
+	}
+	return c.prepare()
+}
+
+func (c *ClientConfig) prepare() []error {
+	var errs []error
 
-	client, err := proxmox.NewClient(strings.TrimSuffix(config.proxmoxURL.String(), "/"), nil, "", tlsConfig, "", int(config.TaskTimeout.Seconds()))
+	if c.TaskTimeout == 0 {
+		c.TaskTimeout = 60 * time.Second
+	}
+	if c.TransferTimeout == 0 {
+		c.TransferTimeout = time.Hour
+	}
+	packersdk.LogSecretFilter.Set(c.Password, c.Token, c.OTP, c.TOTPSecret)
+	for _, v := range c.APIExtraHeaders {
+		packersdk.LogSecretFilter.Set(v)
+	}
+	errs = append(errs, c.APIRetry.Prepare()...)
+
+	// Required configurations that will display errors if not set
+	if c.Username == "" {
+		errs = append(errs, errors.New("username must be specified"))
+	}
+	if c.Password == "" && c.Token == "" {
+		errs = append(errs, errors.New("password or token must be specified"))
+	}
+	if c.ProxmoxURLRaw == "" {
+		errs = append(errs, errors.New("proxmox_url must be specified"))
+	}
+	var err error
+	if c.proxmoxURL, err = url.Parse(c.ProxmoxURLRaw); err != nil {
+		errs = append(errs, fmt.Errorf("could not parse proxmox_url: %s", err))
+	}
+	if c.CAFile != "" && c.CAPEM != "" {
+		errs = append(errs, errors.New("only one of ca_file and ca_pem can be set"))
+	}
+	if c.OTP != "" && c.TOTPSecret != "" {
+		errs = append(errs, errors.New("only one of otp and totp_secret can be set"))
+	}
+	if c.TOTPSecret != "" {
+		if _, err := decodeTOTPSecret(c.TOTPSecret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.APIProxyURL != "" {
+		if u, err := url.Parse(c.APIProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("api_proxy_url must be a URL such as http://proxy.example.com:3128, got %q", c.APIProxyURL))
+		}
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		errs = append(errs, errors.New("client_cert_file and client_key_file must be set together"))
+	}
+	if len(errs) == 0 {
+		if _, err := c.tlsConfig(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// NewClient returns a client for the Proxmox API, authenticated with either
+// the token or the password.
+func (c *ClientConfig) NewClient(debug bool) (*Client, error) {
+	tlsConfig, err := c.tlsConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	*proxmox.Debug = config.PackerDebug
-
-	if config.Token != "" {
-		// configure token auth
+	transport := &http.Transport{
+		TLSClientConfig:    tlsConfig,
+		DisableCompression: true,
+		Proxy:              http.ProxyFromEnvironment,
+	}
+	if c.APIProxyURL != "" {
+		proxyURL, err := url.Parse(c.APIProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse api_proxy_url: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	apiURL := strings.TrimSuffix(c.proxmoxURL.String(), "/")
+	var auth http.RoundTripper = &headerTransport{headers: c.APIExtraHeaders, base: transport}
+	var tickets *ticketTransport
+	if c.Token != "" {
 		log.Print("using token auth")
-		client.SetAPIToken(config.Username, config.Token)
+		auth = &headerTransport{
+			headers: map[string]string{"Authorization": fmt.Sprintf("PVEAPIToken=%s=%s", c.Username, c.Token)},
+			base:    auth,
+		}
 	} else {
-		// fallback to login if not using tokens
+		// Tickets are handled below proxmox-api-go, which can neither
+		// complete two-factor authentication nor renew them
 		log.Print("using password auth")
-		err = client.Login(config.Username, config.Password, "")
+		tickets, err = newTicketTransport(auth, apiURL, c)
 		if err != nil {
 			return nil, err
 		}
+		auth = tickets
+	}
+
+	httpClient := &http.Client{Transport: auth}
+	client, err := proxmox.NewClient(apiURL, httpClient, "", tlsConfig, "", int(c.TaskTimeout.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	*proxmox.Debug = debug
+
+	if tickets == nil {
+		client.SetAPIToken(c.Username, c.Token)
+	} else {
+		// Log in right away, so that wrong credentials are reported first
+		tickets.mu.Lock()
+		err = tickets.refresh(false)
+		tickets.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		client.Username = c.Username
+	}
+
+	return &Client{Client: client, retry: c.APIRetry, http: httpClient, apiURL: apiURL}, nil
+}
+
+// tlsConfig returns the TLS settings of the connection to the API.
+func (c *ClientConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.SkipCertValidation.True(),
+	}
+
+	caPEM := []byte(c.CAPEM)
+	if c.CAFile != "" {
+		var err error
+		caPEM, err = os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca_file: %s", err)
+		}
+	}
+	if len(caPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			log.Printf("could not load the system certificate authorities, only trusting the configured ones: %s", err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificate found in ca_file or ca_pem")
+		}
+		tlsConfig.RootCAs = pool
 	}
 
-	return client, nil
+	if c.TLSFingerprint != "" {
+		fingerprint, err := hex.DecodeString(strings.ReplaceAll(c.TLSFingerprint, ":", ""))
+		if err != nil || len(fingerprint) != sha256.Size {
+			return nil, fmt.Errorf("tls_fingerprint must be a SHA-256 fingerprint, such as AB:CD:...:EF, got %q", c.TLSFingerprint)
+		}
+		// The fingerprint replaces the validation of the certificate chain
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no certificate presented by the server")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], fingerprint) {
+				return fmt.Errorf("certificate fingerprint %s does not match tls_fingerprint", formatFingerprint(sum[:]))
+			}
+			return nil
+		}
+	}
+
+	if c.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client_cert_file and client_key_file: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// formatFingerprint formats a fingerprint the way Proxmox shows it.
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
 }
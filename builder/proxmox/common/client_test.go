@@ -4,12 +4,27 @@
 package proxmox
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/stretchr/testify/require"
@@ -25,15 +40,14 @@ func TestTokenAuth(t *testing.T) {
 	defer mockAPI.Close()
 
 	pmURL, _ := url.Parse(mockAPI.URL)
-	config := Config{
-		proxmoxURL:         pmURL,
-		SkipCertValidation: false,
-		Username:           "dummy@vmhost!test-token",
-		Password:           "not-used",
-		Token:              "ac5293bf-15e2-477f-b04c-a6dfa7a46b80",
+	config := ClientConfig{
+		proxmoxURL: pmURL,
+		Username:   "dummy@vmhost!test-token",
+		Password:   "not-used",
+		Token:      "ac5293bf-15e2-477f-b04c-a6dfa7a46b80",
 	}
 
-	client, err := newProxmoxClient(config)
+	client, err := config.NewClient(false)
 	require.NoError(t, err)
 
 	ref := proxmox.NewVmRef(110)
@@ -75,15 +89,14 @@ func TestLogin(t *testing.T) {
 	defer mockAPI.Close()
 
 	pmURL, _ := url.Parse(mockAPI.URL)
-	config := Config{
-		proxmoxURL:         pmURL,
-		SkipCertValidation: false,
-		Username:           "dummy@vmhost",
-		Password:           "correct-horse-battery-staple",
-		Token:              "",
+	config := ClientConfig{
+		proxmoxURL: pmURL,
+		Username:   "dummy@vmhost",
+		Password:   "correct-horse-battery-staple",
+		Token:      "",
 	}
 
-	client, err := newProxmoxClient(config)
+	client, err := config.NewClient(false)
 	require.NoError(t, err)
 
 	ref := proxmox.NewVmRef(110)
@@ -92,3 +105,216 @@ func TestLogin(t *testing.T) {
 	err = client.Sendkey(ref, "ping")
 	require.NoError(t, err)
 }
+
+// writeClientCert writes a self-signed client certificate and its key to
+// dir, and returns their paths and the certificate.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "packer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile, cert
+}
+
+func TestTLSOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	mockAPI := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	mockAPI.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.VerifyClientCertIfGiven}
+	mockAPI.StartTLS()
+	defer mockAPI.Close()
+
+	serverPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockAPI.Certificate().Raw}))
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte(serverPEM), 0600))
+	sum := sha256.Sum256(mockAPI.Certificate().Raw)
+	fingerprint := formatFingerprint(sum[:])
+
+	cs := []struct {
+		name          string
+		config        ClientConfig
+		requireMTLS   bool
+		expectedError string
+	}{
+		{
+			name:          "unknown CA",
+			config:        ClientConfig{},
+			expectedError: "certificate",
+		},
+		{
+			name:   "ca_file",
+			config: ClientConfig{CAFile: caFile},
+		},
+		{
+			name:   "ca_pem",
+			config: ClientConfig{CAPEM: serverPEM},
+		},
+		{
+			name:   "tls_fingerprint",
+			config: ClientConfig{TLSFingerprint: fingerprint},
+		},
+		{
+			name:   "tls_fingerprint in lower case without colons",
+			config: ClientConfig{TLSFingerprint: fmt.Sprintf("%x", sum)},
+		},
+		{
+			name:          "tls_fingerprint mismatch",
+			config:        ClientConfig{TLSFingerprint: strings.Repeat("AB:", 31) + "AB"},
+			expectedError: "does not match tls_fingerprint",
+		},
+		{
+			name:          "client certificate required",
+			config:        ClientConfig{CAPEM: serverPEM},
+			requireMTLS:   true,
+			expectedError: "certificate required",
+		},
+		{
+			name:        "client certificate",
+			config:      ClientConfig{CAPEM: serverPEM, ClientCertFile: certFile, ClientKeyFile: keyFile},
+			requireMTLS: true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			if c.requireMTLS {
+				mockAPI.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+			} else {
+				mockAPI.TLS.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			mockAPI.CloseClientConnections()
+
+			config := c.config
+			config.proxmoxURL, _ = url.Parse(mockAPI.URL)
+			config.Username = "dummy@vmhost!test-token"
+			config.Token = "ac5293bf-15e2-477f-b04c-a6dfa7a46b80"
+			client, err := config.NewClient(false)
+			require.NoError(t, err)
+
+			ref := proxmox.NewVmRef(110)
+			ref.SetNode("node1")
+			ref.SetVmType("qemu")
+			err = client.Sendkey(ref, "ping")
+			if c.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, c.expectedError)
+			}
+		})
+	}
+}
+
+func TestClientConfigValidation(t *testing.T) {
+	cs := []struct {
+		name          string
+		config        ClientConfig
+		expectedError string
+	}{
+		{
+			name:          "both ca_file and ca_pem",
+			config:        ClientConfig{CAFile: "ca.pem", CAPEM: "pem"},
+			expectedError: "only one of ca_file and ca_pem can be set",
+		},
+		{
+			name:          "ca_pem without certificate",
+			config:        ClientConfig{CAPEM: "not a certificate"},
+			expectedError: "no certificate found in ca_file or ca_pem",
+		},
+		{
+			name:          "invalid fingerprint",
+			config:        ClientConfig{TLSFingerprint: "AB:CD"},
+			expectedError: "tls_fingerprint must be a SHA-256 fingerprint",
+		},
+		{
+			name:          "api_proxy_url without scheme",
+			config:        ClientConfig{APIProxyURL: "proxy.example.com:3128"},
+			expectedError: "api_proxy_url must be a URL",
+		},
+		{
+			name:          "client certificate without key",
+			config:        ClientConfig{ClientCertFile: "client.crt"},
+			expectedError: "client_cert_file and client_key_file must be set together",
+		},
+		{
+			name:          "both otp and totp_secret",
+			config:        ClientConfig{OTP: "123456", TOTPSecret: "GEZDGNBVGY3TQOJQ"},
+			expectedError: "only one of otp and totp_secret can be set",
+		},
+		{
+			name:          "invalid totp_secret",
+			config:        ClientConfig{TOTPSecret: "not base32!"},
+			expectedError: "totp_secret must be a base32 encoded secret",
+		},
+	}
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			config := c.config
+			config.ProxmoxURLRaw = "https://pve:8006/api2/json"
+			config.Username = "root@pam"
+			config.Password = "secret"
+			errs := config.Prepare()
+			require.Len(t, errs, 1)
+			require.ErrorContains(t, errs[0], c.expectedError)
+		})
+	}
+}
+
+func TestProxyAndExtraHeaders(t *testing.T) {
+	var requests []*http.Request
+	mockProxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req)
+		switch req.URL.Path {
+		case "/api2/json/nodes/pve/storage/local/upload":
+			_, _ = rw.Write([]byte(`{"data":"UPID:pve:1:2:3:imgcopy::root@pam:"}`))
+		case "/api2/json/nodes/pve/tasks/UPID:pve:1:2:3:imgcopy::root@pam:/status":
+			_, _ = rw.Write([]byte(`{"data":{"status":"stopped","exitstatus":"OK"}}`))
+		}
+	}))
+	defer mockProxy.Close()
+
+	config := ClientConfig{
+		ProxmoxURLRaw:   "http://pve.example.com:8006/api2/json",
+		Username:        "dummy@vmhost!test-token",
+		Token:           "ac5293bf-15e2-477f-b04c-a6dfa7a46b80",
+		APIProxyURL:     mockProxy.URL,
+		APIExtraHeaders: map[string]string{"X-Gateway-Token": "secret"},
+	}
+	require.Empty(t, config.Prepare())
+	client, err := config.NewClient(false)
+	require.NoError(t, err)
+
+	ref := proxmox.NewVmRef(110)
+	ref.SetNode("pve")
+	ref.SetVmType("qemu")
+	require.NoError(t, client.Sendkey(ref, "ping"))
+	upid, err := client.Upload(context.TODO(), "pve", "local", "iso", "test.iso", strings.NewReader("data"))
+	require.NoError(t, err)
+	require.Equal(t, "UPID:pve:1:2:3:imgcopy::root@pam:", upid)
+
+	require.Len(t, requests, 2)
+	for _, req := range requests {
+		// Requests sent through a proxy have an absolute URL
+		require.Equal(t, "pve.example.com:8006", req.Host)
+		require.Equal(t, "secret", req.Header.Get("X-Gateway-Token"))
+		require.Equal(t, "PVEAPIToken=dummy@vmhost!test-token=ac5293bf-15e2-477f-b04c-a6dfa7a46b80", req.Header.Get("Authorization"))
+	}
+}
@@ -2,7 +2,7 @@
 // SPDX-License-Identifier: MPL-2.0
 
 //go:generate packer-sdc struct-markdown
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,NICConfig,diskConfig,rng0Config,pciDeviceConfig,vgaConfig,ISOsConfig,efiConfig,tpmConfig
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,RetryConfig,NICConfig,diskConfig,rng0Config,pciDeviceConfig,vgaConfig,templateHardwareConfig,ISOsConfig,efiConfig,tpmConfig
 
 package proxmox
 
@@ -10,13 +10,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -49,49 +49,28 @@ type Config struct {
 	bootcommand.BootConfig `mapstructure:",squash"`
 	BootKeyInterval        time.Duration       `mapstructure:"boot_key_interval"`
 	Comm                   communicator.Config `mapstructure:",squash"`
+	ClientConfig           `mapstructure:",squash"`
 
-	// URL to the Proxmox API, including the full path,
-	// so `https://<server>:<port>/api2/json` for example.
-	// Can also be set via the `PROXMOX_URL` environment variable.
-	ProxmoxURLRaw string `mapstructure:"proxmox_url"`
-	proxmoxURL    *url.URL
-	// Skip validating the certificate.
-	SkipCertValidation bool `mapstructure:"insecure_skip_tls_verify"`
-	// Username when authenticating to Proxmox, including
-	// the realm. For example `user@pve` to use the local Proxmox realm. When using
-	// token authentication, the username must include the token id after an exclamation
-	// mark. For example, `user@pve!tokenid`.
-	// Can also be set via the `PROXMOX_USERNAME` environment variable.
-	Username string `mapstructure:"username"`
-	// Password for the user.
-	// For API tokens please use `token`.
-	// Can also be set via the `PROXMOX_PASSWORD` environment variable.
-	// Either `password` or `token` must be specifed. If both are set,
-	// `token` takes precedence.
-	Password string `mapstructure:"password"`
-	// Token for authenticating API calls.
-	// This allows the API client to work with API tokens instead of user passwords.
-	// Can also be set via the `PROXMOX_TOKEN` environment variable.
-	// Either `password` or `token` must be specifed. If both are set,
-	// `token` takes precedence.
-	Token string `mapstructure:"token"`
 	// Which node in the Proxmox cluster to start the virtual
-	// machine on during creation.
+	// machine on during creation. Defaults to the node of the `profile`.
 	Node string `mapstructure:"node"`
 	// Name of resource pool to create virtual machine in.
 	Pool string `mapstructure:"pool"`
-	// `task_timeout` (duration string | ex: "10m") - The timeout for
-	//  Promox API operations, e.g. clones. Defaults to 1 minute.
-	TaskTimeout time.Duration `mapstructure:"task_timeout"`
 
 	// Name of the virtual machine during creation. If not
 	// given, a random uuid will be used.
 	VMName string `mapstructure:"vm_name"`
 	// `vm_id` (int) - The ID used to reference the virtual machine. This will
-	// also be the ID of the final template. Proxmox VMIDs are unique cluster-wide
+	// also be the ID of the final template, unless it is outside of
+	// `template_id_range`. Proxmox VMIDs are unique cluster-wide
 	// and are limited to the range 100-999999999.
 	// If not given, the next free ID on the cluster will be used.
 	VMID int `mapstructure:"vm_id"`
+	// Range of VMIDs to create the virtual machine with, for example
+	// `90000-90999`. The first free ID of the range is used, instead of the
+	// next free ID on the cluster. Conflicts with `vm_id`.
+	VMIDRange string `mapstructure:"vm_id_range"`
+	vmIDRange idRange
 
 	// The tags to set. This is a semicolon separated list. For example,
 	// `debian-12;template`.
@@ -171,13 +150,113 @@ type Config struct {
 	Onboot bool `mapstructure:"onboot"`
 	// Disables KVM hardware virtualization. Defaults to `false`.
 	DisableKVM bool `mapstructure:"disable_kvm"`
+	// Skip checking, before anything is created, that the configured user or
+	// token has the privileges the build needs. Defaults to `false`.
+	SkipPermissionCheck bool `mapstructure:"skip_permission_check"`
+
+	// Command run through the communicator to stop the virtual machine once
+	// provisioning is done, instead of the shutdown through the Proxmox API.
+	// For example `sudo poweroff`, or
+	// `C:\Windows\System32\Sysprep\sysprep.exe /generalize /oobe /shutdown`.
+	// Without it, the virtual machine is shut down through the Proxmox API,
+	// and stopped when that fails.
+	ShutdownCommand string `mapstructure:"shutdown_command"`
+	// How long to wait for the virtual machine to stop after
+	// `shutdown_command`, for example `15m`. The virtual machine is then
+	// shut down through the Proxmox API, and stopped when that fails.
+	// Defaults to `5m`.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// What the build produces. `template`, the default, converts the
+	// virtual machine into a template. `vm` leaves it as a stopped virtual
+	// machine. `snapshot` takes the snapshot `snapshot_name` of the existing
+	// virtual machine `vm_id`, which is started for the build instead of
+	// being created, and left in the power state it was found in. The
+	// virtual machine is never deleted in `snapshot` mode, and `-force`
+	// replaces a snapshot of the same name.
+	OutputMode string `mapstructure:"output_mode"`
+	// Name of the snapshot taken with `output_mode = "snapshot"`.
+	SnapshotName string `mapstructure:"snapshot_name"`
 
 	// Name of the template. Defaults to the generated
 	// name used during creation.
 	TemplateName string `mapstructure:"template_name"`
+	// Range of VMIDs of the template, for example `9000-9099`. When the ID of
+	// the virtual machine is outside of it, the stopped virtual machine is
+	// cloned to the first free ID of the range and deleted, and the clone is
+	// converted to a template.
+	TemplateIDRange string `mapstructure:"template_id_range"`
+	templateIDRange idRange
+	// What `-force` does with an existing template of the same `vm_id`, or
+	// of the same `template_name` when `vm_id` is not set. `delete`, the
+	// default, deletes it before the build starts. `replace` builds the new
+	// template under another ID, and deletes the existing template only
+	// once the new one is converted and finalized, so that a failed build
+	// leaves it in place.
+	ForceMode string `mapstructure:"force_mode"`
+	// With `force_mode = "replace"`, keep the replaced template, renamed to
+	// `<name>-replaced-<timestamp>`, instead of deleting it.
+	ForceKeepReplaced bool `mapstructure:"force_keep_replaced"`
+	// With `force_mode = "replace"`, give the new template the ID of the
+	// replaced one, by cloning it to that ID. A kept replaced template is
+	// moved to a free ID the same way. Always enabled when `vm_id` is set.
+	ForceKeepVMID bool `mapstructure:"force_keep_vm_id"`
+	// Version of the template, for example `1.4.2`. It is appended to
+	// `template_name`, which is required, as `<template_name>-<version>`,
+	// and added to the tags as `version-<version>`. The templates named
+	// `<template_name>-<version>` and tagged with that same version form the
+	// lineage of the template, pruned by `retain_versions`.
+	TemplateVersion string `mapstructure:"template_version"`
+	// Base of the VMID of the versioned template. When set,
+	// `template_version` must be a number, and `vm_id` defaults to
+	// `template_version_id_base + template_version`, so version `42` of a
+	// base of `9000` is built as `9042`. Conflicts with `vm_id`,
+	// `vm_id_range` and `template_id_range`.
+	TemplateVersionIDBase int `mapstructure:"template_version_id_base"`
+	// Number of templates of the lineage to keep, the new one included.
+	// Once the template is built, older versions beyond that number are
+	// deleted, from the oldest, except for the ones still used by linked
+	// clones. Defaults to `0`, which keeps all of them.
+	RetainVersions int `mapstructure:"retain_versions"`
+	// template_name without the version
+	templateLineage string
+
 	// Description of the template, visible in
-	// the Proxmox interface.
+	// the Proxmox interface, or of the snapshot with `output_mode =
+	// "snapshot"`. It is a Go template rendered once the template is built,
+	// with the following data:
+	//
+	//   - `BuildName` and `BuilderType`, the name of the build and the type
+	//     of the builder.
+	//   - `Timestamp`, the time the template was finalized, in RFC 3339
+	//     format.
+	//   - `TemplateName` and `TemplateVersion`.
+	//   - `SourceISOChecksum`, the checksum of the boot ISO of
+	//     `proxmox-iso`.
+	//   - `CloneSourceVMID`, the VMID of the source of `proxmox-clone`.
+	//   - `Provisioners`, from `template_provisioners`.
+	//   - `Metadata`, from `template_metadata`.
+	//
+	// For example `Built by {{ .BuildName }} from {{ index .Metadata "git_sha" }} on {{ .Timestamp }}`.
 	TemplateDescription string `mapstructure:"template_description"`
+	// Append the data of `template_description` to the description as a
+	// JSON code block under a `packer` key, so that other tools can trace
+	// the template back to its build. Defaults to `false`.
+	TemplateDescriptionJSON bool `mapstructure:"template_description_json"`
+	// Build data recorded with the template, such as the git SHA of the
+	// template sources. For example `{ git_sha = var.git_sha }`.
+	TemplateMetadata map[string]string `mapstructure:"template_metadata"`
+	// Provisioners recorded with the template. Packer does not tell
+	// builders which provisioners run, list them here, for example
+	// `["shell", "ansible"]`.
+	TemplateProvisioners []string `mapstructure:"template_provisioners"`
+	// Tags added to the template once it is built, on top of `tags` which
+	// are set on the build VM. This is a semicolon separated list.
+	TemplateTags string `mapstructure:"template_tags"`
+	// Hardware of the template, applied once provisioning is done, when it
+	// differs from the hardware of the build. See [Template
+	// Config](#template-config). Not supported by `proxmox-lxc`.
+	TemplateConfig templateHardwareConfig `mapstructure:"template_config"`
 
 	// If true, add an empty Cloud-Init CDROM drive after the virtual
 	// machine has been converted to a template. Defaults to `false`.
@@ -378,7 +457,8 @@ type diskConfig struct {
 	// rather than a rotational disk.
 	//
 	// This cannot work with virtio disks.
-	SSD bool `mapstructure:"ssd"`
+	SSD                 bool   `mapstructure:"ssd"`
+	AssignedDeviceIndex string `mapstructure-to-hcl2:",skip"`
 }
 
 // Set the efidisk storage options.
@@ -504,6 +584,49 @@ type vgaConfig struct {
 	Memory int `mapstructure:"memory"`
 }
 
+// Hardware settings of the template, replacing the ones of the build VM once
+// provisioning is done, such as a build VLAN or the memory used to speed up
+// the installation. Settings left unset are kept, and disks are left alone.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	template_config {
+//	  memory = 2048
+//	  cores  = 2
+//	  network_adapters {
+//	    model  = "virtio"
+//	    bridge = "vmbr0"
+//	  }
+//	  onboot = true
+//	}
+//
+// ```
+type templateHardwareConfig struct {
+	// How much memory (in megabytes) to give to the template.
+	Memory int `mapstructure:"memory"`
+	// How many CPU cores to give to the template.
+	Cores int `mapstructure:"cores"`
+	// The network adapters of the template, replacing all the adapters of
+	// the build VM. Adapters without `mac_address` keep the MAC address of
+	// the build VM adapter at the same index. See [Network
+	// Adapters](#network-adapters).
+	NICs []NICConfig `mapstructure:"network_adapters"`
+	// The graphics adapter of the template. See [VGA Config](#vga-config).
+	VGA vgaConfig `mapstructure:"vga"`
+	// The serial ports of the template, replacing all the serial ports of
+	// the build VM. See `serials`.
+	Serials []string `mapstructure:"serials"`
+	// Whether clones of the template are started during system bootup.
+	Onboot config.Trilean `mapstructure:"onboot"`
+	// The tags of the template, replacing `tags`. This is a semicolon
+	// separated list.
+	Tags string `mapstructure:"tags"`
+	// The boot order of the template. See `boot`.
+	Boot string `mapstructure:"boot"`
+}
+
 // Allows passing through a host PCI device into the VM. For example, a graphics card
 // or a network adapter. Devices that are mapped into a guest VM are no longer available
 // on the host. A minimal configuration only requires either the `host` or the `mapping`
@@ -590,6 +713,8 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				// Rendered with the build data once the template is built
+				"template_description",
 			},
 		},
 	}, raws...)
@@ -609,24 +734,9 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		c.Agent = config.TriTrue
 	}
 
-	packersdk.LogSecretFilter.Set(c.Password)
+	errs = packersdk.MultiErrorAppend(errs, c.ClientConfig.Prepare()...)
 
 	// Defaults
-	if c.ProxmoxURLRaw == "" {
-		c.ProxmoxURLRaw = os.Getenv("PROXMOX_URL")
-	}
-	if c.Username == "" {
-		c.Username = os.Getenv("PROXMOX_USERNAME")
-	}
-	if c.Password == "" {
-		c.Password = os.Getenv("PROXMOX_PASSWORD")
-	}
-	if c.Token == "" {
-		c.Token = os.Getenv("PROXMOX_TOKEN")
-	}
-	if c.TaskTimeout == 0 {
-		c.TaskTimeout = 60 * time.Second
-	}
 	if c.BootKeyInterval == 0 && os.Getenv(bootcommand.PackerKeyEnv) != "" {
 		var err error
 		c.BootKeyInterval, err = time.ParseDuration(os.Getenv(bootcommand.PackerKeyEnv))
@@ -638,12 +748,99 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		c.BootKeyInterval = 5 * time.Millisecond
 	}
 
+	if c.TemplateVersion != "" {
+		if c.TemplateName == "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("template_name must be specified with template_version"))
+		}
+		c.templateLineage = c.TemplateName
+		c.TemplateName = fmt.Sprintf("%s-%s", c.TemplateName, c.TemplateVersion)
+		c.Tags = strings.Trim(c.Tags+";version-"+strings.ToLower(c.TemplateVersion), ";")
+	}
+	if c.TemplateVersionIDBase != 0 {
+		if c.VMID != 0 || c.VMIDRange != "" || c.TemplateIDRange != "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("template_version_id_base cannot be set with vm_id, vm_id_range or template_id_range"))
+		}
+		version, err := strconv.Atoi(c.TemplateVersion)
+		id := c.TemplateVersionIDBase + version
+		switch {
+		case err != nil || version < 0:
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("template_version must be a number with template_version_id_base, got %q", c.TemplateVersion))
+		case id < 100 || id > 999999999:
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("template_version_id_base + template_version must be in range 100-999999999, got %d", id))
+		default:
+			c.VMID = id
+		}
+	}
+	if c.RetainVersions < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("retain_versions must not be negative"))
+	}
+	if c.RetainVersions > 0 && c.TemplateVersion == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("retain_versions requires template_version"))
+	}
+
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = 5 * time.Minute
+	}
+	if c.ShutdownCommand != "" && c.Comm.Type == "none" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("shutdown_command requires a communicator"))
+	}
+
+	switch c.OutputMode {
+	case "":
+		c.OutputMode = "template"
+	case "template", "vm", "snapshot":
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("output_mode must be one of template, vm or snapshot, got %q", c.OutputMode))
+	}
+	if c.OutputMode != "template" && (c.ForceMode == "replace" || c.TemplateIDRange != "" || c.RetainVersions > 0) {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(`force_mode = "replace", template_id_range and retain_versions require output_mode = "template"`))
+	}
+	if c.OutputMode == "snapshot" {
+		if c.VMID == 0 {
+			errs = packersdk.MultiErrorAppend(errs, errors.New(`vm_id must be specified with output_mode = "snapshot"`))
+		}
+		if err := proxmox.SnapshotName(c.SnapshotName).Validate(); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("snapshot_name: %s", err))
+		}
+		if !c.TemplateConfig.isEmpty() {
+			errs = packersdk.MultiErrorAppend(errs, errors.New(`template_config cannot be used with output_mode = "snapshot"`))
+		}
+	} else if c.SnapshotName != "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(`snapshot_name requires output_mode = "snapshot"`))
+	}
+
 	// Technically Proxmox VMIDs are unsigned 32bit integers, but are limited to
 	// the range 100-999999999. Source:
 	// https://pve-devel.pve.proxmox.narkive.com/Pa6mH1OP/avoiding-vmid-reuse#post8
 	if c.VMID != 0 && (c.VMID < 100 || c.VMID > 999999999) {
 		errs = packersdk.MultiErrorAppend(errs, errors.New("vm_id must be in range 100-999999999"))
 	}
+	if c.VMIDRange != "" {
+		if c.VMID != 0 {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("vm_id and vm_id_range cannot both be set"))
+		}
+		if c.vmIDRange, err = parseIDRange("vm_id_range", c.VMIDRange); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+	switch c.ForceMode {
+	case "":
+		c.ForceMode = "delete"
+	case "delete", "replace":
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("force_mode must be one of delete or replace, got %q", c.ForceMode))
+	}
+	if c.ForceMode == "replace" && c.VMID != 0 {
+		c.ForceKeepVMID = true
+	}
+	if c.ForceMode != "replace" && (c.ForceKeepReplaced || c.ForceKeepVMID) {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(`force_keep_replaced and force_keep_vm_id require force_mode = "replace"`))
+	}
+	if c.TemplateIDRange != "" {
+		if c.templateIDRange, err = parseIDRange("template_id_range", c.TemplateIDRange); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
 	if c.VMName == "" {
 		// Default to packer-[time-ordered-uuid]
 		c.VMName = fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
@@ -819,15 +1016,7 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		}
 	}
 
-	if len(c.Serials) > 4 {
-		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("too many serials: %d serials defined, but proxmox accepts 4 elements maximum", len(c.Serials)))
-	}
-	res := regexp.MustCompile(`^(/dev/.+|socket)$`)
-	for _, serial := range c.Serials {
-		if !res.MatchString(serial) {
-			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("serials must respond to pattern \"/dev/.+\" or be \"socket\". It was \"%s\"", serial))
-		}
-	}
+	errs = packersdk.MultiErrorAppend(errs, prepareSerials("serials", c.Serials)...)
 	if c.SCSIController == "" {
 		log.Printf("SCSI controller not set, using default 'lsi'")
 		c.SCSIController = "lsi"
@@ -843,22 +1032,24 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		}
 	}
 
-	errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.Ctx)...)
+	// The pct communicator runs commands through `pct exec` over an SSH
+	// connection to the Proxmox node, so its settings are validated as SSH.
+	if c.Comm.Type == "pct" {
+		if c.Ctx.BuildType != "proxmox-lxc" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("communicator \"pct\" is only supported by the proxmox-lxc builder"))
+		}
+		c.Comm.Type = "ssh"
+		errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.Ctx)...)
+		c.Comm.Type = "pct"
+	} else {
+		errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.Ctx)...)
+	}
 	errs = packersdk.MultiErrorAppend(errs, c.BootConfig.Prepare(&c.Ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.Ctx)...)
 
 	// Required configurations that will display errors if not set
-	if c.Username == "" {
-		errs = packersdk.MultiErrorAppend(errs, errors.New("username must be specified"))
-	}
-	if c.Password == "" && c.Token == "" {
-		errs = packersdk.MultiErrorAppend(errs, errors.New("password or token must be specified"))
-	}
-	if c.ProxmoxURLRaw == "" {
-		errs = packersdk.MultiErrorAppend(errs, errors.New("proxmox_url must be specified"))
-	}
-	if c.proxmoxURL, err = url.Parse(c.ProxmoxURLRaw); err != nil {
-		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("could not parse proxmox_url: %s", err))
+	if c.Node == "" {
+		c.Node = c.DefaultNode()
 	}
 	if c.Node == "" {
 		errs = packersdk.MultiErrorAppend(errs, errors.New("node must be specified"))
@@ -870,23 +1061,14 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		errs = packersdk.MultiErrorAppend(errs, errors.New("vm_name must be a valid DNS name"))
 	}
 	if c.TemplateName != "" && !re.MatchString(c.TemplateName) {
-		errs = packersdk.MultiErrorAppend(errs, errors.New("template_name must be a valid DNS name"))
-	}
-	for idx, nic := range c.NICs {
-		if nic.Bridge == "" {
-			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].bridge must be specified", idx))
-		}
-		if nic.Model == "" {
-			log.Printf("NIC %d model not set, using default 'e1000'", idx)
-			c.NICs[idx].Model = "e1000"
-		}
-		if nic.Model != "virtio" && nic.PacketQueues > 0 {
-			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].packet_queues can only be set for 'virtio' driver", idx))
-		}
-		if (nic.MTU < 0) || (nic.MTU > 65520) {
-			errs = packersdk.MultiErrorAppend(errs, errors.New("network_adapters[%d].mtu only positive values up to 65520 are supported"))
+		if c.TemplateVersion != "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("template_name and template_version must form a valid DNS name"))
+		} else {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("template_name must be a valid DNS name"))
 		}
 	}
+	errs = packersdk.MultiErrorAppend(errs, prepareNICs("network_adapters", c.NICs)...)
+	errs = packersdk.MultiErrorAppend(errs, c.TemplateConfig.prepare(c)...)
 	if c.EFIDisk != "" {
 		if c.EFIConfig != (efiConfig{}) {
 			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("both efi_config and efidisk cannot be set at the same time, consider defining only efi_config as efidisk is deprecated"))
@@ -965,3 +1147,69 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 	}
 	return nil, warnings, nil
 }
+
+// prepareSerials validates the serial ports of the option.
+func prepareSerials(option string, serials []string) []error {
+	var errs []error
+	if len(serials) > 4 {
+		errs = append(errs, fmt.Errorf("too many %s: %d serials defined, but proxmox accepts 4 elements maximum", option, len(serials)))
+	}
+	res := regexp.MustCompile(`^(/dev/.+|socket)$`)
+	for _, serial := range serials {
+		if !res.MatchString(serial) {
+			errs = append(errs, fmt.Errorf("%s must respond to pattern \"/dev/.+\" or be \"socket\". It was \"%s\"", option, serial))
+		}
+	}
+	return errs
+}
+
+// prepareNICs validates the network adapters of the option, and sets their
+// defaults.
+func prepareNICs(option string, nics []NICConfig) []error {
+	var errs []error
+	for idx, nic := range nics {
+		if nic.Bridge == "" {
+			errs = append(errs, fmt.Errorf("%s[%d].bridge must be specified", option, idx))
+		}
+		if nic.Model == "" {
+			log.Printf("NIC %d model not set, using default 'e1000'", idx)
+			nics[idx].Model = "e1000"
+		}
+		if nic.Model != "virtio" && nic.PacketQueues > 0 {
+			errs = append(errs, fmt.Errorf("%s[%d].packet_queues can only be set for 'virtio' driver", option, idx))
+		}
+		if (nic.MTU < 0) || (nic.MTU > 65520) {
+			errs = append(errs, fmt.Errorf("%s[%d].mtu only positive values up to 65520 are supported", option, idx))
+		}
+	}
+	return errs
+}
+
+// prepare validates template_config. The version tag of template_version is
+// added to its tags, as they replace the ones carrying it.
+func (tc *templateHardwareConfig) prepare(c *Config) []error {
+	var errs []error
+	if tc.Memory != 0 && tc.Memory < 16 {
+		errs = append(errs, errors.New("template_config.memory must be at least 16"))
+	}
+	if tc.Memory != 0 && tc.Memory < c.BalloonMinimum {
+		errs = append(errs, fmt.Errorf("ballooning_minimum (%d) must be lower than template_config.memory (%d)", c.BalloonMinimum, tc.Memory))
+	}
+	if tc.Cores < 0 {
+		errs = append(errs, errors.New("template_config.cores must be positive"))
+	}
+	errs = append(errs, prepareSerials("template_config.serials", tc.Serials)...)
+	errs = append(errs, prepareNICs("template_config.network_adapters", tc.NICs)...)
+	if tc.Tags != "" && c.TemplateVersion != "" {
+		tc.Tags = mergeTags(tc.Tags, "version-"+strings.ToLower(c.TemplateVersion))
+	}
+	if c.Ctx.BuildType == "proxmox-lxc" && !tc.isEmpty() {
+		errs = append(errs, errors.New("template_config is not supported by the proxmox-lxc builder"))
+	}
+	return errs
+}
+
+func (tc *templateHardwareConfig) isEmpty() bool {
+	return tc.Memory == 0 && tc.Cores == 0 && len(tc.NICs) == 0 && tc.VGA == (vgaConfig{}) &&
+		len(tc.Serials) == 0 && tc.Onboot == config.TriUnset && tc.Tags == "" && tc.Boot == ""
+}
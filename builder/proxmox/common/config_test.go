@@ -8,8 +8,10 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
 )
 
 func mandatoryConfig(t *testing.T) map[string]interface{} {
@@ -567,6 +569,370 @@ func TestVMID(t *testing.T) {
 	}
 }
 
+func TestVMIDRanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectedError string
+	}{
+		{
+			name:      "valid ranges",
+			overrides: map[string]interface{}{"vm_id_range": "90000-90999", "template_id_range": "9000 - 9099"},
+		},
+		{
+			name:          "vm_id and vm_id_range",
+			overrides:     map[string]interface{}{"vm_id": 90000, "vm_id_range": "90000-90999"},
+			expectedError: "vm_id and vm_id_range cannot both be set",
+		},
+		{
+			name:          "not a range",
+			overrides:     map[string]interface{}{"vm_id_range": "90000"},
+			expectedError: "vm_id_range must be a range of VMIDs",
+		},
+		{
+			name:          "descending range",
+			overrides:     map[string]interface{}{"template_id_range": "9099-9000"},
+			expectedError: "template_id_range must be an ascending range within 100-999999999",
+		},
+		{
+			name:          "range below 100",
+			overrides:     map[string]interface{}{"vm_id_range": "50-150"},
+			expectedError: "vm_id_range must be an ascending range within 100-999999999",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if tt.expectedError == "" {
+				require.NoError(t, err)
+				require.Equal(t, idRange{min: 90000, max: 90999}, c.vmIDRange)
+				require.Equal(t, idRange{min: 9000, max: 9099}, c.templateIDRange)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestForceMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectedMode  string
+		expectKeepID  bool
+		expectedError string
+	}{
+		{
+			name:         "defaults to delete",
+			expectedMode: "delete",
+		},
+		{
+			name:         "replace keeps vm_id",
+			overrides:    map[string]interface{}{"force_mode": "replace", "vm_id": 9000},
+			expectedMode: "replace",
+			expectKeepID: true,
+		},
+		{
+			name:          "unknown mode",
+			overrides:     map[string]interface{}{"force_mode": "overwrite"},
+			expectedError: "force_mode must be one of delete or replace",
+		},
+		{
+			name:          "keep options without replace",
+			overrides:     map[string]interface{}{"force_keep_replaced": true},
+			expectedError: `force_keep_replaced and force_keep_vm_id require force_mode = "replace"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedMode, c.ForceMode)
+			require.Equal(t, tt.expectKeepID, c.ForceKeepVMID)
+		})
+	}
+}
+
+func TestTemplateVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrides       map[string]interface{}
+		expectedName    string
+		expectedLineage string
+		expectedTags    string
+		expectedVMID    int
+		expectedError   string
+	}{
+		{
+			name:            "version is appended to the name and tags",
+			overrides:       map[string]interface{}{"template_name": "debian", "template_version": "1.4.2-RC1", "tags": "linux"},
+			expectedName:    "debian-1.4.2-RC1",
+			expectedLineage: "debian",
+			expectedTags:    "linux;version-1.4.2-rc1",
+		},
+		{
+			name:            "version ID base sets vm_id",
+			overrides:       map[string]interface{}{"template_name": "debian", "template_version": "42", "template_version_id_base": 9000},
+			expectedName:    "debian-42",
+			expectedLineage: "debian",
+			expectedTags:    "version-42",
+			expectedVMID:    9042,
+		},
+		{
+			name:          "version requires template_name",
+			overrides:     map[string]interface{}{"template_version": "1"},
+			expectedError: "template_name must be specified with template_version",
+		},
+		{
+			name:          "version ID base conflicts with vm_id",
+			overrides:     map[string]interface{}{"template_name": "debian", "template_version": "1", "template_version_id_base": 9000, "vm_id": 9001},
+			expectedError: "template_version_id_base cannot be set with vm_id, vm_id_range or template_id_range",
+		},
+		{
+			name:          "version ID base requires a numeric version",
+			overrides:     map[string]interface{}{"template_name": "debian", "template_version": "1.4.2", "template_version_id_base": 9000},
+			expectedError: `template_version must be a number with template_version_id_base, got "1.4.2"`,
+		},
+		{
+			name:          "retain_versions requires template_version",
+			overrides:     map[string]interface{}{"retain_versions": 3},
+			expectedError: "retain_versions requires template_version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedName, c.TemplateName)
+			require.Equal(t, tt.expectedLineage, c.templateLineage)
+			require.Equal(t, tt.expectedTags, c.Tags)
+			require.Equal(t, tt.expectedVMID, c.VMID)
+		})
+	}
+}
+
+func TestTemplateDescription(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["template_description"] = "Built by {{ .BuildName }}"
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	require.NoError(t, err)
+	// Rendered once the template is built
+	require.Equal(t, "Built by {{ .BuildName }}", c.TemplateDescription)
+
+	cfg["template_description"] = "Built by {{ .BuildName"
+	c = Config{}
+	_, _, err = c.Prepare(&c, cfg)
+	require.ErrorContains(t, err, "invalid 'template_description'")
+}
+
+func TestTemplateConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		overrides      map[string]interface{}
+		expectedConfig templateHardwareConfig
+		expectedError  string
+	}{
+		{
+			name: "network adapters default to e1000",
+			overrides: map[string]interface{}{"template_config": map[string]interface{}{
+				"memory":           1024,
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0"}},
+			}},
+			expectedConfig: templateHardwareConfig{Memory: 1024, NICs: []NICConfig{{Model: "e1000", Bridge: "vmbr0"}}},
+		},
+		{
+			name: "version tag is kept",
+			overrides: map[string]interface{}{
+				"template_name":    "debian",
+				"template_version": "2",
+				"template_config":  map[string]interface{}{"tags": "golden"},
+			},
+			expectedConfig: templateHardwareConfig{Tags: "golden;version-2"},
+		},
+		{
+			name: "network adapters require a bridge",
+			overrides: map[string]interface{}{"template_config": map[string]interface{}{
+				"network_adapters": []map[string]interface{}{{"model": "virtio"}},
+			}},
+			expectedError: "template_config.network_adapters[0].bridge must be specified",
+		},
+		{
+			name: "invalid serial",
+			overrides: map[string]interface{}{"template_config": map[string]interface{}{
+				"serials": []string{"/mnt/device"},
+			}},
+			expectedError: `template_config.serials must respond to pattern "/dev/.+" or be "socket"`,
+		},
+		{
+			name: "memory lower than ballooning_minimum",
+			overrides: map[string]interface{}{
+				"ballooning_minimum": 2048,
+				"memory":             4096,
+				"template_config":    map[string]interface{}{"memory": 1024},
+			},
+			expectedError: "ballooning_minimum (2048) must be lower than template_config.memory (1024)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedConfig, c.TemplateConfig)
+		})
+	}
+}
+
+func TestOutputMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectedMode  string
+		expectedError string
+	}{
+		{
+			name:         "defaults to template",
+			expectedMode: "template",
+		},
+		{
+			name:         "vm",
+			overrides:    map[string]interface{}{"output_mode": "vm"},
+			expectedMode: "vm",
+		},
+		{
+			name:         "snapshot",
+			overrides:    map[string]interface{}{"output_mode": "snapshot", "vm_id": 100, "snapshot_name": "release_1"},
+			expectedMode: "snapshot",
+		},
+		{
+			name:          "unknown mode",
+			overrides:     map[string]interface{}{"output_mode": "image"},
+			expectedError: "output_mode must be one of template, vm or snapshot",
+		},
+		{
+			name:          "snapshot requires vm_id",
+			overrides:     map[string]interface{}{"output_mode": "snapshot", "snapshot_name": "release"},
+			expectedError: `vm_id must be specified with output_mode = "snapshot"`,
+		},
+		{
+			name:          "snapshot requires a valid snapshot_name",
+			overrides:     map[string]interface{}{"output_mode": "snapshot", "vm_id": 100, "snapshot_name": "1.0"},
+			expectedError: "snapshot_name:",
+		},
+		{
+			name:          "snapshot_name requires snapshot",
+			overrides:     map[string]interface{}{"snapshot_name": "release"},
+			expectedError: `snapshot_name requires output_mode = "snapshot"`,
+		},
+		{
+			name:          "template options require template",
+			overrides:     map[string]interface{}{"output_mode": "vm", "template_id_range": "9000-9099"},
+			expectedError: `force_mode = "replace", template_id_range and retain_versions require output_mode = "template"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedMode, c.OutputMode)
+		})
+	}
+}
+
+func TestShutdownCommand(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrides       map[string]interface{}
+		expectedTimeout time.Duration
+		expectedError   string
+	}{
+		{
+			name:            "shutdown_timeout defaults to 5m",
+			overrides:       map[string]interface{}{"shutdown_command": "sudo poweroff"},
+			expectedTimeout: 5 * time.Minute,
+		},
+		{
+			name:            "shutdown_timeout",
+			overrides:       map[string]interface{}{"shutdown_command": "sudo poweroff", "shutdown_timeout": "90s"},
+			expectedTimeout: 90 * time.Second,
+		},
+		{
+			name:          "shutdown_command requires a communicator",
+			overrides:     map[string]interface{}{"shutdown_command": "sudo poweroff", "communicator": "none"},
+			expectedError: "shutdown_command requires a communicator",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTimeout, c.ShutdownTimeout)
+		})
+	}
+}
+
 func TestPCIDeviceMapping(t *testing.T) {
 	testCases := []struct {
 		expectedError   error
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of API errors, to be tested with errors.Is.
+var (
+	// The guest, volume, storage or API path does not exist.
+	ErrNotFound = errors.New("not found")
+	// A guest with the same ID, or a volume with the same name, exists.
+	ErrAlreadyExists = errors.New("already exists")
+	// The guest is locked by another operation, such as a backup or a clone.
+	ErrLocked = errors.New("locked")
+	// The user or token lacks a privilege, or the credentials are wrong.
+	ErrPermissionDenied = errors.New("permission denied")
+	// The request or the task it started did not finish in time.
+	ErrTimeout = errors.New("timeout")
+	// The API could not be reached or was not ready, retrying may succeed.
+	ErrTransient = errors.New("transient error")
+)
+
+// APIError is an error of the Proxmox API, classified by kind.
+type APIError struct {
+	// One of the ErrXXX kinds, or nil when the error is not recognized.
+	Kind error
+	// The HTTP status code, or 0 when there was no response.
+	StatusCode int
+	// The message of the response body, when it differs from the status.
+	Message string
+	// The errors of the response body about the request parameters.
+	Params map[string]string
+	// The error returned by proxmox-api-go.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	msg := e.Err.Error()
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	names := make([]string, 0, len(e.Params))
+	for name := range e.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg += fmt.Sprintf(", %s: %s", name, e.Params[name])
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// Is reports whether the error is of the given kind.
+func (e *APIError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// proxmox-api-go returns the status line of failed responses as error,
+// possibly prefixed by some context. Proxmox puts the error message in the
+// reason phrase.
+var statusLineRe = regexp.MustCompile(`(?:^|: )([1-5][0-9]{2}) (.*)$`)
+
+// ClassifyError turns an error of proxmox-api-go into an *APIError, using
+// the HTTP status it carries and, when available, the response body. Errors
+// that are already classified, and nil, are returned as is.
+func ClassifyError(err error, body string) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	apiErr = &APIError{Err: err}
+
+	message := err.Error()
+	if m := statusLineRe.FindStringSubmatch(message); m != nil {
+		apiErr.StatusCode, _ = strconv.Atoi(m[1])
+		message = m[2]
+	}
+	var resp struct {
+		Message string            `json:"message"`
+		Errors  map[string]string `json:"errors"`
+	}
+	if json.Unmarshal([]byte(body), &resp) == nil {
+		resp.Message = strings.TrimSpace(resp.Message)
+		if resp.Message != "" && !strings.Contains(message, resp.Message) {
+			apiErr.Message = resp.Message
+			message += " " + resp.Message
+		}
+		apiErr.Params = resp.Errors
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		apiErr.Kind = ErrTimeout
+	case errors.As(err, &netErr):
+		apiErr.Kind = ErrTransient
+	default:
+		apiErr.Kind = errorKind(apiErr.StatusCode, message)
+	}
+	return apiErr
+}
+
+// errorKind classifies an error from its HTTP status code, and its message
+// for the status codes Proxmox uses for most failures.
+func errorKind(statusCode int, message string) error {
+	switch statusCode {
+	case 401, 403:
+		return ErrPermissionDenied
+	case 404:
+		return ErrNotFound
+	case 408, 504:
+		return ErrTimeout
+	// 595 and 596 are sent by pveproxy when it cannot reach another node
+	case 429, 502, 503, 595, 596:
+		return ErrTransient
+	}
+
+	msg := strings.ToLower(message)
+	switch {
+	case strings.Contains(msg, "permission check failed"):
+		return ErrPermissionDenied
+	// Lock errors mention a timeout, they are checked first
+	case strings.Contains(msg, "can't lock file"), strings.Contains(msg, "is locked"):
+		return ErrLocked
+	case strings.Contains(msg, "already exists"):
+		return ErrAlreadyExists
+	case strings.Contains(msg, "does not exist"), strings.Contains(msg, "not found"), strings.Contains(msg, "no such"):
+		return ErrNotFound
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "timed out"):
+		return ErrTimeout
+	case strings.Contains(msg, "no quorum"), strings.Contains(msg, "cluster not ready"):
+		return ErrTransient
+	}
+	return nil
+}
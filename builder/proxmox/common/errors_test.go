@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+// writeStatus writes a response with a custom reason phrase, which Proxmox
+// uses for its error messages, but net/http does not allow to set.
+func writeStatus(t *testing.T, rw http.ResponseWriter, code int, reason string, body string) {
+	conn, buf, err := rw.(http.Hijacker).Hijack()
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", code, reason, len(body), body)
+	require.NoError(t, buf.Flush())
+}
+
+func newTestClient(t *testing.T, serverURL string) *Client {
+	pmURL, _ := url.Parse(serverURL)
+	config := ClientConfig{
+		proxmoxURL: pmURL,
+		Username:   "dummy@vmhost!test-token",
+		Token:      "ac5293bf-15e2-477f-b04c-a6dfa7a46b80",
+	}
+	client, err := config.NewClient(false)
+	require.NoError(t, err)
+	return client
+}
+
+func TestClientErrors(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/cluster/resources":
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"data": []map[string]interface{}{
+					{"vmid": 100, "name": "existing", "node": "pve", "type": "qemu"},
+				},
+			})
+		case "/nodes/pve/qemu/100/clone":
+			writeStatus(t, rw, 500, "unable to create VM 101 - VM 101 already exists on node 'pve'", `{"data":null}`)
+		case "/nodes/pve/qemu/101/clone":
+			writeStatus(t, rw, 500, "VM 101 is locked (backup)", `{"data":null}`)
+		case "/nodes/pve/qemu/102/clone":
+			writeStatus(t, rw, 500, "can't lock file '/var/lock/qemu-server/lock-102.conf' - got timeout", `{"data":null}`)
+		case "/nodes/pve/qemu/103/clone":
+			writeStatus(t, rw, 403, "Permission check failed (/vms/103, VM.Clone)", `{"data":null}`)
+		case "/nodes/pve/qemu/104/clone":
+			writeStatus(t, rw, 500, "Configuration file 'nodes/pve/qemu-server/104.conf' does not exist", `{"data":null}`)
+		case "/nodes/pve/qemu/105/clone":
+			rw.WriteHeader(http.StatusGatewayTimeout)
+		case "/nodes/pve/qemu/106/clone":
+			writeStatus(t, rw, 595, "Connection refused", "")
+		case "/nodes/pve/qemu/107/clone":
+			writeStatus(t, rw, 400, "Parameter verification failed.", `{"data":null,"errors":{"newid":"value must have a minimum value of 100"}}`)
+		case "/nodes/pve/qemu/108/clone":
+			writeStatus(t, rw, 500, "something unexpected", `{"data":null}`)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+	client := newTestClient(t, mockAPI.URL)
+
+	cs := []struct {
+		vmid          int
+		expectedKind  error
+		expectedError string
+	}{
+		{100, ErrAlreadyExists, "500 unable to create VM 101 - VM 101 already exists on node 'pve'"},
+		{101, ErrLocked, "500 VM 101 is locked (backup)"},
+		{102, ErrLocked, "500 can't lock file '/var/lock/qemu-server/lock-102.conf' - got timeout"},
+		{103, ErrPermissionDenied, "403 Permission check failed (/vms/103, VM.Clone)"},
+		{104, ErrNotFound, "500 Configuration file 'nodes/pve/qemu-server/104.conf' does not exist"},
+		{105, ErrTimeout, "504 Gateway Timeout"},
+		{106, ErrTransient, "595 Connection refused"},
+		{107, nil, "400 Parameter verification failed., newid: value must have a minimum value of 100"},
+		{108, nil, "500 something unexpected"},
+		{109, ErrNotFound, "404 Not Found"},
+	}
+	for _, c := range cs {
+		t.Run(fmt.Sprintf("clone of %d", c.vmid), func(t *testing.T) {
+			_, err := client.CreateItemReturnStatus(map[string]interface{}{"newid": 200}, fmt.Sprintf("/nodes/pve/qemu/%d/clone", c.vmid))
+			require.Error(t, err)
+			require.Equal(t, c.expectedError, err.Error())
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr), "expected an *APIError, got %T", err)
+			require.Equal(t, c.expectedKind, apiErr.Kind)
+			for _, kind := range []error{ErrNotFound, ErrAlreadyExists, ErrLocked, ErrPermissionDenied, ErrTimeout, ErrTransient} {
+				require.Equal(t, kind == c.expectedKind, errors.Is(err, kind), "errors.Is(err, %v)", kind)
+			}
+		})
+	}
+
+	t.Run("guest lookups", func(t *testing.T) {
+		err := client.CheckVmRef(proxmox.NewVmRef(999))
+		require.ErrorIs(t, err, ErrNotFound)
+		require.NoError(t, client.CheckVmRef(proxmox.NewVmRef(100)))
+
+		_, err = client.GetVmRefsByName("missing")
+		require.ErrorIs(t, err, ErrNotFound)
+		vmrs, err := client.GetVmRefsByName("existing")
+		require.NoError(t, err)
+		require.Len(t, vmrs, 1)
+	})
+
+	t.Run("message in body", func(t *testing.T) {
+		err := ClassifyError(errors.New("500 Internal Server Error"), `{"data":null,"message":"VM 100 already exists on node 'pve'\n"}`)
+		require.ErrorIs(t, err, ErrAlreadyExists)
+		require.Equal(t, "500 Internal Server Error: VM 100 already exists on node 'pve'", err.Error())
+	})
+}
+
+func TestClientErrorsUnreachable(t *testing.T) {
+	mockAPI := httptest.NewServer(http.NotFoundHandler())
+	client := newTestClient(t, mockAPI.URL)
+	mockAPI.Close()
+
+	err := client.Delete("/nodes/pve/tasks/UPID:pve:1:2:3:qmclone:100:root@pam:")
+	require.ErrorIs(t, err, ErrTransient)
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// NodeSSHConfig holds the settings used to copy files to and from a Proxmox
+// node over SSH, for the transfers the Proxmox API does not support. The host
+// key of the node is only verified when `node_ssh_host_key` or
+// `node_ssh_known_hosts_file` is set.
+type NodeSSHConfig struct {
+	// Host name or IP address of the Proxmox node to copy files to and from.
+	// Defaults to the host of `proxmox_url`.
+	NodeSSHHost string `mapstructure:"node_ssh_host"`
+	// SSH port of the Proxmox node. Defaults to `22`.
+	NodeSSHPort int `mapstructure:"node_ssh_port"`
+	// User to connect to the Proxmox node as. Defaults to `root`.
+	NodeSSHUsername string `mapstructure:"node_ssh_username"`
+	// Password of `node_ssh_username`.
+	NodeSSHPassword string `mapstructure:"node_ssh_password"`
+	// Path to a private key authorized for `node_ssh_username`.
+	// One of `node_ssh_password` or `node_ssh_private_key_file` is required.
+	NodeSSHPrivateKeyFile string `mapstructure:"node_ssh_private_key_file"`
+	// Public host key of the Proxmox node, as found in
+	// `/etc/ssh/ssh_host_ed25519_key.pub` on the node, for example
+	// `ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAA...`. The connection is refused
+	// when the node presents another key.
+	NodeSSHHostKey string `mapstructure:"node_ssh_host_key"`
+	// Path to a `known_hosts` file listing the host key of the Proxmox node,
+	// such as `~/.ssh/known_hosts`, to verify it against instead of
+	// `node_ssh_host_key`. When neither is set, the host key of the node is
+	// not verified, and the connection, authenticated as `root` by default,
+	// is open to impersonation of the node.
+	NodeSSHKnownHostsFile string `mapstructure:"node_ssh_known_hosts_file"`
+}
+
+// Prepare sets the defaults of the node_ssh settings and validates them. The
+// host defaults to the one the API is served from.
+func (c *NodeSSHConfig) Prepare(proxmoxURL string) []error {
+	var errs []error
+
+	if c.NodeSSHHost == "" {
+		if u, err := url.Parse(proxmoxURL); err == nil {
+			c.NodeSSHHost = u.Hostname()
+		}
+	}
+	if c.NodeSSHPort == 0 {
+		c.NodeSSHPort = 22
+	}
+	if c.NodeSSHUsername == "" {
+		c.NodeSSHUsername = "root"
+	}
+	if c.NodeSSHHost == "" {
+		errs = append(errs, errors.New("node_ssh_host must be specified"))
+	}
+	if c.NodeSSHPassword == "" && c.NodeSSHPrivateKeyFile == "" {
+		errs = append(errs, errors.New("one of node_ssh_password or node_ssh_private_key_file must be specified"))
+	}
+	if c.NodeSSHPrivateKeyFile != "" {
+		if _, err := os.Stat(c.NodeSSHPrivateKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("node_ssh_private_key_file is invalid: %s", err))
+		}
+	}
+	if c.NodeSSHHostKey != "" && c.NodeSSHKnownHostsFile != "" {
+		errs = append(errs, errors.New("only one of node_ssh_host_key and node_ssh_known_hosts_file can be set"))
+	}
+	if c.NodeSSHHostKey != "" {
+		if _, _, _, _, err := gossh.ParseAuthorizedKey([]byte(c.NodeSSHHostKey)); err != nil {
+			errs = append(errs, fmt.Errorf("node_ssh_host_key is invalid: %s", err))
+		}
+	}
+	if c.NodeSSHKnownHostsFile != "" {
+		if _, err := knownhosts.New(c.NodeSSHKnownHostsFile); err != nil {
+			errs = append(errs, fmt.Errorf("node_ssh_known_hosts_file is invalid: %s", err))
+		}
+	}
+	packersdk.LogSecretFilter.Set(c.NodeSSHPassword)
+
+	return errs
+}
+
+// Connect opens an SSH connection to the Proxmox node.
+func (c *NodeSSHConfig) Connect() (packersdk.Communicator, error) {
+	var auth []gossh.AuthMethod
+	if c.NodeSSHPrivateKeyFile != "" {
+		key, err := os.ReadFile(c.NodeSSHPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := gossh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing node_ssh_private_key_file: %s", err)
+		}
+		auth = append(auth, gossh.PublicKeys(signer))
+	}
+	if c.NodeSSHPassword != "" {
+		auth = append(auth,
+			gossh.Password(c.NodeSSHPassword),
+			gossh.KeyboardInteractive(ssh.PasswordKeyboardInteractive(c.NodeSSHPassword)),
+		)
+	}
+
+	hostKeyCallback, err := c.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(c.NodeSSHHost, strconv.Itoa(c.NodeSSHPort))
+	log.Printf("connecting to %s as %s", address, c.NodeSSHUsername)
+	return ssh.New(address, &ssh.Config{
+		Connection: ssh.ConnectFunc("tcp", address),
+		SSHConfig: &gossh.ClientConfig{
+			User:            c.NodeSSHUsername,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+		},
+	})
+}
+
+// hostKeyCallback verifies the host key of the node against
+// node_ssh_host_key or node_ssh_known_hosts_file, when either is set.
+func (c *NodeSSHConfig) hostKeyCallback() (gossh.HostKeyCallback, error) {
+	switch {
+	case c.NodeSSHHostKey != "":
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(c.NodeSSHHostKey))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing node_ssh_host_key: %s", err)
+		}
+		return gossh.FixedHostKey(key), nil
+	case c.NodeSSHKnownHostsFile != "":
+		callback, err := knownhosts.New(c.NodeSSHKnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading node_ssh_known_hosts_file: %s", err)
+		}
+		return callback, nil
+	}
+	log.Printf("not verifying the host key of %s, set node_ssh_host_key or node_ssh_known_hosts_file", c.NodeSSHHost)
+	return gossh.InsecureIgnoreHostKey(), nil
+}
+
+// BackupDir returns the directory backup archives are stored in on a directory
+// based storage, given the configuration of the storage.
+func BackupDir(name string, storage map[string]interface{}) (string, error) {
+	storagePath, _ := storage["path"].(string)
+	if storagePath == "" {
+		return "", fmt.Errorf("storage %s is not a directory based storage, its backup archives can not be copied over SSH", name)
+	}
+	content, _ := storage["content"].(string)
+	if !strings.Contains(content, "backup") {
+		return "", fmt.Errorf("storage %s does not allow the backup content type", name)
+	}
+	return path.Join(storagePath, "dump"), nil
+}
+
+// ShellQuote quotes s for use as a single POSIX shell word.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
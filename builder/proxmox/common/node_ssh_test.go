@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) gossh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := gossh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func TestNodeSSHHostKey(t *testing.T) {
+	nodeKey, otherKey := newHostKey(t), newHostKey(t)
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("192.0.2.10:22")}, nodeKey)
+	require.NoError(t, os.WriteFile(knownHosts, []byte(line+"\n"), 0600))
+
+	cs := []struct {
+		name         string
+		config       NodeSSHConfig
+		expectErrors int
+		// Whether the host keys of the node and of another host are accepted
+		acceptNode  bool
+		acceptOther bool
+	}{
+		{
+			name:        "not verified by default",
+			config:      NodeSSHConfig{NodeSSHPassword: "password"},
+			acceptNode:  true,
+			acceptOther: true,
+		},
+		{
+			name:       "node_ssh_host_key",
+			config:     NodeSSHConfig{NodeSSHPassword: "password", NodeSSHHostKey: string(gossh.MarshalAuthorizedKey(nodeKey))},
+			acceptNode: true,
+		},
+		{
+			name:       "node_ssh_known_hosts_file",
+			config:     NodeSSHConfig{NodeSSHPassword: "password", NodeSSHKnownHostsFile: knownHosts},
+			acceptNode: true,
+		},
+		{
+			name:         "invalid node_ssh_host_key",
+			config:       NodeSSHConfig{NodeSSHPassword: "password", NodeSSHHostKey: "ssh-ed25519 invalid"},
+			expectErrors: 1,
+		},
+		{
+			name:         "missing node_ssh_known_hosts_file",
+			config:       NodeSSHConfig{NodeSSHPassword: "password", NodeSSHKnownHostsFile: filepath.Join(t.TempDir(), "missing")},
+			expectErrors: 1,
+		},
+		{
+			name: "both set",
+			config: NodeSSHConfig{
+				NodeSSHPassword:       "password",
+				NodeSSHHostKey:        string(gossh.MarshalAuthorizedKey(nodeKey)),
+				NodeSSHKnownHostsFile: knownHosts,
+			},
+			expectErrors: 1,
+		},
+	}
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			errs := c.config.Prepare("https://192.0.2.10:8006/api2/json")
+			require.Len(t, errs, c.expectErrors, "errors: %v", errs)
+			if c.expectErrors > 0 {
+				return
+			}
+
+			callback, err := c.config.hostKeyCallback()
+			require.NoError(t, err)
+			require.Equal(t, c.acceptNode, callback("192.0.2.10:22", remote, nodeKey) == nil)
+			require.Equal(t, c.acceptOther, callback("192.0.2.10:22", remote, otherKey) == nil)
+		})
+	}
+}
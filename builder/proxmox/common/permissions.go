@@ -0,0 +1,226 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// PrivilegeRequirement lists the privileges a feature of the build needs.
+// They are fulfilled when granted on any of the paths.
+type PrivilegeRequirement struct {
+	// The configuration option or feature that needs the privileges, used in
+	// errors.
+	Feature    string
+	Paths      []string
+	Privileges []string
+}
+
+type permissionChecker interface {
+	GetVersion() (version proxmoxapi.Version, err error)
+	GetItemList(url string) (list map[string]interface{}, err error)
+	GetItemListInterfaceArray(url string) ([]interface{}, error)
+}
+
+var _ permissionChecker = &Client{}
+
+// checkPermissions fetches the privileges of the configured user or token on
+// every path the build touches, and returns all the missing ones at once.
+func checkPermissions(client permissionChecker, reqs []PrivilegeRequirement) error {
+	granted := map[string]map[string]bool{}
+	for _, req := range reqs {
+		for _, path := range req.Paths {
+			if _, ok := granted[path]; ok {
+				continue
+			}
+			privs, err := privileges(client, path)
+			if err != nil {
+				return fmt.Errorf("error checking privileges on %s: %s", path, err)
+			}
+			granted[path] = privs
+		}
+	}
+
+	var errs *packersdk.MultiError
+	for _, req := range reqs {
+		var missing []string
+		for _, priv := range req.Privileges {
+			found := false
+			for _, path := range req.Paths {
+				if granted[path][priv] {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, priv)
+			}
+		}
+		if len(missing) > 0 {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: missing %s on %s", req.Feature, strings.Join(missing, ", "), strings.Join(req.Paths, " or ")))
+		}
+	}
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// privileges returns the effective privileges of the authenticated user or
+// token on the path.
+func privileges(client permissionChecker, path string) (map[string]bool, error) {
+	list, err := client.GetItemList("/access/permissions?path=" + url.QueryEscape(path))
+	if err != nil {
+		return nil, err
+	}
+	data, _ := list["data"].(map[string]interface{})
+	perms, _ := data[path].(map[string]interface{})
+	privs := map[string]bool{}
+	// The values tell whether the privileges propagate, listed ones are granted
+	for priv := range perms {
+		privs[priv] = true
+	}
+	return privs, nil
+}
+
+// privilegeRequirements returns the privileges needed by the configuration,
+// given the storage pools it uses. SDN.Use on bridges is only required from
+// Proxmox VE 8 on.
+func (c *Config) privilegeRequirements(client permissionChecker, storage []StorageRequirement) []PrivilegeRequirement {
+	vmPaths := []string{"/vms"}
+	// When replacing a template, the VM is built under another ID, and
+	// retain_versions deletes the older versions
+	if c.VMID != 0 && !(c.PackerForce && c.ForceMode == "replace") && c.RetainVersions == 0 {
+		vmPaths = []string{"/vms/" + strconv.Itoa(c.VMID)}
+	}
+	if c.Pool != "" {
+		vmPaths = append(vmPaths, "/pool/"+c.Pool)
+	}
+	vmPrivs := []string{
+		"VM.Allocate", "VM.Audit", "VM.PowerMgmt",
+		"VM.Config.CPU", "VM.Config.Memory", "VM.Config.Disk",
+		"VM.Config.Network", "VM.Config.Options", "VM.Config.HWType",
+	}
+	if len(c.ISOs) > 0 || c.CloudInit {
+		vmPrivs = append(vmPrivs, "VM.Config.CDROM")
+	}
+	if c.CloudInit {
+		vmPrivs = append(vmPrivs, "VM.Config.Cloudinit")
+	}
+	if len(c.BootCommand) > 0 {
+		vmPrivs = append(vmPrivs, "VM.Console")
+	}
+	// The VM may be cloned into template_id_range, or to the ID of the
+	// template it replaces
+	if c.templateIDRange.isSet() || c.ForceKeepVMID {
+		vmPrivs = append(vmPrivs, "VM.Clone")
+	}
+	if c.OutputMode == "snapshot" {
+		vmPrivs = append(vmPrivs, "VM.Snapshot")
+	}
+	reqs := []PrivilegeRequirement{
+		{Feature: "virtual machine", Paths: vmPaths, Privileges: vmPrivs},
+	}
+
+	// Storage pools used for several purposes need the union of the privileges
+	storagePrivs := map[string][]string{}
+	storageFeatures := map[string][]string{}
+	for _, s := range storage {
+		priv := "Datastore.Audit"
+		switch {
+		case s.Content == "images" || s.Content == "rootdir":
+			priv = "Datastore.AllocateSpace"
+		case s.Upload:
+			priv = "Datastore.AllocateTemplate"
+		}
+		if !slices.Contains(storagePrivs[s.StoragePool], priv) {
+			storagePrivs[s.StoragePool] = append(storagePrivs[s.StoragePool], priv)
+		}
+		if !slices.Contains(storageFeatures[s.StoragePool], s.Option) {
+			storageFeatures[s.StoragePool] = append(storageFeatures[s.StoragePool], s.Option)
+		}
+	}
+	pools := make([]string, 0, len(storagePrivs))
+	for pool := range storagePrivs {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	for _, pool := range pools {
+		reqs = append(reqs, PrivilegeRequirement{
+			Feature:    strings.Join(storageFeatures[pool], ", "),
+			Paths:      []string{"/storage/" + pool},
+			Privileges: storagePrivs[pool],
+		})
+	}
+
+	for _, iso := range c.ISOs {
+		if iso.ISODownloadPVE {
+			reqs = append(reqs, PrivilegeRequirement{
+				Feature:    "iso_download_pve",
+				Paths:      []string{"/nodes/" + c.Node},
+				Privileges: []string{"Sys.Audit", "Sys.Modify"},
+			})
+			break
+		}
+	}
+
+	if version, err := client.GetVersion(); err != nil {
+		log.Printf("error fetching the Proxmox VE version, not checking privileges on bridges: %s", err)
+	} else if version.Major >= 8 {
+		zones := vnetZones(client)
+		for idx, nic := range c.NICs {
+			if nic.Bridge == "" {
+				continue
+			}
+			zone, ok := zones[nic.Bridge]
+			if !ok {
+				// Plain bridges belong to the built-in localnetwork zone
+				zone = "localnetwork"
+			}
+			reqs = append(reqs, PrivilegeRequirement{
+				Feature:    fmt.Sprintf("network_adapters[%d].bridge", idx),
+				Paths:      []string{fmt.Sprintf("/sdn/zones/%s/%s", zone, nic.Bridge)},
+				Privileges: []string{"SDN.Use"},
+			})
+		}
+	}
+
+	for idx, dev := range c.PCIDevices {
+		if dev.Mapping != "" {
+			reqs = append(reqs, PrivilegeRequirement{
+				Feature:    fmt.Sprintf("pci_devices[%d].mapping", idx),
+				Paths:      []string{"/mapping/pci/" + dev.Mapping},
+				Privileges: []string{"Mapping.Use"},
+			})
+		}
+	}
+	return reqs
+}
+
+// vnetZones returns the zone of every SDN VNet. Clusters without SDN have
+// none.
+func vnetZones(client permissionChecker) map[string]string {
+	zones := map[string]string{}
+	vnets, err := client.GetItemListInterfaceArray("/cluster/sdn/vnets")
+	if err != nil {
+		log.Printf("error listing SDN VNets: %s", err)
+		return zones
+	}
+	for _, r := range vnets {
+		vnet, _ := r.(map[string]interface{})
+		name, _ := vnet["vnet"].(string)
+		zone, _ := vnet["zone"].(string)
+		zones[name] = zone
+	}
+	return zones
+}
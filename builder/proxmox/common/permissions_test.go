@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+)
+
+type permissionCheckerMock struct {
+	version    proxmoxapi.Version
+	privileges map[string][]string
+	vnets      []interface{}
+	requested  []string
+}
+
+func (m *permissionCheckerMock) GetVersion() (proxmoxapi.Version, error) {
+	return m.version, nil
+}
+
+func (m *permissionCheckerMock) GetItemList(u string) (map[string]interface{}, error) {
+	path, err := url.QueryUnescape(strings.TrimPrefix(u, "/access/permissions?path="))
+	if err != nil {
+		return nil, err
+	}
+	m.requested = append(m.requested, path)
+	privs := map[string]interface{}{}
+	for _, priv := range m.privileges[path] {
+		privs[priv] = float64(1)
+	}
+	return map[string]interface{}{
+		"data": map[string]interface{}{path: privs},
+	}, nil
+}
+
+func (m *permissionCheckerMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	if url == "/cluster/sdn/vnets" && m.vnets != nil {
+		return m.vnets, nil
+	}
+	return nil, errors.New("not found")
+}
+
+var _ permissionChecker = &permissionCheckerMock{}
+
+var allVMPrivileges = []string{
+	"VM.Allocate", "VM.Audit", "VM.PowerMgmt", "VM.Console",
+	"VM.Config.CPU", "VM.Config.Memory", "VM.Config.Disk", "VM.Config.CDROM",
+	"VM.Config.Network", "VM.Config.Options", "VM.Config.HWType", "VM.Config.Cloudinit",
+}
+
+func TestCheckPermissions(t *testing.T) {
+	config := &Config{
+		Node:       "pve1",
+		Pool:       "packer",
+		CloudInit:  true,
+		Disks:      []diskConfig{{StoragePool: "local-lvm"}},
+		ISOs:       []ISOsConfig{{ISOStoragePool: "local"}, {ISOFile: "nfs:iso/drivers.iso"}},
+		NICs:       []NICConfig{{Bridge: "vmbr0"}, {Bridge: "vnet10"}},
+		PCIDevices: []pciDeviceConfig{{Mapping: "gpu"}},
+	}
+
+	cs := []struct {
+		name           string
+		version        proxmoxapi.Version
+		privileges     map[string][]string
+		expectedErrors []string
+	}{
+		{
+			name:    "all privileges granted",
+			version: proxmoxapi.Version{Major: 8},
+			privileges: map[string][]string{
+				"/vms":                          allVMPrivileges,
+				"/storage/local-lvm":            {"Datastore.AllocateSpace"},
+				"/storage/local":                {"Datastore.AllocateTemplate"},
+				"/storage/nfs":                  {"Datastore.Audit"},
+				"/sdn/zones/localnetwork/vmbr0": {"SDN.Use"},
+				"/sdn/zones/zone1/vnet10":       {"SDN.Use"},
+				"/mapping/pci/gpu":              {"Mapping.Use"},
+			},
+		},
+		{
+			name:    "privileges on the pool",
+			version: proxmoxapi.Version{Major: 7},
+			privileges: map[string][]string{
+				"/pool/packer":       allVMPrivileges,
+				"/storage/local-lvm": {"Datastore.AllocateSpace"},
+				"/storage/local":     {"Datastore.AllocateTemplate"},
+				"/storage/nfs":       {"Datastore.Audit"},
+				"/mapping/pci/gpu":   {"Mapping.Use"},
+			},
+		},
+		{
+			name:    "missing privileges are listed",
+			version: proxmoxapi.Version{Major: 8},
+			privileges: map[string][]string{
+				"/vms":           allVMPrivileges[:11],
+				"/storage/nfs":   {"Datastore.Audit"},
+				"/storage/local": {"Datastore.Audit"},
+			},
+			expectedErrors: []string{
+				"virtual machine: missing VM.Config.Cloudinit on /vms or /pool/packer",
+				"iso_storage_pool: missing Datastore.AllocateTemplate on /storage/local",
+				"disks[0].storage_pool: missing Datastore.AllocateSpace on /storage/local-lvm",
+				"network_adapters[0].bridge: missing SDN.Use on /sdn/zones/localnetwork/vmbr0",
+				"network_adapters[1].bridge: missing SDN.Use on /sdn/zones/zone1/vnet10",
+				"pci_devices[0].mapping: missing Mapping.Use on /mapping/pci/gpu",
+			},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &permissionCheckerMock{
+				version:    c.version,
+				privileges: c.privileges,
+				vnets: []interface{}{
+					map[string]interface{}{"vnet": "vnet10", "zone": "zone1"},
+				},
+			}
+			reqs := config.privilegeRequirements(m, config.storageRequirements(""))
+			err := checkPermissions(m, reqs)
+
+			var got []string
+			if err != nil {
+				for _, line := range strings.Split(err.Error(), "\n") {
+					line = strings.TrimPrefix(strings.TrimSpace(line), "* ")
+					if strings.Contains(line, ": missing ") {
+						got = append(got, line)
+					}
+				}
+				if len(got) == 0 {
+					t.Fatalf("Unexpected error %s", err)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(c.expectedErrors, "\n") {
+				t.Errorf("Expected errors\n%s\ngot\n%s", strings.Join(c.expectedErrors, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheckPermissionsFetchesEachPathOnce(t *testing.T) {
+	m := &permissionCheckerMock{}
+	reqs := []PrivilegeRequirement{
+		{Feature: "a", Paths: []string{"/vms", "/pool/packer"}, Privileges: []string{"VM.Allocate"}},
+		{Feature: "b", Paths: []string{"/vms"}, Privileges: []string{"VM.Audit"}},
+	}
+	if err := checkPermissions(m, reqs); err == nil {
+		t.Fatal("Expected missing privileges to be reported")
+	}
+	if strings.Join(m.requested, ",") != "/vms,/pool/packer" {
+		t.Errorf("Expected each path to be fetched once, got %v", m.requested)
+	}
+}
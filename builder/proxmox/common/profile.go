@@ -0,0 +1,162 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+// defaultCredentialsFile returns the path of the credentials file used when
+// `credentials_file` is not set, `~/.config/packer-proxmox/credentials` unless
+// XDG_CONFIG_HOME points elsewhere.
+func defaultCredentialsFile() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "packer-proxmox", "credentials"), nil
+}
+
+// readProfiles parses a credentials file, made of sections named after the
+// profiles, holding `key = value` settings:
+//
+//	[homelab]
+//	proxmox_url = https://pve.home.example.com:8006/api2/json
+//	username    = packer@pve!build
+//	token       = 01234567-89ab-cdef-0123-456789abcdef
+//	node        = pve1
+//
+// Lines starting with `#` or `;` are comments.
+func readProfiles(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var section map[string]string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: invalid section %q", path, n, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := profiles[name]; ok {
+				return nil, fmt.Errorf("%s:%d: duplicate profile %q", path, n, name)
+			}
+			section = map[string]string{}
+			profiles[name] = section
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		if section == nil {
+			return nil, fmt.Errorf("%s:%d: setting outside of a profile", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		section[strings.TrimSpace(key)] = value
+	}
+	return profiles, scanner.Err()
+}
+
+// loadProfile fills the connection settings that are not set in the
+// configuration from the profile of the credentials file. The password and
+// token of the profile are ignored when the configuration sets either one,
+// so that it does not end up mixing two authentication methods.
+func (c *ClientConfig) loadProfile() error {
+	path := c.CredentialsFile
+	if path == "" {
+		var err error
+		if path, err = defaultCredentialsFile(); err != nil {
+			return fmt.Errorf("could not find the credentials file, set credentials_file: %s", err)
+		}
+	}
+	profiles, err := readProfiles(path)
+	if err != nil {
+		return fmt.Errorf("could not read the credentials file: %s", err)
+	}
+	profile, ok := profiles[c.Profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", c.Profile, path)
+	}
+
+	// Paths are relative to the credentials file
+	relative := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(filepath.Dir(path), p)
+	}
+	fields := map[string]*string{
+		"proxmox_url":      &c.ProxmoxURLRaw,
+		"username":         &c.Username,
+		"password":         &c.Password,
+		"token":            &c.Token,
+		"totp_secret":      &c.TOTPSecret,
+		"ca_file":          &c.CAFile,
+		"tls_fingerprint":  &c.TLSFingerprint,
+		"client_cert_file": &c.ClientCertFile,
+		"client_key_file":  &c.ClientKeyFile,
+		"api_proxy_url":    &c.APIProxyURL,
+		"node":             &c.profileNode,
+	}
+	authSet := c.Password != "" || c.Token != ""
+	for key, value := range profile {
+		switch key {
+		case "insecure_skip_tls_verify":
+			skip, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("profile %q: insecure_skip_tls_verify must be a boolean, got %q", c.Profile, value)
+			}
+			if c.SkipCertValidation == config.TriUnset {
+				c.SkipCertValidation = config.TrileanFromBool(skip)
+			}
+		case "password", "token":
+			if !authSet && *fields[key] == "" {
+				*fields[key] = value
+			}
+		case "ca_file", "client_cert_file", "client_key_file":
+			if *fields[key] == "" {
+				*fields[key] = relative(value)
+			}
+		default:
+			field, ok := fields[key]
+			if !ok {
+				return fmt.Errorf("profile %q: unknown setting %q", c.Profile, key)
+			}
+			if *field == "" {
+				*field = value
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultNode returns the node set by the profile, used when the
+// configuration does not set one.
+func (c *ClientConfig) DefaultNode() string {
+	return c.profileNode
+}
@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/stretchr/testify/require"
+)
+
+const testCredentials = `
+# Clusters used by the builds
+[homelab]
+proxmox_url = https://pve.home.example.com:8006/api2/json
+username    = packer@pve!build
+token       = "01234567-89ab-cdef-0123-456789abcdef"
+client_cert_file = certs/client.crt
+client_key_file  = /etc/ssl/client.key
+node        = pve1
+
+; Password authentication
+[lab]
+proxmox_url = https://lab.example.com:8006/api2/json
+username = root@pam
+password = 'correct horse battery staple'
+insecure_skip_tls_verify = true
+`
+
+func writeCredentials(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestProfiles(t *testing.T) {
+	for _, env := range []string{"PROXMOX_URL", "PROXMOX_USERNAME", "PROXMOX_PASSWORD", "PROXMOX_TOKEN", "PROXMOX_TOTP_SECRET", "PROXMOX_PROFILE", "PROXMOX_CREDENTIALS_FILE"} {
+		t.Setenv(env, "")
+	}
+	path := writeCredentials(t, testCredentials)
+
+	t.Run("profile settings", func(t *testing.T) {
+		c := ClientConfig{Profile: "homelab", CredentialsFile: path}
+		require.NoError(t, c.loadProfile())
+		require.Equal(t, "https://pve.home.example.com:8006/api2/json", c.ProxmoxURLRaw)
+		require.Equal(t, "packer@pve!build", c.Username)
+		require.Equal(t, "01234567-89ab-cdef-0123-456789abcdef", c.Token)
+		require.Equal(t, filepath.Join(filepath.Dir(path), "certs/client.crt"), c.ClientCertFile)
+		require.Equal(t, "/etc/ssl/client.key", c.ClientKeyFile)
+		require.Equal(t, "pve1", c.DefaultNode())
+		require.False(t, c.SkipCertValidation.True())
+	})
+
+	t.Run("template takes precedence over the profile", func(t *testing.T) {
+		t.Setenv("PROXMOX_USERNAME", "env@pam")
+		t.Setenv("PROXMOX_PROFILE", "lab")
+		t.Setenv("PROXMOX_CREDENTIALS_FILE", path)
+		c := ClientConfig{ProxmoxURLRaw: "https://other.example.com:8006/api2/json"}
+		require.Empty(t, c.Prepare())
+		require.Equal(t, "https://other.example.com:8006/api2/json", c.ProxmoxURLRaw)
+		require.Equal(t, "root@pam", c.Username)
+		require.Equal(t, "correct horse battery staple", c.Password)
+		require.True(t, c.SkipCertValidation.True())
+		require.Empty(t, c.DefaultNode())
+	})
+
+	t.Run("template authentication takes precedence over the profile", func(t *testing.T) {
+		c := ClientConfig{Profile: "lab", CredentialsFile: path, Token: "fedcba98-7654-3210-fedc-ba9876543210"}
+		require.NoError(t, c.loadProfile())
+		require.Equal(t, "root@pam", c.Username)
+		require.Equal(t, "fedcba98-7654-3210-fedc-ba9876543210", c.Token)
+		require.Empty(t, c.Password)
+	})
+
+	t.Run("template insecure_skip_tls_verify takes precedence over the profile", func(t *testing.T) {
+		c := ClientConfig{Profile: "lab", CredentialsFile: path, SkipCertValidation: config.TriFalse}
+		require.NoError(t, c.loadProfile())
+		require.Equal(t, config.TriFalse, c.SkipCertValidation)
+	})
+
+	t.Run("default credentials file", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "packer-proxmox"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "packer-proxmox", "credentials"), []byte(testCredentials), 0600))
+		c := ClientConfig{Profile: "lab"}
+		require.NoError(t, c.loadProfile())
+		require.Equal(t, "root@pam", c.Username)
+	})
+
+	cs := []struct {
+		name          string
+		content       string
+		profile       string
+		expectedError string
+	}{
+		{
+			name:          "unknown profile",
+			content:       testCredentials,
+			profile:       "prod",
+			expectedError: `profile "prod" not found in`,
+		},
+		{
+			name:          "unknown setting",
+			content:       "[prod]\nurl = https://prod.example.com:8006/api2/json\n",
+			profile:       "prod",
+			expectedError: `profile "prod": unknown setting "url"`,
+		},
+		{
+			name:          "invalid boolean",
+			content:       "[prod]\ninsecure_skip_tls_verify = maybe\n",
+			profile:       "prod",
+			expectedError: "insecure_skip_tls_verify must be a boolean",
+		},
+		{
+			name:          "setting outside of a profile",
+			content:       "username = root@pam\n[prod]\n",
+			profile:       "prod",
+			expectedError: ":1: setting outside of a profile",
+		},
+		{
+			name:          "duplicate profile",
+			content:       "[prod]\n[prod]\n",
+			profile:       "prod",
+			expectedError: `:2: duplicate profile "prod"`,
+		},
+		{
+			name:          "invalid line",
+			content:       "[prod]\nusername\n",
+			profile:       "prod",
+			expectedError: ":2: expected key = value",
+		},
+	}
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			cc := ClientConfig{Profile: c.profile, CredentialsFile: writeCredentials(t, c.content)}
+			errs := cc.Prepare()
+			require.Len(t, errs, 1)
+			require.ErrorContains(t, errs[0], c.expectedError)
+		})
+	}
+}
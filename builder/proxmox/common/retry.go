@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Calls to the Proxmox API that fail because the API could not be reached,
+// or because the guest is locked by another operation, are retried with an
+// exponential backoff. Only calls that can safely be repeated are retried,
+// such as reading or updating the configuration of a guest, or starting and
+// stopping it.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	api_retry {
+//	  max_attempts    = 5
+//	  initial_backoff = "5s"
+//	  max_backoff     = "1m"
+//	}
+//
+// ```
+type RetryConfig struct {
+	// Maximum number of attempts of a call, the first one included. Defaults
+	// to `3`, `1` disables retries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Delay before the first retry, doubled at every following retry.
+	// Defaults to `2s`.
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// Maximum delay between two attempts. Defaults to `30s`.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// Fraction of the delay randomly added or removed, so that builds
+	// contending for the same lock do not retry at the same time. Between `0`
+	// and `1`, defaults to `0.2`.
+	Jitter float64 `mapstructure:"jitter"`
+}
+
+func (r *RetryConfig) Prepare() []error {
+	var errs []error
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = 3
+	}
+	if r.InitialBackoff == 0 {
+		r.InitialBackoff = 2 * time.Second
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = 30 * time.Second
+	}
+	if r.Jitter == 0 {
+		r.Jitter = 0.2
+	}
+	if r.MaxAttempts < 0 {
+		errs = append(errs, errors.New("api_retry.max_attempts must be positive"))
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		errs = append(errs, errors.New("api_retry.initial_backoff and api_retry.max_backoff must be positive"))
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("api_retry.jitter must be between 0 and 1, got %g", r.Jitter))
+	}
+	return errs
+}
+
+// retryable returns whether a failed call may succeed when repeated. Timeouts
+// are not retried, the operation may still be running.
+func retryable(err error) bool {
+	return errors.Is(err, ErrTransient) || errors.Is(err, ErrLocked)
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable,
+// or the maximum number of attempts is reached. Every retry is logged, with
+// call naming what is retried.
+func (r RetryConfig) Do(call string, fn func() error) error {
+	return r.do(call, retryable, fn)
+}
+
+// do is Do, retrying the errors for which retry returns true.
+func (r RetryConfig) do(call string, retry func(error) bool, fn func() error) error {
+	backoff := r.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.MaxAttempts || !retry(err) {
+			return err
+		}
+		delay := backoff + time.Duration((2*rand.Float64()-1)*r.Jitter*float64(backoff))
+		log.Printf("%s failed (attempt %d of %d), retrying in %s: %s", call, attempt, r.MaxAttempts, delay.Round(time.Millisecond), err)
+		time.Sleep(delay)
+		backoff *= 2
+		if backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+	}
+}
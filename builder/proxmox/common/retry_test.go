@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryConfigDo(t *testing.T) {
+	locked := &APIError{Kind: ErrLocked, Err: errors.New("500 can't lock file")}
+	transient := &APIError{Kind: ErrTransient, Err: errors.New("503 Service Unavailable")}
+	denied := &APIError{Kind: ErrPermissionDenied, Err: errors.New("403 Permission check failed")}
+	timeout := &APIError{Kind: ErrTimeout, Err: errors.New("Wait timeout for: UPID")}
+
+	cs := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedError error
+	}{
+		{
+			name:          "success is not retried",
+			errs:          []error{nil},
+			expectedCalls: 1,
+		},
+		{
+			name:          "locked guest is retried",
+			errs:          []error{locked, locked, nil},
+			expectedCalls: 3,
+		},
+		{
+			name:          "transient error is retried",
+			errs:          []error{transient, nil},
+			expectedCalls: 2,
+		},
+		{
+			name:          "permission denied is not retried",
+			errs:          []error{denied, nil},
+			expectedCalls: 1,
+			expectedError: denied,
+		},
+		{
+			name:          "timeout is not retried",
+			errs:          []error{timeout, nil},
+			expectedCalls: 1,
+			expectedError: timeout,
+		},
+		{
+			name:          "last error after max attempts",
+			errs:          []error{locked, transient, locked, nil},
+			expectedCalls: 3,
+			expectedError: locked,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			retry := RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Jitter: 0.5}
+			calls := 0
+			err := retry.Do("test", func() error {
+				calls++
+				return c.errs[calls-1]
+			})
+			require.Equal(t, c.expectedError, err)
+			require.Equal(t, c.expectedCalls, calls)
+		})
+	}
+}
+
+func TestRetryConfigPrepare(t *testing.T) {
+	retry := RetryConfig{}
+	require.Empty(t, retry.Prepare())
+	require.Equal(t, RetryConfig{MaxAttempts: 3, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second, Jitter: 0.2}, retry)
+
+	retry = RetryConfig{MaxAttempts: -1, Jitter: 1.5}
+	require.Len(t, retry.Prepare(), 2)
+}
+
+func TestClientRetriesLockedGuest(t *testing.T) {
+	attempts := 0
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/nodes/pve/qemu/100/config":
+			attempts++
+			if attempts < 3 {
+				writeStatus(t, rw, 500, "can't lock file '/var/lock/qemu-server/lock-100.conf' - got timeout", `{"data":null}`)
+				return
+			}
+			_, _ = rw.Write([]byte(`{"data":null}`))
+		case "/nodes/pve/qemu/100/template":
+			attempts++
+			writeStatus(t, rw, 403, "Permission check failed (/vms/100, VM.Allocate)", `{"data":null}`)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+	client := newTestClient(t, mockAPI.URL)
+	client.retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	vmRef := proxmox.NewVmRef(100)
+	vmRef.SetNode("pve")
+	vmRef.SetVmType("qemu")
+
+	_, err := client.SetVmConfig(vmRef, map[string]interface{}{"description": "test"})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = client.CreateTemplate(vmRef)
+	require.ErrorIs(t, err, ErrPermissionDenied)
+	require.Equal(t, 1, attempts)
+}
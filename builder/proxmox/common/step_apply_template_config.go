@@ -0,0 +1,199 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+// stepApplyTemplateConfig applies template_config once provisioning is done,
+// before the VM is converted into a template. Only the settings differing from
+// the VM configuration are changed, in a single update.
+type stepApplyTemplateConfig struct{}
+
+type templateConfigurer interface {
+	GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error)
+	SetVmConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
+}
+
+var _ templateConfigurer = &Client{}
+
+func (s *stepApplyTemplateConfig) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	if c.TemplateConfig.isEmpty() {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(templateConfigurer)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	vmParams, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		err := fmt.Errorf("error fetching VM config: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	changes := c.TemplateConfig.changes(vmRef.VmId(), vmParams)
+	if len(changes) == 0 {
+		ui.Say("The VM already matches template_config")
+		return multistep.ActionContinue
+	}
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ui.Sayf("Applying template_config: %s", strings.Join(keys, ", "))
+	if _, err := client.SetVmConfig(vmRef, changes); err != nil {
+		err := fmt.Errorf("Error applying template_config: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+var (
+	netKeyRe    = regexp.MustCompile(`^net\d+$`)
+	serialKeyRe = regexp.MustCompile(`^serial\d+$`)
+)
+
+// changes returns the settings of template_config differing from the VM
+// configuration vmParams. Network adapters and serial ports beyond the ones of
+// template_config are deleted.
+func (tc *templateHardwareConfig) changes(vmID int, vmParams map[string]interface{}) map[string]interface{} {
+	settings := map[string]string{}
+	var deleteKeys []string
+
+	if tc.Memory != 0 {
+		settings["memory"] = strconv.Itoa(tc.Memory)
+	}
+	if tc.Cores != 0 {
+		settings["cores"] = strconv.Itoa(tc.Cores)
+	}
+	if tc.VGA != (vgaConfig{}) {
+		vga := []string{tc.VGA.Type}
+		if tc.VGA.Memory > 0 {
+			vga = append(vga, fmt.Sprintf("memory=%d", tc.VGA.Memory))
+		}
+		settings["vga"] = strings.Trim(strings.Join(vga, ","), ",")
+	}
+	switch tc.Onboot {
+	case config.TriTrue:
+		settings["onboot"] = "1"
+	case config.TriFalse:
+		settings["onboot"] = "0"
+	}
+	if tc.Tags != "" {
+		settings["tags"] = tc.Tags
+	}
+	if tc.Boot != "" {
+		settings["boot"] = tc.Boot
+	}
+	if len(tc.Serials) > 0 {
+		for idx, serial := range tc.Serials {
+			settings[fmt.Sprintf("serial%d", idx)] = serial
+		}
+		deleteKeys = append(deleteKeys, extraKeys(vmParams, serialKeyRe, settings)...)
+	}
+	if len(tc.NICs) > 0 {
+		for idx, nic := range tc.NICs {
+			key := fmt.Sprintf("net%d", idx)
+			current, _ := vmParams[key].(string)
+			settings[key] = nicProperties(vmID, idx, nic, current)
+		}
+		deleteKeys = append(deleteKeys, extraKeys(vmParams, netKeyRe, settings)...)
+	}
+
+	changes := map[string]interface{}{}
+	for key, value := range settings {
+		if current, ok := vmParams[key]; !ok || !sameProperties(fmt.Sprint(current), value) {
+			changes[key] = value
+		}
+	}
+	if len(deleteKeys) > 0 {
+		sort.Strings(deleteKeys)
+		changes["delete"] = strings.Join(deleteKeys, ",")
+	}
+	return changes
+}
+
+// extraKeys returns the keys of vmParams matching re and missing from
+// settings.
+func extraKeys(vmParams map[string]interface{}, re *regexp.Regexp, settings map[string]string) []string {
+	var keys []string
+	for key := range vmParams {
+		if _, ok := settings[key]; re.MatchString(key) && !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// nicProperties formats the network adapter like Proxmox does. Without
+// mac_address, the adapter keeps the MAC address of current, the adapter of
+// the VM at the same index.
+func nicProperties(vmID int, idx int, nic NICConfig, current string) string {
+	mac := nic.MACAddress
+	switch mac {
+	case "":
+		if model, currentMAC, ok := strings.Cut(strings.Split(current, ",")[0], "="); ok && model != "bridge" {
+			mac = currentMAC
+		}
+	case "repeatable":
+		// Same as the MAC address generated by proxmox-api-go
+		pairing := vmID<<5 | idx
+		mac = strings.ToUpper(net.HardwareAddr{0x00, 0x18, 0x59, byte(pairing >> 16), byte(pairing >> 8), byte(pairing)}.String())
+	}
+	properties := []string{nic.Model}
+	if mac != "" {
+		properties[0] += "=" + mac
+	}
+	properties = append(properties, "bridge="+nic.Bridge)
+	if nic.Firewall {
+		properties = append(properties, "firewall=1")
+	}
+	if nic.MTU > 0 {
+		properties = append(properties, fmt.Sprintf("mtu=%d", nic.MTU))
+	}
+	if nic.PacketQueues > 0 {
+		properties = append(properties, fmt.Sprintf("queues=%d", nic.PacketQueues))
+	}
+	if nic.VLANTag != "" {
+		properties = append(properties, "tag="+nic.VLANTag)
+	}
+	return strings.Join(properties, ",")
+}
+
+// sameProperties reports whether the comma separated properties a and b are
+// the same, regardless of their order.
+func sameProperties(a, b string) bool {
+	pa, pb := strings.Split(a, ","), strings.Split(b, ",")
+	if len(pa) != len(pb) {
+		return false
+	}
+	sort.Strings(pa)
+	sort.Strings(pb)
+	for i := range pa {
+		if !strings.EqualFold(pa[i], pb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *stepApplyTemplateConfig) Cleanup(state multistep.StateBag) {}
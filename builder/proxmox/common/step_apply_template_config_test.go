@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/stretchr/testify/require"
+)
+
+type configurerMock struct {
+	vmParams  map[string]interface{}
+	getErr    error
+	setErr    error
+	setCalls  int
+	setParams map[string]interface{}
+}
+
+func (m *configurerMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error) {
+	return m.vmParams, m.getErr
+}
+func (m *configurerMock) SetVmConfig(vmRef *proxmox.VmRef, params map[string]interface{}) (interface{}, error) {
+	m.setCalls++
+	m.setParams = params
+	return nil, m.setErr
+}
+
+var _ templateConfigurer = &configurerMock{}
+
+func TestApplyTemplateConfig(t *testing.T) {
+	buildVM := map[string]interface{}{
+		"memory":  8192.0,
+		"cores":   4.0,
+		"net0":    "virtio=BC:24:11:00:00:01,bridge=vmbr1,tag=42",
+		"net1":    "e1000=BC:24:11:00:00:02,bridge=vmbr0",
+		"serial0": "socket",
+		"scsi0":   "local-lvm:vm-100-disk-0,size=8G",
+		"ide2":    "local:iso/debian.iso,media=cdrom",
+		"onboot":  0.0,
+	}
+
+	cs := []struct {
+		name            string
+		templateConfig  templateHardwareConfig
+		getErr          error
+		setErr          error
+		expectedChanges map[string]interface{}
+		expectedAction  multistep.StepAction
+	}{
+		{
+			name:           "nothing to apply without template_config",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "settings matching the VM are not changed",
+			templateConfig: templateHardwareConfig{Memory: 8192, Cores: 4, Onboot: config.TriFalse},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "differing settings are applied at once",
+			templateConfig: templateHardwareConfig{
+				Memory: 2048,
+				Cores:  4,
+				NICs:   []NICConfig{{Model: "virtio", Bridge: "vmbr0", Firewall: true}},
+				VGA:    vgaConfig{Type: "serial0"},
+				Onboot: config.TriTrue,
+				Tags:   "debian;golden",
+				Boot:   "order=scsi0",
+			},
+			expectedChanges: map[string]interface{}{
+				"memory": "2048",
+				"net0":   "virtio=BC:24:11:00:00:01,bridge=vmbr0,firewall=1",
+				"vga":    "serial0",
+				"onboot": "1",
+				"tags":   "debian;golden",
+				"boot":   "order=scsi0",
+				"delete": "net1",
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "network adapters are compared regardless of order",
+			templateConfig: templateHardwareConfig{
+				NICs: []NICConfig{
+					{Model: "virtio", Bridge: "vmbr1", VLANTag: "42"},
+					{Model: "e1000", Bridge: "vmbr0", MACAddress: "bc:24:11:00:00:02"},
+				},
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "repeatable MAC addresses are generated",
+			templateConfig: templateHardwareConfig{
+				NICs: []NICConfig{{Model: "virtio", Bridge: "vmbr0", MACAddress: "repeatable"}},
+			},
+			expectedChanges: map[string]interface{}{
+				"net0":   "virtio=00:18:59:00:0C:80,bridge=vmbr0",
+				"delete": "net1",
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "serials are replaced",
+			templateConfig: templateHardwareConfig{Serials: []string{"/dev/ttyS1", "socket"}},
+			expectedChanges: map[string]interface{}{
+				"serial0": "/dev/ttyS1",
+				"serial1": "socket",
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "GetVmConfig error should return halt",
+			templateConfig: templateHardwareConfig{Memory: 2048},
+			getErr:         fmt.Errorf("some error"),
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name:            "SetVmConfig error should return halt",
+			templateConfig:  templateHardwareConfig{Memory: 2048},
+			setErr:          fmt.Errorf("some error"),
+			expectedChanges: map[string]interface{}{"memory": "2048"},
+			expectedAction:  multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &configurerMock{vmParams: buildVM, getErr: c.getErr, setErr: c.setErr}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{TemplateConfig: c.templateConfig})
+			state.Put("proxmoxClient", client)
+			state.Put("vmRef", proxmox.NewVmRef(100))
+
+			step := stepApplyTemplateConfig{}
+			require.Equal(t, c.expectedAction, step.Run(context.TODO(), state))
+			if c.expectedChanges == nil {
+				require.Zero(t, client.setCalls)
+				return
+			}
+			require.Equal(t, 1, client.setCalls)
+			require.Equal(t, c.expectedChanges, client.setParams)
+		})
+	}
+}
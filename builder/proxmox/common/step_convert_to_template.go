@@ -13,35 +13,40 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// stepConvertToTemplate takes the running VM configured in earlier steps, stops it, and
-// converts it into a Proxmox template.
+// stepConvertToTemplate takes the VM stopped in earlier steps and
+// converts it into a Proxmox template. When the VM is outside of template_id_range, it is
+// cloned into the range first, as Proxmox cannot change the ID of a guest.
 //
 // It sets the template_id state which is used for Artifact lookup.
 type stepConvertToTemplate struct{}
 
 type templateConverter interface {
-	ShutdownVm(*proxmox.VmRef) (string, error)
-	CreateTemplate(*proxmox.VmRef) error
+	DeleteVm(*proxmox.VmRef) (string, error)
+	resourceLister
+	TaskClient
 }
 
-var _ templateConverter = &proxmox.Client{}
+var _ templateConverter = &Client{}
 
 func (s *stepConvertToTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	client := state.Get("proxmoxClient").(templateConverter)
+	c := state.Get("config").(*Config)
 	vmRef := state.Get("vmRef").(*proxmox.VmRef)
 
-	ui.Say("Stopping VM")
-	_, err := client.ShutdownVm(vmRef)
-	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, could not stop: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	if c.templateIDRange.isSet() && !c.templateIDRange.contains(vmRef.VmId()) {
+		var err error
+		vmRef, err = cloneToTemplateRange(ctx, state, c, vmRef)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	ui.Say("Converting VM to template")
-	err = client.CreateTemplate(vmRef)
+	tracker := &TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
+	_, err := tracker.Run(ctx, nil, templateURL(vmRef))
 	if err != nil {
 		err := fmt.Errorf("Error converting VM to template: %s", err)
 		state.Put("error", err)
@@ -54,4 +59,78 @@ func (s *stepConvertToTemplate) Run(ctx context.Context, state multistep.StateBa
 	return multistep.ActionContinue
 }
 
+// cloneToTemplateRange clones the stopped VM to the first free ID of
+// template_id_range and deletes it, returning the clone.
+func cloneToTemplateRange(ctx context.Context, state multistep.StateBag, c *Config, vmRef *proxmox.VmRef) (*proxmox.VmRef, error) {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(templateConverter)
+	// The clone copies the disks of the VM
+	tracker := &TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+
+	cloneRef, err := cloneGuest(ctx, ui, tracker, vmRef, c.Pool, "", func() (int, error) {
+		return firstFreeID(client, c.templateIDRange)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// From now on the clone is the VM cleaned up when the build fails
+	state.Put("vmRef", cloneRef)
+
+	ui.Sayf("Deleting VM %d", vmRef.VmId())
+	if _, err := client.DeleteVm(vmRef); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting VM %d. Please delete it manually: %s", vmRef.VmId(), err))
+	}
+	return cloneRef, nil
+}
+
+// cloneGuest makes a full clone of the stopped guest to the ID returned by
+// nextID, which is called again when another build takes the ID first. The
+// clone is named name, or as Proxmox names clones when empty.
+func cloneGuest(ctx context.Context, ui packersdk.Ui, tracker *TaskTracker, vmRef *proxmox.VmRef, pool string, name string, nextID func() (int, error)) (*proxmox.VmRef, error) {
+	vmType := vmRef.GetVmType()
+	if vmType == "" {
+		vmType = "qemu"
+	}
+	previous := 0
+	for i := 1; ; i++ {
+		id, err := nextID()
+		if err != nil {
+			return nil, fmt.Errorf("Error getting an ID to clone %d to: %s", vmRef.VmId(), err)
+		}
+		if id == previous {
+			return nil, fmt.Errorf("Error cloning %d: ID %d is already allocated", vmRef.VmId(), id)
+		}
+		ui.Sayf("Cloning %d to ID %d", vmRef.VmId(), id)
+		params := map[string]interface{}{
+			"newid": id,
+			"full":  true,
+		}
+		if pool != "" {
+			params["pool"] = pool
+		}
+		if name != "" && vmType == "lxc" {
+			params["hostname"] = name
+		} else if name != "" {
+			params["name"] = name
+		}
+		_, err = tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/%s/%d/clone", vmRef.Node(), vmType, vmRef.VmId()))
+		if err == nil {
+			cloneRef := proxmox.NewVmRef(id)
+			cloneRef.SetNode(vmRef.Node())
+			cloneRef.SetVmType(vmType)
+			if pool != "" {
+				cloneRef.SetPool(pool)
+			}
+			return cloneRef, nil
+		}
+		// Another build may have taken the ID in the meantime
+		if isDuplicateIDError(err) && i < maxDuplicateIDRetries {
+			ui.Sayf("ID %d was already allocated, retrying", id)
+			previous = id
+			continue
+		}
+		return nil, fmt.Errorf("Error cloning %d to ID %d: %s", vmRef.VmId(), id, err)
+	}
+}
+
 func (s *stepConvertToTemplate) Cleanup(state multistep.StateBag) {}
@@ -6,23 +6,33 @@ package proxmox
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
 )
 
 type converterMock struct {
-	shutdownVm     func(*proxmox.VmRef) (string, error)
-	createTemplate func(*proxmox.VmRef) error
+	*taskClientMock
+	createTemplate func(url string) error
+	deleteVm       func(*proxmox.VmRef) (string, error)
+	listGuests     func() []interface{}
 }
 
-func (m converterMock) ShutdownVm(r *proxmox.VmRef) (string, error) {
-	return m.shutdownVm(r)
+func (m converterMock) DeleteVm(r *proxmox.VmRef) (string, error) {
+	return m.deleteVm(r)
 }
-func (m converterMock) CreateTemplate(r *proxmox.VmRef) error {
-	return m.createTemplate(r)
+func (m converterMock) GetResourceList(resourceType string) ([]interface{}, error) {
+	return m.listGuests(), nil
+}
+func (m converterMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	if err := m.createTemplate(url); err != nil {
+		return "", err
+	}
+	return `{"data":null}`, nil
 }
 
 var _ templateConverter = converterMock{}
@@ -30,7 +40,6 @@ var _ templateConverter = converterMock{}
 func TestConvertToTemplate(t *testing.T) {
 	cs := []struct {
 		name                     string
-		shutdownErr              error
 		expectCallCreateTemplate bool
 		createTemplateErr        error
 		expectedAction           multistep.StepAction
@@ -42,17 +51,10 @@ func TestConvertToTemplate(t *testing.T) {
 			expectedAction:           multistep.ActionContinue,
 			expectTemplateIdSet:      true,
 		},
-		{
-			name:                     "when shutdown fails, don't try to create template and halt",
-			shutdownErr:              fmt.Errorf("failed to stop vm"),
-			expectCallCreateTemplate: false,
-			expectedAction:           multistep.ActionHalt,
-			expectTemplateIdSet:      false,
-		},
 		{
 			name:                     "when create template fails, halt",
 			expectCallCreateTemplate: true,
-			createTemplateErr:        fmt.Errorf("failed to stop vm"),
+			createTemplateErr:        fmt.Errorf("failed to convert vm"),
 			expectedAction:           multistep.ActionHalt,
 			expectTemplateIdSet:      false,
 		},
@@ -63,15 +65,9 @@ func TestConvertToTemplate(t *testing.T) {
 	for _, c := range cs {
 		t.Run(c.name, func(t *testing.T) {
 			converter := converterMock{
-				shutdownVm: func(r *proxmox.VmRef) (string, error) {
-					if r.VmId() != vmid {
-						t.Errorf("ShutdownVm called with unexpected id, expected %d, got %d", vmid, r.VmId())
-					}
-					return "", c.shutdownErr
-				},
-				createTemplate: func(r *proxmox.VmRef) error {
-					if r.VmId() != vmid {
-						t.Errorf("CreateTemplate called with unexpected id, expected %d, got %d", vmid, r.VmId())
+				createTemplate: func(url string) error {
+					if url != "/nodes/pve/qemu/123/template" {
+						t.Errorf("Template conversion called with unexpected url %s", url)
 					}
 					if !c.expectCallCreateTemplate {
 						t.Error("Did not expect CreateTemplate to be called")
@@ -83,7 +79,10 @@ func TestConvertToTemplate(t *testing.T) {
 
 			state := new(multistep.BasicStateBag)
 			state.Put("ui", packersdk.TestUi(t))
-			state.Put("vmRef", proxmox.NewVmRef(vmid))
+			vmRef := proxmox.NewVmRef(vmid)
+			vmRef.SetNode("pve")
+			state.Put("vmRef", vmRef)
+			state.Put("config", &Config{})
 			state.Put("proxmoxClient", converter)
 
 			step := stepConvertToTemplate{}
@@ -104,3 +103,110 @@ func TestConvertToTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertToTemplateInTemplateIDRange(t *testing.T) {
+	cs := []struct {
+		name           string
+		vmid           int
+		guests         []int
+		takenOnClone   []int
+		expectedPosts  []string
+		expectedDelete bool
+		expectedAction multistep.StepAction
+		expectedID     int
+	}{
+		{
+			name:           "VM inside of the range is converted in place",
+			vmid:           9001,
+			guests:         []int{9000, 9001},
+			expectedPosts:  []string{"/nodes/pve/qemu/9001/template"},
+			expectedAction: multistep.ActionContinue,
+			expectedID:     9001,
+		},
+		{
+			name:   "VM outside of the range is cloned to the first free ID",
+			vmid:   90000,
+			guests: []int{9000, 9001, 9003, 90000},
+			expectedPosts: []string{
+				"/nodes/pve/qemu/90000/clone",
+				"/nodes/pve/qemu/9002/template",
+			},
+			expectedDelete: true,
+			expectedAction: multistep.ActionContinue,
+			expectedID:     9002,
+		},
+		{
+			name:         "ID taken while cloning is retried",
+			vmid:         90000,
+			guests:       []int{9000, 90000},
+			takenOnClone: []int{9001},
+			expectedPosts: []string{
+				"/nodes/pve/qemu/90000/clone",
+				"/nodes/pve/qemu/90000/clone",
+				"/nodes/pve/qemu/9002/template",
+			},
+			expectedDelete: true,
+			expectedAction: multistep.ActionContinue,
+			expectedID:     9002,
+		},
+		{
+			name:           "full range halts",
+			vmid:           90000,
+			guests:         []int{9000, 9001, 9002, 9003, 90000},
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			var posts []string
+			deleted := false
+			converter := converterMock{
+				deleteVm: func(r *proxmox.VmRef) (string, error) {
+					if r.VmId() != c.vmid {
+						t.Errorf("DeleteVm called with unexpected id, expected %d, got %d", c.vmid, r.VmId())
+					}
+					deleted = true
+					return "", nil
+				},
+			}
+			converter.createTemplate = func(url string) error {
+				posts = append(posts, url)
+				if len(c.takenOnClone) > 0 && strings.HasSuffix(url, "/clone") {
+					id := c.takenOnClone[0]
+					c.takenOnClone = c.takenOnClone[1:]
+					c.guests = append(c.guests, id)
+					return ClassifyError(fmt.Errorf("500 unable to create VM %d - VM %d already exists on node 'pve'", id, id), "")
+				}
+				return nil
+			}
+			// Guests are listed at the time of the call, so that IDs taken by a
+			// concurrent build show up
+			converter.listGuests = func() []interface{} {
+				var guests []interface{}
+				for _, id := range c.guests {
+					guests = append(guests, map[string]interface{}{"vmid": float64(id)})
+				}
+				return guests
+			}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			vmRef := proxmox.NewVmRef(c.vmid)
+			vmRef.SetNode("pve")
+			state.Put("vmRef", vmRef)
+			state.Put("config", &Config{templateIDRange: idRange{min: 9000, max: 9003}})
+			state.Put("proxmoxClient", converter)
+
+			step := stepConvertToTemplate{}
+			action := step.Run(context.TODO(), state)
+			require.Equal(t, c.expectedAction, action)
+			require.Equal(t, c.expectedPosts, posts)
+			require.Equal(t, c.expectedDelete, deleted)
+			if c.expectedAction == multistep.ActionContinue {
+				require.Equal(t, c.expectedID, state.Get("template_id"))
+				require.Equal(t, c.expectedID, state.Get("vmRef").(*proxmox.VmRef).VmId())
+			}
+		})
+	}
+}
@@ -25,7 +25,7 @@ type stepDownloadISOOnPVE struct {
 
 func (s *stepDownloadISOOnPVE) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	var isoStoragePath string
-	isoStoragePath, err := DownloadISOOnPVE(state, s.ISO.ISOUrls, s.ISO.ISOChecksum, s.ISO.ISOStoragePool)
+	isoStoragePath, err := DownloadISOOnPVE(ctx, state, s.ISO.ISOUrls, s.ISO.ISOChecksum, s.ISO.ISOStoragePool)
 
 	// Abort if no ISO can be downloaded
 	if err != nil {
@@ -44,10 +44,11 @@ func (s *stepDownloadISOOnPVE) Run(ctx context.Context, state multistep.StateBag
 // If a download was successful it skips the additonal downlaod mirrors and returns the path to the iso on the node.
 //
 // Returns: When successful, the path to the iso on the node, else an empty string.
-func DownloadISOOnPVE(state multistep.StateBag, ISOUrls []string, ISOChecksum string, ISOStoragePool string) (string, error) {
+func DownloadISOOnPVE(ctx context.Context, state multistep.StateBag, ISOUrls []string, ISOChecksum string, ISOStoragePool string) (string, error) {
 	ui := state.Get("ui").(packersdk.Ui)
-	client := state.Get("proxmoxClient").(*proxmox.Client)
+	client := state.Get("proxmoxClient").(TaskClient)
 	c := state.Get("config").(*Config)
+	tracker := &TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
 
 	// Generate ISOConfig configuration attributes in the format defined for packer-plugin-sdk
 	// and use go-getter to generate parameters compatible with the Proxmox-API.
@@ -79,8 +80,22 @@ func DownloadISOOnPVE(state multistep.StateBag, ISOUrls []string, ISOChecksum st
 			Checksum:          checksum,
 		}
 
+		params := map[string]interface{}{
+			"content":  "iso",
+			"url":      isoConfig.DownloadUrl,
+			"filename": isoConfig.Filename,
+		}
+		if isoConfig.Checksum != "" {
+			params["checksum-algorithm"] = isoConfig.ChecksumAlgorithm
+			params["checksum"] = isoConfig.Checksum
+		}
+
 		log.Printf("[INFO] - beginning download of %s to node %s", isoConfig.DownloadUrl, isoConfig.Node)
-		err := proxmox.DownloadIsoFromUrl(client, isoConfig)
+		ui.Say(fmt.Sprintf("Downloading %s to node %s", isoConfig.DownloadUrl, isoConfig.Node))
+		_, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/storage/%s/download-url", isoConfig.Node, isoConfig.Storage))
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		// On error continues with the next URL and logs the error
 		if err != nil {
 			log.Printf("[ERROR] - failed to download iso from %s: %s", isoConfig.DownloadUrl, err)
@@ -5,13 +5,16 @@ package proxmox
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 // stepFinalizeTemplateConfig does any required modifications to the configuration _after_
@@ -22,9 +25,10 @@ type stepFinalizeTemplateConfig struct{}
 type templateFinalizer interface {
 	GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error)
 	SetVmConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
+	SetLxcConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
 }
 
-var _ templateFinalizer = &proxmox.Client{}
+var _ templateFinalizer = &Client{}
 
 func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
@@ -34,14 +38,28 @@ func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.St
 
 	changes := make(map[string]interface{})
 
-	changes["name"] = c.VMName
+	// Containers are named through their hostname
+	nameKey := "name"
+	isContainer := vmRef.GetVmType() == "lxc"
+	if isContainer {
+		nameKey = "hostname"
+	}
+
+	changes[nameKey] = c.VMName
 	if c.TemplateName != "" {
-		changes["name"] = c.TemplateName
+		changes[nameKey] = c.TemplateName
 	}
 
 	// During build, the description is "Packer ephemeral build VM", so if no description is
 	// set, we need to clear it
-	changes["description"] = c.TemplateDescription
+	description, err := templateDescription(state, c)
+	if err != nil {
+		err := fmt.Errorf("Error rendering template_description: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	changes["description"] = description
 
 	vmParams, err := client.GetVmConfig(vmRef)
 	if err != nil {
@@ -51,6 +69,11 @@ func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.St
 		return multistep.ActionHalt
 	}
 
+	if c.TemplateTags != "" {
+		tags, _ := vmParams["tags"].(string)
+		changes["tags"] = mergeTags(tags, c.TemplateTags)
+	}
+
 	if c.CloudInit {
 		cloudInitStoragePool := c.CloudInitStoragePool
 		if cloudInitStoragePool == "" {
@@ -139,7 +162,11 @@ func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.St
 	changes["delete"] = strings.Join(deleteItems, ",")
 
 	if len(changes) > 0 {
-		_, err := client.SetVmConfig(vmRef, changes)
+		setConfig := client.SetVmConfig
+		if isContainer {
+			setConfig = client.SetLxcConfig
+		}
+		_, err := setConfig(vmRef, changes)
 		if err != nil {
 			err := fmt.Errorf("Error updating template: %s", err)
 			state.Put("error", err)
@@ -151,4 +178,73 @@ func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.St
 	return multistep.ActionContinue
 }
 
+// templateDescriptionData is the data available to template_description, also
+// appended to the description as JSON with template_description_json.
+type templateDescriptionData struct {
+	BuildName   string `json:"build_name"`
+	BuilderType string `json:"builder_type"`
+	// Time the template was finalized, in RFC 3339 format
+	Timestamp       string `json:"timestamp"`
+	TemplateName    string `json:"template_name,omitempty"`
+	TemplateVersion string `json:"template_version,omitempty"`
+	// Checksum of the boot ISO of the proxmox-iso builder
+	SourceISOChecksum string `json:"source_iso_checksum,omitempty"`
+	// VMID of the source of the proxmox-clone builder
+	CloneSourceVMID int               `json:"clone_source_vm_id,omitempty"`
+	Provisioners    []string          `json:"provisioners,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+// templateDescription renders template_description with the build data, and
+// appends the data as a JSON code block when template_description_json is set.
+func templateDescription(state multistep.StateBag, c *Config) (string, error) {
+	data := &templateDescriptionData{
+		BuildName:       c.PackerBuildName,
+		BuilderType:     c.PackerBuilderType,
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
+		TemplateName:    c.TemplateName,
+		TemplateVersion: c.TemplateVersion,
+		Provisioners:    c.TemplateProvisioners,
+		Metadata:        c.TemplateMetadata,
+	}
+	if checksum, ok := state.GetOk("source_iso_checksum"); ok {
+		data.SourceISOChecksum = checksum.(string)
+	}
+	if id, ok := state.GetOk("clone_source_vm_id"); ok {
+		data.CloneSourceVMID = id.(int)
+	}
+
+	ctx := c.Ctx
+	ctx.Data = data
+	description, err := interpolate.Render(c.TemplateDescription, &ctx)
+	if err != nil {
+		return "", err
+	}
+	if !c.TemplateDescriptionJSON {
+		return description, nil
+	}
+	block, err := json.MarshalIndent(map[string]interface{}{"packer": data}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if description != "" {
+		description += "\n\n"
+	}
+	return description + "```json\n" + string(block) + "\n```\n", nil
+}
+
+// mergeTags appends the tags missing from the semicolon separated list tags.
+func mergeTags(tags string, extra string) string {
+	merged := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(tags+";"+extra, ";") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return strings.Join(merged, ";")
+}
+
 func (s *stepFinalizeTemplateConfig) Cleanup(state multistep.StateBag) {}
@@ -6,13 +6,16 @@ package proxmox
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
 )
 
 type finalizerMock struct {
@@ -26,6 +29,9 @@ func (m finalizerMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, erro
 func (m finalizerMock) SetVmConfig(vmref *proxmox.VmRef, c map[string]interface{}) (interface{}, error) {
 	return m.setConfig(c)
 }
+func (m finalizerMock) SetLxcConfig(vmref *proxmox.VmRef, c map[string]interface{}) (interface{}, error) {
+	return m.setConfig(c)
+}
 
 var _ templateFinalizer = finalizerMock{}
 
@@ -172,6 +178,33 @@ func TestTemplateFinalize(t *testing.T) {
 			setConfigErr:        fmt.Errorf("some error"),
 			expectedAction:      multistep.ActionHalt,
 		},
+		{
+			name: "description is rendered and template tags are added",
+			builderConfig: &Config{
+				PackerConfig:        common.PackerConfig{PackerBuildName: "debian"},
+				TemplateDescription: `{{ .BuildName }} at {{ index .Metadata "git_sha" }}`,
+				TemplateMetadata:    map[string]string{"git_sha": "0123abc"},
+				TemplateTags:        "golden;debian-12",
+			},
+			initialVMConfig: map[string]interface{}{
+				"name": "dummy",
+				"tags": "debian-12;build",
+			},
+			expectCallSetConfig: true,
+			expectedVMConfig: map[string]interface{}{
+				"description": "debian at 0123abc",
+				"tags":        "debian-12;build;golden",
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "invalid description should return halt",
+			builderConfig: &Config{
+				TemplateDescription: `{{ .Unknown }}`,
+			},
+			expectCallSetConfig: false,
+			expectedAction:      multistep.ActionHalt,
+		},
 		{
 			name:          "find and remove unused disks",
 			builderConfig: &Config{},
@@ -226,3 +259,31 @@ func TestTemplateFinalize(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateDescriptionJSON(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("source_iso_checksum", "sha256:d0e1")
+	c := &Config{
+		PackerConfig:            common.PackerConfig{PackerBuildName: "debian", PackerBuilderType: "proxmox-iso"},
+		TemplateName:            "debian-12",
+		TemplateDescription:     "Debian 12",
+		TemplateDescriptionJSON: true,
+		TemplateProvisioners:    []string{"shell"},
+	}
+
+	description, err := templateDescription(state, c)
+	require.NoError(t, err)
+	description = regexp.MustCompile(`"timestamp": "[^"]+"`).ReplaceAllString(description, `"timestamp": "<timestamp>"`)
+	require.Equal(t, "Debian 12\n\n```json\n"+`{
+  "packer": {
+    "build_name": "debian",
+    "builder_type": "proxmox-iso",
+    "timestamp": "<timestamp>",
+    "template_name": "debian-12",
+    "source_iso_checksum": "sha256:d0e1",
+    "provisioners": [
+      "shell"
+    ]
+  }
+}`+"\n```\n", description)
+}
@@ -22,13 +22,19 @@ type CloudInitDriveRemover interface {
 	SetVmConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
 }
 
-var _ CloudInitDriveRemover = &proxmox.Client{}
+var _ CloudInitDriveRemover = &Client{}
 
 func (s *stepRemoveCloudInitDrive) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	client := state.Get("proxmoxClient").(CloudInitDriveRemover)
 	vmRef := state.Get("vmRef").(*proxmox.VmRef)
 
+	// Containers have no cloud-init drive, and their nameserver and
+	// searchdomain settings are part of the container configuration.
+	if vmRef.GetVmType() == "lxc" {
+		return multistep.ActionContinue
+	}
+
 	changes := make(map[string]interface{})
 	delete := []string{}
 
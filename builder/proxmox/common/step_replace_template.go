@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepReplaceTemplate replaces the existing template found by stepStartVM
+// with `force_mode = "replace"`, once the new template is converted and
+// finalized, so that a failure before leaves the replaced template alone. The
+// replaced template is deleted, or renamed when kept, and the new template is
+// moved to its ID when force_keep_vm_id is set.
+//
+// It updates the vmRef and template_id states when the new template is moved.
+type stepReplaceTemplate struct{}
+
+type templateReplacer interface {
+	templateConverter
+	GetNextID(int) (int, error)
+	GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error)
+	SetVmConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
+}
+
+var _ templateReplacer = &Client{}
+
+func (s *stepReplaceTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	replacedUntyped, ok := state.GetOk("replacedTemplate")
+	if !ok {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(templateReplacer)
+	c := state.Get("config").(*Config)
+	replaced := replacedUntyped.(*proxmox.VmRef)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+	tracker := &TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
+	// Clones copy the disks of the templates
+	transfer := &TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if c.ForceKeepReplaced {
+		if err := keepReplacedTemplate(ctx, ui, client, tracker, transfer, c, replaced); err != nil {
+			return halt(fmt.Errorf("Error keeping replaced template %d, the new template is %d: %s", replaced.VmId(), vmRef.VmId(), err))
+		}
+	} else {
+		ui.Sayf("Deleting replaced template %d", replaced.VmId())
+		if _, err := client.DeleteVm(replaced); err != nil {
+			return halt(fmt.Errorf("Error deleting replaced template %d, the new template is %d: %s", replaced.VmId(), vmRef.VmId(), err))
+		}
+	}
+
+	if !c.ForceKeepVMID {
+		return multistep.ActionContinue
+	}
+	// The template is finalized by now, it keeps its name in the clone
+	name := c.VMName
+	if c.TemplateName != "" {
+		name = c.TemplateName
+	}
+	templateRef, err := cloneGuest(ctx, ui, transfer, vmRef, c.Pool, name, func() (int, error) {
+		return replaced.VmId(), nil
+	})
+	if err == nil {
+		_, err = tracker.Run(ctx, nil, templateURL(templateRef))
+	}
+	if err != nil {
+		return halt(fmt.Errorf("Error moving the new template %d to ID %d: %s", vmRef.VmId(), replaced.VmId(), err))
+	}
+	state.Put("vmRef", templateRef)
+	log.Printf("template_id: %d", templateRef.VmId())
+	state.Put("template_id", templateRef.VmId())
+
+	ui.Sayf("Deleting template %d", vmRef.VmId())
+	if _, err := client.DeleteVm(vmRef); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting template %d. Please delete it manually: %s", vmRef.VmId(), err))
+	}
+	return multistep.ActionContinue
+}
+
+// keepReplacedTemplate renames the replaced template, and moves it to a free
+// ID when its ID goes to the new template.
+func keepReplacedTemplate(ctx context.Context, ui packersdk.Ui, client templateReplacer, tracker *TaskTracker, transfer *TaskTracker, c *Config, replaced *proxmox.VmRef) error {
+	nameKey := "name"
+	if replaced.GetVmType() == "lxc" {
+		nameKey = "hostname"
+	}
+	config, err := client.GetVmConfig(replaced)
+	if err != nil {
+		return err
+	}
+	name, _ := config[nameKey].(string)
+	name = fmt.Sprintf("%s-replaced-%s", name, time.Now().UTC().Format("20060102150405"))
+
+	if !c.ForceKeepVMID {
+		ui.Sayf("Renaming replaced template %d to %s", replaced.VmId(), name)
+		_, err := client.SetVmConfig(replaced, map[string]interface{}{nameKey: name})
+		return err
+	}
+
+	kept, err := cloneGuest(ctx, ui, transfer, replaced, c.Pool, name, func() (int, error) {
+		if c.templateIDRange.isSet() {
+			return firstFreeID(client, c.templateIDRange)
+		}
+		return client.GetNextID(0)
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tracker.Run(ctx, nil, templateURL(kept)); err != nil {
+		return err
+	}
+	ui.Sayf("Deleting replaced template %d", replaced.VmId())
+	_, err = client.DeleteVm(replaced)
+	return err
+}
+
+// templateURL returns the API path converting the guest to a template.
+func templateURL(vmRef *proxmox.VmRef) string {
+	return guestURL(vmRef) + "/template"
+}
+
+// guestURL returns the API path of the guest.
+func guestURL(vmRef *proxmox.VmRef) string {
+	vmType := vmRef.GetVmType()
+	if vmType == "" {
+		vmType = "qemu"
+	}
+	return fmt.Sprintf("/nodes/%s/%s/%d", vmRef.Node(), vmType, vmRef.VmId())
+}
+
+func (s *stepReplaceTemplate) Cleanup(state multistep.StateBag) {}
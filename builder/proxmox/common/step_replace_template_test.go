@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
+)
+
+// replacerMock records the operations done on guests, as "<operation> <id>".
+type replacerMock struct {
+	converterMock
+	calls     []string
+	deleteErr error
+}
+
+func (m *replacerMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	m.calls = append(m.calls, fmt.Sprintf("POST %s %v", url, params["newid"]))
+	return `{"data":null}`, nil
+}
+func (m *replacerMock) DeleteVm(r *proxmox.VmRef) (string, error) {
+	m.calls = append(m.calls, fmt.Sprintf("delete %d", r.VmId()))
+	return "", m.deleteErr
+}
+func (m *replacerMock) GetNextID(int) (int, error) {
+	return 102, nil
+}
+func (m *replacerMock) GetVmConfig(r *proxmox.VmRef) (map[string]interface{}, error) {
+	return map[string]interface{}{"name": "debian", "template": 1.0}, nil
+}
+func (m *replacerMock) SetVmConfig(r *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+	m.calls = append(m.calls, fmt.Sprintf("rename %d %s", r.VmId(), config["name"]))
+	return nil, nil
+}
+
+var _ templateReplacer = &replacerMock{}
+
+func TestReplaceTemplate(t *testing.T) {
+	cs := []struct {
+		name               string
+		config             *Config
+		deleteErr          error
+		expectedCalls      []string
+		expectedAction     multistep.StepAction
+		expectedTemplateID int
+	}{
+		{
+			name:   "replaced template is deleted",
+			config: &Config{ForceMode: "replace"},
+			expectedCalls: []string{
+				"delete 100",
+			},
+			expectedAction:     multistep.ActionContinue,
+			expectedTemplateID: 101,
+		},
+		{
+			name:   "replaced template is renamed when kept",
+			config: &Config{ForceMode: "replace", ForceKeepReplaced: true},
+			expectedCalls: []string{
+				"rename 100 debian-replaced-<timestamp>",
+			},
+			expectedAction:     multistep.ActionContinue,
+			expectedTemplateID: 101,
+		},
+		{
+			name:   "new template takes the ID of the replaced one",
+			config: &Config{ForceMode: "replace", ForceKeepVMID: true},
+			expectedCalls: []string{
+				"delete 100",
+				"POST /nodes/pve/qemu/101/clone 100",
+				"POST /nodes/pve/qemu/100/template <nil>",
+				"delete 101",
+			},
+			expectedAction:     multistep.ActionContinue,
+			expectedTemplateID: 100,
+		},
+		{
+			name:   "kept replaced template is moved to a free ID",
+			config: &Config{ForceMode: "replace", ForceKeepReplaced: true, ForceKeepVMID: true},
+			expectedCalls: []string{
+				"POST /nodes/pve/qemu/100/clone 102",
+				"POST /nodes/pve/qemu/102/template <nil>",
+				"delete 100",
+				"POST /nodes/pve/qemu/101/clone 100",
+				"POST /nodes/pve/qemu/100/template <nil>",
+				"delete 101",
+			},
+			expectedAction:     multistep.ActionContinue,
+			expectedTemplateID: 100,
+		},
+		{
+			name:      "new template is left alone when the replaced one cannot be deleted",
+			config:    &Config{ForceMode: "replace", ForceKeepVMID: true},
+			deleteErr: fmt.Errorf("500 VM 100 is locked (clone)"),
+			expectedCalls: []string{
+				"delete 100",
+			},
+			expectedAction:     multistep.ActionHalt,
+			expectedTemplateID: 101,
+		},
+	}
+
+	timestamp := regexp.MustCompile(`replaced-\d{14}`)
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &replacerMock{deleteErr: c.deleteErr}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", c.config)
+			state.Put("proxmoxClient", client)
+			replaced := proxmox.NewVmRef(100)
+			replaced.SetNode("pve")
+			state.Put("replacedTemplate", replaced)
+			vmRef := proxmox.NewVmRef(101)
+			vmRef.SetNode("pve")
+			state.Put("vmRef", vmRef)
+			state.Put("template_id", 101)
+
+			step := stepReplaceTemplate{}
+			action := step.Run(context.TODO(), state)
+			require.Equal(t, c.expectedAction, action)
+			for i := range client.calls {
+				client.calls[i] = timestamp.ReplaceAllString(client.calls[i], "replaced-<timestamp>")
+			}
+			require.Equal(t, c.expectedCalls, client.calls)
+			require.Equal(t, c.expectedTemplateID, state.Get("template_id"))
+			require.Equal(t, c.expectedTemplateID, state.Get("vmRef").(*proxmox.VmRef).VmId())
+		})
+	}
+}
+
+func TestReplaceTemplateWithoutReplacedTemplate(t *testing.T) {
+	client := &replacerMock{}
+	state := new(multistep.BasicStateBag)
+	state.Put("proxmoxClient", client)
+
+	step := stepReplaceTemplate{}
+	require.Equal(t, multistep.ActionContinue, step.Run(context.TODO(), state))
+	require.Empty(t, client.calls)
+}
+
+// finalizeFailMock fails to update the configuration of the new template, as
+// a missing privilege does.
+type finalizeFailMock struct {
+	*replacerMock
+}
+
+func (m finalizeFailMock) SetVmConfig(r *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+	return nil, fmt.Errorf("403 Permission check failed (/vms/%d, VM.Config.Options)", r.VmId())
+}
+func (m finalizeFailMock) SetLxcConfig(r *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+	return m.SetVmConfig(r, config)
+}
+
+func TestReplacedTemplateKeptWhenFinalizeFails(t *testing.T) {
+	steps := outputSteps("template")
+	for i, step := range steps {
+		// The VM is provisioned and stopped, and template_config is empty
+		if _, ok := step.(*stepConvertToTemplate); ok {
+			steps = steps[i:]
+			break
+		}
+	}
+
+	for _, c := range []*Config{
+		{ForceMode: "replace"},
+		{ForceMode: "replace", ForceKeepReplaced: true},
+		{ForceMode: "replace", ForceKeepVMID: true},
+	} {
+		client := &replacerMock{}
+		state := new(multistep.BasicStateBag)
+		state.Put("ui", packersdk.TestUi(t))
+		state.Put("config", c)
+		state.Put("proxmoxClient", finalizeFailMock{client})
+		replaced := proxmox.NewVmRef(100)
+		replaced.SetNode("pve")
+		state.Put("replacedTemplate", replaced)
+		vmRef := proxmox.NewVmRef(101)
+		vmRef.SetNode("pve")
+		state.Put("vmRef", vmRef)
+
+		runner := &multistep.BasicRunner{Steps: steps}
+		runner.Run(context.TODO(), state)
+		require.ErrorContains(t, state.Get("error").(error), "403 Permission check failed")
+		// Only the new template was converted, the replaced one is untouched
+		require.Equal(t, []string{"POST /nodes/pve/qemu/101/template <nil>"}, client.calls)
+	}
+}
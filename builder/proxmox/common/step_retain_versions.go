@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepRetainVersions deletes the templates of the lineage of the new template
+// beyond retain_versions, from the oldest. Templates still used by linked
+// clones are kept.
+//
+// The template is built by then, so failures are reported without failing
+// the build.
+type stepRetainVersions struct{}
+
+type versionPruner interface {
+	resourceLister
+	GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error)
+	DeleteVm(*proxmox.VmRef) (string, error)
+}
+
+var _ versionPruner = &Client{}
+
+// templateVersion is a template of the lineage.
+type templateVersion struct {
+	vmRef *proxmox.VmRef
+	name  string
+	// Creation time, 0 when unknown
+	ctime int64
+}
+
+var ctimeRe = regexp.MustCompile(`(?:^|,)ctime=(\d+)`)
+
+func (s *stepRetainVersions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	if c.RetainVersions == 0 {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(versionPruner)
+	templateID := state.Get("template_id").(int)
+
+	guests, err := client.GetResourceList("vm")
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error listing the versions of %s, none were deleted: %s", c.templateLineage, err))
+		return multistep.ActionContinue
+	}
+	versions := lineageVersions(client, guests, c.templateLineage, templateID)
+	// The new template is one of the versions kept
+	if len(versions) < c.RetainVersions {
+		log.Printf("%d older versions of %s, none to delete", len(versions), c.templateLineage)
+		return multistep.ActionContinue
+	}
+	expired := versions[c.RetainVersions-1:]
+
+	used, err := linkedCloneBases(client, guests, expired)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error looking for linked clones of the versions of %s, none were deleted: %s", c.templateLineage, err))
+		return multistep.ActionContinue
+	}
+	for _, v := range expired {
+		if clones := used[v.vmRef.VmId()]; len(clones) > 0 {
+			ui.Sayf("Keeping template %s (%d) beyond retain_versions, used by linked clones %v", v.name, v.vmRef.VmId(), clones)
+			continue
+		}
+		ui.Sayf("Deleting template %s (%d) beyond retain_versions", v.name, v.vmRef.VmId())
+		if _, err := client.DeleteVm(v.vmRef); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting template %d: %s", v.vmRef.VmId(), err))
+		}
+	}
+	return multistep.ActionContinue
+}
+
+// lineageVersions returns the templates of the lineage, other than the new
+// one, from the newest to the oldest. Templates are ordered by creation time,
+// then by ID when it is unknown.
+func lineageVersions(client versionPruner, guests []interface{}, lineage string, templateID int) []templateVersion {
+	var versions []templateVersion
+	for _, g := range guests {
+		guest, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := guest["vmid"].(float64)
+		name, _ := guest["name"].(string)
+		if template, _ := guest["template"].(float64); template != 1 || int(id) == templateID {
+			continue
+		}
+		// Versions are named after the lineage, and tagged with their version
+		version, ok := strings.CutPrefix(name, lineage+"-")
+		if !ok || version == "" || !hasTag(guest["tags"], "version-"+strings.ToLower(version)) {
+			continue
+		}
+		vmRef := guestRef(guest)
+		v := templateVersion{vmRef: vmRef, name: name}
+		if config, err := client.GetVmConfig(vmRef); err != nil {
+			log.Printf("error fetching the config of template %d, ordering it by ID: %s", v.vmRef.VmId(), err)
+		} else if meta, ok := config["meta"].(string); ok {
+			if m := ctimeRe.FindStringSubmatch(meta); m != nil {
+				v.ctime, _ = strconv.ParseInt(m[1], 10, 64)
+			}
+		}
+		versions = append(versions, v)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].ctime != versions[j].ctime {
+			return versions[i].ctime > versions[j].ctime
+		}
+		return versions[i].vmRef.VmId() > versions[j].vmRef.VmId()
+	})
+	return versions
+}
+
+// guestRef returns the reference of a guest listed in the cluster resources.
+func guestRef(guest map[string]interface{}) *proxmox.VmRef {
+	id, _ := guest["vmid"].(float64)
+	node, _ := guest["node"].(string)
+	vmType, _ := guest["type"].(string)
+	vmRef := proxmox.NewVmRef(int(id))
+	vmRef.SetNode(node)
+	vmRef.SetVmType(vmType)
+	return vmRef
+}
+
+func hasTag(tags interface{}, tag string) bool {
+	s, _ := tags.(string)
+	return slices.Contains(strings.Split(s, ";"), tag)
+}
+
+// linkedCloneBases returns the IDs of the guests using the disks of each of
+// the templates as base, as linked clones do. Only the guests of the nodes
+// that can reach the disks of the templates, their own nodes and the nodes
+// sharing their storage, are looked at.
+func linkedCloneBases(client versionPruner, guests []interface{}, templates []templateVersion) (map[int][]int, error) {
+	bases := map[int]*regexp.Regexp{}
+	nodes := map[string]bool{}
+	storages := map[string]bool{}
+	for _, t := range templates {
+		id := t.vmRef.VmId()
+		// Linked clone disks are named after their base, for example
+		// local-lvm:base-9000-disk-0/vm-101-disk-0, or
+		// local-zfs:basevol-9000-disk-0/subvol-101-disk-0 for containers
+		bases[id] = regexp.MustCompile(fmt.Sprintf(`(?:^|[:/])(?:base|basevol)-%d-disk-\d+[^,/]*/`, id))
+		nodes[t.vmRef.Node()] = true
+
+		config, err := client.GetVmConfig(t.vmRef)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching the config of %d: %s", id, err)
+		}
+		disk := regexp.MustCompile(fmt.Sprintf(`^([^:,]+):(?:base|basevol)-%d-disk-\d+`, id))
+		for _, value := range config {
+			if s, ok := value.(string); ok {
+				if m := disk.FindStringSubmatch(s); m != nil {
+					storages[m[1]] = true
+				}
+			}
+		}
+	}
+	if len(storages) > 0 {
+		list, err := client.GetResourceList("storage")
+		if err != nil {
+			return nil, fmt.Errorf("error listing storages: %s", err)
+		}
+		for _, s := range list {
+			storage, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := storage["storage"].(string)
+			node, _ := storage["node"].(string)
+			if shared, _ := storage["shared"].(float64); shared == 1 && storages[name] {
+				nodes[node] = true
+			}
+		}
+	}
+
+	used := map[int][]int{}
+	for _, g := range guests {
+		guest, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vmRef := guestRef(guest)
+		if !nodes[vmRef.Node()] {
+			continue
+		}
+		config, err := client.GetVmConfig(vmRef)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching the config of %d: %s", vmRef.VmId(), err)
+		}
+		for templateID, base := range bases {
+			if templateID == vmRef.VmId() {
+				continue
+			}
+			for _, value := range config {
+				if s, ok := value.(string); ok && base.MatchString(s) {
+					used[templateID] = append(used[templateID], vmRef.VmId())
+					break
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+func (s *stepRetainVersions) Cleanup(state multistep.StateBag) {}
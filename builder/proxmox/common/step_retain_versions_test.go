@@ -0,0 +1,184 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
+)
+
+type prunerMock struct {
+	guests    []interface{}
+	storages  []interface{}
+	configs   map[int]map[string]interface{}
+	deleted   []int
+	deleteErr error
+}
+
+func (m *prunerMock) GetResourceList(resourceType string) ([]interface{}, error) {
+	if resourceType == "storage" {
+		return m.storages, nil
+	}
+	return m.guests, nil
+}
+func (m *prunerMock) GetVmConfig(r *proxmox.VmRef) (map[string]interface{}, error) {
+	return m.configs[r.VmId()], nil
+}
+func (m *prunerMock) DeleteVm(r *proxmox.VmRef) (string, error) {
+	m.deleted = append(m.deleted, r.VmId())
+	return "", m.deleteErr
+}
+
+var _ versionPruner = &prunerMock{}
+
+func versionGuest(id int, name string, template bool, tags string) map[string]interface{} {
+	guest := map[string]interface{}{
+		"vmid": float64(id),
+		"name": name,
+		"node": "pve",
+		"type": "qemu",
+		"tags": tags,
+	}
+	if template {
+		guest["template"] = 1.0
+	}
+	return guest
+}
+
+func guestOn(node string, guest map[string]interface{}) map[string]interface{} {
+	guest["node"] = node
+	return guest
+}
+
+func TestRetainVersions(t *testing.T) {
+	lineage := []interface{}{
+		versionGuest(9001, "debian-1", true, "version-1"),
+		versionGuest(9002, "debian-2", true, "version-2"),
+		versionGuest(9003, "debian-3", true, "version-3"),
+		// New template
+		versionGuest(9004, "debian-4", true, "version-4"),
+	}
+	others := []interface{}{
+		versionGuest(8001, "debian-1", false, "version-1"),
+		versionGuest(8002, "debian-bookworm", true, ""),
+		versionGuest(8003, "ubuntu-1", true, "version-1"),
+		versionGuest(8004, "debian-1-rc", true, "version-1"),
+		versionGuest(8005, "debian-5", true, "version-4"),
+	}
+	ctimes := map[int]map[string]interface{}{
+		9001: {"meta": "creation-qemu=8.1.5,ctime=1700000300"},
+		9002: {"meta": "creation-qemu=8.1.5,ctime=1700000200"},
+		9003: {"meta": "creation-qemu=8.1.5,ctime=1700000100"},
+	}
+
+	cs := []struct {
+		name            string
+		retainVersions  int
+		guests          []interface{}
+		configs         map[int]map[string]interface{}
+		storages        []interface{}
+		deleteErr       error
+		expectedDeleted []int
+	}{
+		{
+			name:            "no retention",
+			guests:          lineage,
+			expectedDeleted: nil,
+		},
+		{
+			name:            "oldest versions are deleted, ordered by ID without creation time",
+			retainVersions:  2,
+			guests:          append(append([]interface{}{}, lineage...), others...),
+			expectedDeleted: []int{9002, 9001},
+		},
+		{
+			name:            "versions are ordered by creation time",
+			retainVersions:  2,
+			guests:          lineage,
+			configs:         ctimes,
+			expectedDeleted: []int{9002, 9003},
+		},
+		{
+			name:            "retaining more versions than exist deletes nothing",
+			retainVersions:  5,
+			guests:          lineage,
+			expectedDeleted: nil,
+		},
+		{
+			name:           "versions used by linked clones are kept",
+			retainVersions: 1,
+			guests:         append(append([]interface{}{}, lineage...), versionGuest(101, "app", false, "")),
+			configs: map[int]map[string]interface{}{
+				9002: {"scsi0": "local-lvm:base-9002-disk-0,size=8G"},
+				101:  {"scsi0": "local-lvm:base-9002-disk-0/vm-101-disk-0,size=8G"},
+			},
+			expectedDeleted: []int{9003, 9001},
+		},
+		{
+			name:           "versions used by linked clone containers are kept",
+			retainVersions: 1,
+			guests:         append(append([]interface{}{}, lineage...), versionGuest(101, "app", false, "")),
+			configs: map[int]map[string]interface{}{
+				9003: {"rootfs": "local-zfs:basevol-9003-disk-0,size=8G"},
+				101:  {"rootfs": "local-zfs:basevol-9003-disk-0/subvol-101-disk-0,size=8G"},
+			},
+			expectedDeleted: []int{9002, 9001},
+		},
+		{
+			name:           "guests of other nodes are not looked at",
+			retainVersions: 1,
+			guests:         append(append([]interface{}{}, lineage...), guestOn("pve2", versionGuest(101, "app", false, ""))),
+			configs: map[int]map[string]interface{}{
+				9002: {"scsi0": "local-lvm:base-9002-disk-0,size=8G"},
+				101:  {"scsi0": "local-lvm:base-9002-disk-0/vm-101-disk-0,size=8G"},
+			},
+			storages: []interface{}{
+				map[string]interface{}{"storage": "local-lvm", "node": "pve2", "shared": 0.0},
+			},
+			expectedDeleted: []int{9003, 9002, 9001},
+		},
+		{
+			name:           "guests of nodes sharing the storage are looked at",
+			retainVersions: 1,
+			guests:         append(append([]interface{}{}, lineage...), guestOn("pve2", versionGuest(101, "app", false, ""))),
+			configs: map[int]map[string]interface{}{
+				9002: {"scsi0": "ceph:base-9002-disk-0,size=8G"},
+				101:  {"scsi0": "ceph:base-9002-disk-0/vm-101-disk-0,size=8G"},
+			},
+			storages: []interface{}{
+				map[string]interface{}{"storage": "ceph", "node": "pve", "shared": 1.0},
+				map[string]interface{}{"storage": "ceph", "node": "pve2", "shared": 1.0},
+			},
+			expectedDeleted: []int{9003, 9001},
+		},
+		{
+			name:            "delete errors do not halt",
+			retainVersions:  1,
+			guests:          lineage,
+			deleteErr:       fmt.Errorf("500 VM 9003 is locked (clone)"),
+			expectedDeleted: []int{9003, 9002, 9001},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &prunerMock{guests: c.guests, storages: c.storages, configs: c.configs, deleteErr: c.deleteErr}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{RetainVersions: c.retainVersions, templateLineage: "debian"})
+			state.Put("proxmoxClient", client)
+			state.Put("template_id", 9004)
+
+			step := stepRetainVersions{}
+			require.Equal(t, multistep.ActionContinue, step.Run(context.TODO(), state))
+			require.Equal(t, c.expectedDeleted, client.deleted)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepShutdownVM stops the provisioned VM, before template_config is applied
+// and the VM is converted into a template, or kept as the artifact with
+// `output_mode = "vm"`.
+type stepShutdownVM struct{}
+
+type vmStopper interface {
+	ShutdownVm(*proxmox.VmRef) (string, error)
+	StopVm(*proxmox.VmRef) (string, error)
+	GetVmState(*proxmox.VmRef) (map[string]interface{}, error)
+}
+
+var _ vmStopper = &Client{}
+
+var (
+	shutdownPollInterval = 2 * time.Second
+)
+
+func (s *stepShutdownVM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(vmStopper)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	if err := shutdownVM(ctx, state, client, vmRef); err != nil {
+		err := fmt.Errorf("Error stopping VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+// shutdownVM stops the VM through the Proxmox API, or with shutdown_command
+// when set. When the VM is still running after shutdown_timeout, it falls back
+// to the API shutdown. When the API shutdown fails or times out, the VM is
+// stopped forcefully.
+func shutdownVM(ctx context.Context, state multistep.StateBag, client vmStopper, vmRef *proxmox.VmRef) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if c.ShutdownCommand == "" {
+		ui.Say("Stopping VM")
+		return apiShutdown(ui, client, vmRef)
+	}
+
+	ui.Say("Running shutdown_command")
+	comm := state.Get("communicator").(packersdk.Communicator)
+	cmd := &packersdk.RemoteCmd{Command: c.ShutdownCommand}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return fmt.Errorf("error running shutdown_command: %s", err)
+	}
+	ui.Sayf("Waiting up to %s for the VM to stop", c.ShutdownTimeout)
+	stopped, err := waitForStop(ctx, client, vmRef, c.ShutdownTimeout)
+	if err != nil {
+		return err
+	}
+	if stopped {
+		ui.Say("VM stopped by shutdown_command")
+		return nil
+	}
+
+	ui.Error(fmt.Sprintf("VM still running %s after shutdown_command, shutting it down through the API", c.ShutdownTimeout))
+	return apiShutdown(ui, client, vmRef)
+}
+
+// apiShutdown shuts the VM down through the Proxmox API, and stops it when the
+// shutdown fails.
+func apiShutdown(ui packersdk.Ui, client vmStopper, vmRef *proxmox.VmRef) error {
+	_, err := client.ShutdownVm(vmRef)
+	if err == nil {
+		ui.Say("VM stopped by the API shutdown")
+		return nil
+	}
+	ui.Error(fmt.Sprintf("Error shutting down VM, stopping it: %s", err))
+	if _, err := client.StopVm(vmRef); err != nil {
+		return err
+	}
+	ui.Say("VM stopped forcefully")
+	return nil
+}
+
+// waitForStop polls the status of the VM until it is stopped, and reports
+// whether it stopped before the timeout.
+func waitForStop(ctx context.Context, client vmStopper, vmRef *proxmox.VmRef, timeout time.Duration) (bool, error) {
+	deadline := time.After(timeout)
+	for {
+		vmState, err := client.GetVmState(vmRef)
+		if err != nil {
+			return false, fmt.Errorf("error fetching the status of VM %d: %s", vmRef.VmId(), err)
+		}
+		if vmState["status"] == "stopped" {
+			return true, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-deadline:
+			return false, nil
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+}
+
+func (s *stepShutdownVM) Cleanup(state multistep.StateBag) {}
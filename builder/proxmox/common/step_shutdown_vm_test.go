@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
+)
+
+type stopperMock struct {
+	// Number of status polls before the VM is stopped, -1 for never
+	pollsToStop int
+	polls       int
+	shutdownErr error
+	stopErr     error
+	shutdown    bool
+	stopped     bool
+}
+
+func (m *stopperMock) ShutdownVm(*proxmox.VmRef) (string, error) {
+	m.shutdown = true
+	return "", m.shutdownErr
+}
+func (m *stopperMock) StopVm(*proxmox.VmRef) (string, error) {
+	m.stopped = true
+	return "", m.stopErr
+}
+func (m *stopperMock) GetVmState(*proxmox.VmRef) (map[string]interface{}, error) {
+	m.polls++
+	if m.pollsToStop >= 0 && m.polls > m.pollsToStop {
+		return map[string]interface{}{"status": "stopped"}, nil
+	}
+	return map[string]interface{}{"status": "running"}, nil
+}
+
+var _ vmStopper = &stopperMock{}
+
+func TestShutdownVM(t *testing.T) {
+	defer func(interval time.Duration) { shutdownPollInterval = interval }(shutdownPollInterval)
+	shutdownPollInterval = time.Millisecond
+
+	cs := []struct {
+		name            string
+		shutdownCommand string
+		pollsToStop     int
+		shutdownErr     error
+		stopErr         error
+		expectCommand   bool
+		expectShutdown  bool
+		expectStop      bool
+		expectedAction  multistep.StepAction
+	}{
+		{
+			name:           "without shutdown_command the VM is shut down through the API",
+			expectShutdown: true,
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "API shutdown error falls back to a hard stop without shutdown_command",
+			shutdownErr:    fmt.Errorf("timeout while waiting for the task"),
+			expectShutdown: true,
+			expectStop:     true,
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "hard stop error should return halt",
+			shutdownErr:    fmt.Errorf("some error"),
+			stopErr:        fmt.Errorf("some other error"),
+			expectShutdown: true,
+			expectStop:     true,
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name:            "VM stopped by shutdown_command",
+			shutdownCommand: "sudo poweroff",
+			pollsToStop:     2,
+			expectCommand:   true,
+			expectedAction:  multistep.ActionContinue,
+		},
+		{
+			name:            "timeout falls back to the API shutdown",
+			shutdownCommand: "sudo poweroff",
+			pollsToStop:     -1,
+			expectCommand:   true,
+			expectShutdown:  true,
+			expectedAction:  multistep.ActionContinue,
+		},
+		{
+			name:            "API shutdown error falls back to a hard stop",
+			shutdownCommand: "sudo poweroff",
+			pollsToStop:     -1,
+			shutdownErr:     fmt.Errorf("some error"),
+			expectCommand:   true,
+			expectShutdown:  true,
+			expectStop:      true,
+			expectedAction:  multistep.ActionContinue,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &stopperMock{pollsToStop: c.pollsToStop, shutdownErr: c.shutdownErr, stopErr: c.stopErr}
+			comm := new(packersdk.MockCommunicator)
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{ShutdownCommand: c.shutdownCommand, ShutdownTimeout: 20 * time.Millisecond})
+			state.Put("proxmoxClient", client)
+			state.Put("communicator", comm)
+			state.Put("vmRef", proxmox.NewVmRef(100))
+
+			step := stepShutdownVM{}
+			require.Equal(t, c.expectedAction, step.Run(context.TODO(), state))
+			require.Equal(t, c.expectCommand, comm.StartCalled)
+			if c.expectCommand {
+				require.Equal(t, c.shutdownCommand, comm.StartCmd.Command)
+			}
+			require.Equal(t, c.expectShutdown, client.shutdown)
+			require.Equal(t, c.expectStop, client.stopped)
+		})
+	}
+}
@@ -5,6 +5,7 @@ package proxmox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -27,13 +28,15 @@ type stepStartVM struct {
 }
 
 type ProxmoxVMCreator interface {
-	Create(*proxmox.VmRef, proxmox.ConfigQemu, multistep.StateBag) error
+	Create(context.Context, *proxmox.VmRef, proxmox.ConfigQemu, multistep.StateBag) error
 }
 type vmStarter interface {
 	CheckVmRef(vmr *proxmox.VmRef) (err error)
 	DeleteVm(vmr *proxmox.VmRef) (exitStatus string, err error)
 	GetNextID(int) (int, error)
+	GetResourceList(resourceType string) ([]interface{}, error)
 	GetVmConfig(vmr *proxmox.VmRef) (vmConfig map[string]interface{}, err error)
+	GetVmState(vmr *proxmox.VmRef) (vmState map[string]interface{}, err error)
 	GetVmRefsByName(vmName string) (vmrs []*proxmox.VmRef, err error)
 	SetVmConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
 	StartVm(*proxmox.VmRef) (string, error)
@@ -53,9 +56,7 @@ func getExistingTemplate(c *Config, client vmStarter) (*proxmox.VmRef, error) {
 		err := client.CheckVmRef(vmRef)
 		if err != nil {
 			// expect an error if no VM is found
-			// the error string is defined in GetVmInfo() of proxmox-api-go
-			notFoundError := fmt.Sprintf("vm '%d' not found", c.VMID)
-			if err.Error() == notFoundError {
+			if errors.Is(err, ErrNotFound) {
 				log.Println(err.Error())
 				return &proxmox.VmRef{}, nil
 			}
@@ -63,13 +64,16 @@ func getExistingTemplate(c *Config, client vmStarter) (*proxmox.VmRef, error) {
 		}
 		log.Printf("found VM with ID %d", vmRef.VmId())
 	} else {
-		log.Printf("looking up VMs with name '%s'", c.TemplateName)
-		vmRefs, err := client.GetVmRefsByName(c.TemplateName)
+		name := c.TemplateName
+		// The VM produced with output_mode vm keeps vm_name by default
+		if name == "" && c.OutputMode == "vm" {
+			name = c.VMName
+		}
+		log.Printf("looking up VMs with name '%s'", name)
+		vmRefs, err := client.GetVmRefsByName(name)
 		if err != nil {
 			// expect an error if no VMs are found
-			// the error string is defined in GetVmRefsByName() of proxmox-api-go
-			notFoundError := fmt.Sprintf("vm '%s' not found", c.TemplateName)
-			if err.Error() == notFoundError {
+			if errors.Is(err, ErrNotFound) {
 				log.Println(err.Error())
 				return &proxmox.VmRef{}, nil
 			}
@@ -80,10 +84,10 @@ func getExistingTemplate(c *Config, client vmStarter) (*proxmox.VmRef, error) {
 			for _, vmr := range vmRefs {
 				vmIDs = append(vmIDs, vmr.VmId())
 			}
-			return &proxmox.VmRef{}, fmt.Errorf("found multiple VMs with name '%s', IDs: %v", c.TemplateName, vmIDs)
+			return &proxmox.VmRef{}, fmt.Errorf("found multiple VMs with name '%s', IDs: %v", name, vmIDs)
 		}
 		vmRef = vmRefs[0]
-		log.Printf("found VM with name '%s' (ID: %d)", c.TemplateName, vmRef.VmId())
+		log.Printf("found VM with name '%s' (ID: %d)", name, vmRef.VmId())
 	}
 	log.Printf("check if VM %d is a template", vmRef.VmId())
 	vmConfig, err := client.GetVmConfig(vmRef)
@@ -91,7 +95,11 @@ func getExistingTemplate(c *Config, client vmStarter) (*proxmox.VmRef, error) {
 		return &proxmox.VmRef{}, err
 	}
 	log.Printf("VM %d template: %d", vmRef.VmId(), vmConfig["template"])
-	if vmConfig["template"] == nil {
+	// The existing artifact is a VM with output_mode vm
+	if c.OutputMode == "vm" && vmConfig["template"] != nil {
+		return &proxmox.VmRef{}, fmt.Errorf("found matching VM (ID: %d, name: %s), but it is a template", vmRef.VmId(), vmConfig["name"])
+	}
+	if c.OutputMode != "vm" && vmConfig["template"] == nil {
 		return &proxmox.VmRef{}, fmt.Errorf("found matching VM (ID: %d, name: %s), but it is not a template", vmRef.VmId(), vmConfig["name"])
 	}
 	return vmRef, nil
@@ -102,6 +110,10 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 	client := state.Get("proxmoxClient").(vmStarter)
 	c := state.Get("config").(*Config)
 
+	if c.OutputMode == "snapshot" {
+		return startExistingVM(state, client, c)
+	}
+
 	kvm := true
 	if c.DisableKVM {
 		kvm = false
@@ -155,6 +167,8 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 		config.Balloon = c.BalloonMinimum
 	}
 
+	// The ID the VM is created with, 0 to generate one
+	vmid := c.VMID
 	if c.PackerForce {
 		ui.Say("Force set, checking for existing artifact on PVE cluster")
 		vmRef, err := getExistingTemplate(c, client)
@@ -163,9 +177,17 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
-		if vmRef.VmId() != 0 {
+		if vmRef.VmId() != 0 && c.ForceMode == "replace" {
+			ui.Say(fmt.Sprintf("found existing VM template with ID %d on PVE node %s, it will be replaced once the new template is built", vmRef.VmId(), vmRef.Node()))
+			state.Put("replacedTemplate", vmRef)
+			// vm_id is taken by the template until it is replaced
+			vmid = 0
+		} else if vmRef.VmId() != 0 {
 			ui.Say(fmt.Sprintf("found existing VM template with ID %d on PVE node %s, deleting it", vmRef.VmId(), vmRef.Node()))
 			_, err = client.DeleteVm(vmRef)
+			if errors.Is(err, ErrLocked) {
+				err = fmt.Errorf("VM template %d is locked, it may be in use by a clone or a backup: %s", vmRef.VmId(), err)
+			}
 			if err != nil {
 				state.Put("error", err)
 				ui.Error(fmt.Sprintf("error deleting VM template: %s", err.Error()))
@@ -179,11 +201,23 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 
 	ui.Say("Creating VM")
 	var vmRef *proxmox.VmRef
+	previous := 0
 	for i := 1; ; i++ {
-		id := c.VMID
+		id := vmid
 		if id == 0 {
-			ui.Say("No VM ID given, getting next free from Proxmox")
-			genID, err := client.GetNextID(0)
+			var genID int
+			var err error
+			if c.vmIDRange.isSet() {
+				ui.Sayf("No VM ID given, getting first free in range %s", c.vmIDRange)
+				genID, err = firstFreeID(client, c.vmIDRange)
+			} else {
+				ui.Say("No VM ID given, getting next free from Proxmox")
+				genID, err = client.GetNextID(0)
+			}
+			if err == nil && genID == previous {
+				// The ID is still taken by a guest the lookup does not see
+				err = fmt.Errorf("Error creating VM: ID %d is already allocated", genID)
+			}
 			if err != nil {
 				state.Put("error", err)
 				ui.Error(err.Error())
@@ -198,7 +232,7 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 			vmRef.SetPool(c.Pool)
 		}
 
-		err := s.vmCreator.Create(vmRef, config, state)
+		err := s.vmCreator.Create(ctx, vmRef, config, state)
 		if err == nil {
 			break
 		}
@@ -206,8 +240,9 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 		// If there's no explicitly configured VMID, and the error is caused
 		// by a race condition in someone else using the ID we just got
 		// generated, we'll retry up to maxDuplicateIDRetries times.
-		if c.VMID == 0 && isDuplicateIDError(err) && i < maxDuplicateIDRetries {
+		if vmid == 0 && isDuplicateIDError(err) && i < maxDuplicateIDRetries {
 			ui.Say("Generated VM ID was already allocated, retrying")
+			previous = id
 			continue
 		}
 		err = fmt.Errorf("Error creating VM: %s", err)
@@ -250,6 +285,50 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 	return multistep.ActionContinue
 }
 
+// startExistingVM starts the VM to snapshot with `output_mode = "snapshot"`,
+// unless it is running. It sets the existingVM state, so that the VM is not
+// deleted, and the existingVMStarted state when it was started, so that it is
+// stopped again.
+func startExistingVM(state multistep.StateBag, client vmStarter, c *Config) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmRef := proxmox.NewVmRef(c.VMID)
+	if err := client.CheckVmRef(vmRef); err != nil {
+		return halt(fmt.Errorf("Error looking up VM %d to snapshot: %s", c.VMID, err))
+	}
+	vmConfig, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		return halt(fmt.Errorf("Error fetching the config of VM %d: %s", c.VMID, err))
+	}
+	if vmConfig["template"] != nil {
+		return halt(fmt.Errorf("VM %d is a template, output_mode = \"snapshot\" requires a VM", c.VMID))
+	}
+	vmState, err := client.GetVmState(vmRef)
+	if err != nil {
+		return halt(fmt.Errorf("Error fetching the status of VM %d: %s", c.VMID, err))
+	}
+
+	state.Put("existingVM", true)
+	state.Put("vmRef", vmRef)
+	state.Put("instance_id", vmRef.VmId())
+
+	if vmState["status"] == "running" {
+		ui.Sayf("VM %d is already running", vmRef.VmId())
+		return multistep.ActionContinue
+	}
+	ui.Sayf("Starting VM %d", vmRef.VmId())
+	if _, err := client.StartVm(vmRef); err != nil {
+		return halt(fmt.Errorf("Error starting VM: %s", err))
+	}
+	state.Put("existingVMStarted", true)
+	return multistep.ActionContinue
+}
+
 func generateAgentConfig(agent config.Trilean) *proxmox.QemuGuestAgent {
 	var enableAgent bool
 
@@ -464,6 +543,7 @@ func generateProxmoxDisks(disks []diskConfig, isos []ISOsConfig, cloneSourceDisk
 						ValueOf(&ideDisks).Elem().
 						FieldByName(fmt.Sprintf("Disk_%d", ideCount)).
 						Set(reflect.ValueOf(&dev))
+					disks[idx].AssignedDeviceIndex = fmt.Sprintf("ide%d", ideCount)
 					ideCount++
 					break
 				}
@@ -499,6 +579,7 @@ func generateProxmoxDisks(disks []diskConfig, isos []ISOsConfig, cloneSourceDisk
 						ValueOf(&scsiDisks).Elem().
 						FieldByName(fmt.Sprintf("Disk_%d", scsiCount)).
 						Set(reflect.ValueOf(&dev))
+					disks[idx].AssignedDeviceIndex = fmt.Sprintf("scsi%d", scsiCount)
 					scsiCount++
 					break
 				}
@@ -532,6 +613,7 @@ func generateProxmoxDisks(disks []diskConfig, isos []ISOsConfig, cloneSourceDisk
 						ValueOf(&sataDisks).Elem().
 						FieldByName(fmt.Sprintf("Disk_%d", sataCount)).
 						Set(reflect.ValueOf(&dev))
+					disks[idx].AssignedDeviceIndex = fmt.Sprintf("sata%d", sataCount)
 					sataCount++
 					break
 				}
@@ -565,6 +647,7 @@ func generateProxmoxDisks(disks []diskConfig, isos []ISOsConfig, cloneSourceDisk
 						ValueOf(&virtIODisks).Elem().
 						FieldByName(fmt.Sprintf("Disk_%d", virtIOCount)).
 						Set(reflect.ValueOf(&dev))
+					disks[idx].AssignedDeviceIndex = fmt.Sprintf("virtio%d", virtIOCount)
 					virtIOCount++
 					break
 				}
@@ -765,7 +848,7 @@ func setDeviceParamIfDefined(dev proxmox.QemuDevice, key, value string) {
 }
 
 func isDuplicateIDError(err error) bool {
-	return strings.Contains(err.Error(), "already exists on node")
+	return errors.Is(err, ErrAlreadyExists)
 }
 
 type startedVMCleaner interface {
@@ -773,7 +856,7 @@ type startedVMCleaner interface {
 	DeleteVm(*proxmox.VmRef) (string, error)
 }
 
-var _ startedVMCleaner = &proxmox.Client{}
+var _ startedVMCleaner = &Client{}
 
 func (s *stepStartVM) Cleanup(state multistep.StateBag) {
 	vmRefUntyped, ok := state.GetOk("vmRef")
@@ -792,9 +875,24 @@ func (s *stepStartVM) Cleanup(state multistep.StateBag) {
 	client := state.Get("proxmoxClient").(startedVMCleaner)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	// An existing VM is only stopped, when the build started it
+	if _, ok := state.GetOk("existingVM"); ok {
+		if _, ok := state.GetOk("existingVMStarted"); ok {
+			ui.Say("Stopping VM")
+			if _, err := client.StopVm(vmRef); err != nil {
+				ui.Error(fmt.Sprintf("Error stopping VM. Please stop it manually: %s", err))
+			}
+		}
+		return
+	}
+
 	// Destroy the server we just created
 	ui.Say("Stopping VM")
 	_, err := client.StopVm(vmRef)
+	if errors.Is(err, ErrNotFound) {
+		log.Printf("VM %d is already gone: %s", vmRef.VmId(), err)
+		return
+	}
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error stopping VM. Please stop and delete it manually: %s", err))
 		return
@@ -802,7 +900,7 @@ func (s *stepStartVM) Cleanup(state multistep.StateBag) {
 
 	ui.Say("Deleting VM")
 	_, err = client.DeleteVm(vmRef)
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		ui.Error(fmt.Sprintf("Error deleting VM. Please delete it manually: %s", err))
 		return
 	}
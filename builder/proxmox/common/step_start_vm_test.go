@@ -6,6 +6,7 @@ package proxmox
 import (
 	"context"
 	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
@@ -34,6 +35,8 @@ func TestCleanupStartVM(t *testing.T) {
 		name               string
 		setVmRef           bool
 		setSuccess         bool
+		existingVM         bool
+		existingStarted    bool
 		stopVMErr          error
 		expectCallStopVM   bool
 		deleteVMErr        error
@@ -57,6 +60,18 @@ func TestCleanupStartVM(t *testing.T) {
 			expectCallStopVM:   true,
 			expectCallDeleteVM: true,
 		},
+		{
+			name:             "existing VM started by the build is stopped but not deleted",
+			setVmRef:         true,
+			existingVM:       true,
+			existingStarted:  true,
+			expectCallStopVM: true,
+		},
+		{
+			name:       "existing VM already running is left alone",
+			setVmRef:   true,
+			existingVM: true,
+		},
 		{
 			name:               "if stopping fails, DeleteVm should not be called",
 			setVmRef:           true,
@@ -99,6 +114,12 @@ func TestCleanupStartVM(t *testing.T) {
 			if c.setSuccess {
 				state.Put("success", "true")
 			}
+			if c.existingVM {
+				state.Put("existingVM", true)
+			}
+			if c.existingStarted {
+				state.Put("existingVMStarted", true)
+			}
 
 			step := stepStartVM{}
 			step.Cleanup(state)
@@ -118,13 +139,15 @@ type startVMMock struct {
 	startVm     func(*proxmox.VmRef) (string, error)
 	setVmConfig func(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
 	getNextID   func(id int) (int, error)
+	getGuests   func() ([]interface{}, error)
 	getVmConfig func(vmr *proxmox.VmRef) (vmConfig map[string]interface{}, err error)
+	getVmState  func(vmr *proxmox.VmRef) (vmState map[string]interface{}, err error)
 	checkVmRef  func(vmr *proxmox.VmRef) (err error)
 	getVmByName func(vmName string) (vmrs []*proxmox.VmRef, err error)
 	deleteVm    func(vmr *proxmox.VmRef) (exitStatus string, err error)
 }
 
-func (m *startVMMock) Create(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
+func (m *startVMMock) Create(ctx context.Context, vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
 	return m.create(vmRef, config, state)
 }
 func (m *startVMMock) StartVm(vmRef *proxmox.VmRef) (string, error) {
@@ -136,9 +159,15 @@ func (m *startVMMock) SetVmConfig(vmRef *proxmox.VmRef, config map[string]interf
 func (m *startVMMock) GetNextID(id int) (int, error) {
 	return m.getNextID(id)
 }
+func (m *startVMMock) GetResourceList(resourceType string) ([]interface{}, error) {
+	return m.getGuests()
+}
 func (m *startVMMock) GetVmConfig(vmr *proxmox.VmRef) (map[string]interface{}, error) {
 	return m.getVmConfig(vmr)
 }
+func (m *startVMMock) GetVmState(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+	return m.getVmState(vmr)
+}
 func (m *startVMMock) CheckVmRef(vmr *proxmox.VmRef) (err error) {
 	return m.checkVmRef(vmr)
 }
@@ -212,7 +241,7 @@ func TestStartVM(t *testing.T) {
 
 func TestStartVMRetryOnDuplicateID(t *testing.T) {
 	newDuplicateError := func(id int) error {
-		return fmt.Errorf("unable to create VM %d - VM %d already exists on node 'test'", id, id)
+		return ClassifyError(fmt.Errorf("500 unable to create VM %d - VM %d already exists on node 'test'", id, id), "")
 	}
 	cs := []struct {
 		name                  string
@@ -300,11 +329,95 @@ func TestStartVMRetryOnDuplicateID(t *testing.T) {
 	}
 }
 
+func TestStartVMInVMIDRange(t *testing.T) {
+	cs := []struct {
+		name           string
+		guests         []int
+		takenOnCreate  []int
+		hiddenOnCreate []int
+		expectedIDs    []int
+		expectedAction multistep.StepAction
+	}{
+		{
+			name:           "first free ID of the range",
+			guests:         []int{100, 90000, 90001, 90003},
+			expectedIDs:    []int{90002},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "ID taken by another build is retried",
+			guests:         []int{90000},
+			takenOnCreate:  []int{90001},
+			expectedIDs:    []int{90001, 90002},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "ID taken but missing from the guests halts",
+			guests:         []int{90000},
+			hiddenOnCreate: []int{90001},
+			expectedIDs:    []int{90001},
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name:           "full range halts",
+			guests:         []int{90000, 90001, 90002, 90003},
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			var createdIDs []int
+			mock := &startVMMock{
+				create: func(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
+					createdIDs = append(createdIDs, vmRef.VmId())
+					if len(c.takenOnCreate) > 0 {
+						id := c.takenOnCreate[0]
+						c.takenOnCreate = c.takenOnCreate[1:]
+						c.guests = append(c.guests, id)
+						return ClassifyError(fmt.Errorf("500 unable to create VM %d - VM %d already exists on node 'test'", id, id), "")
+					}
+					if id := vmRef.VmId(); slices.Contains(c.hiddenOnCreate, id) {
+						return ClassifyError(fmt.Errorf("500 unable to create VM %d - VM %d already exists on node 'test'", id, id), "")
+					}
+					return nil
+				},
+				startVm: func(*proxmox.VmRef) (string, error) {
+					return "", nil
+				},
+				getNextID: func(id int) (int, error) {
+					t.Error("GetNextID should not be called with vm_id_range")
+					return 0, nil
+				},
+				getGuests: func() ([]interface{}, error) {
+					var guests []interface{}
+					for _, id := range c.guests {
+						guests = append(guests, map[string]interface{}{"vmid": float64(id)})
+					}
+					return guests, nil
+				},
+			}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{vmIDRange: idRange{min: 90000, max: 90003}})
+			state.Put("proxmoxClient", mock)
+			s := stepStartVM{vmCreator: mock}
+
+			action := s.Run(context.TODO(), state)
+			if action != c.expectedAction {
+				t.Errorf("Expected action %s, got %s", c.expectedAction, action)
+			}
+			assert.Equal(t, c.expectedIDs, createdIDs)
+		})
+	}
+}
+
 func TestStartVMWithForce(t *testing.T) {
 	cs := []struct {
 		name                 string
 		config               *Config
 		expectedCallToDelete bool
+		expectedReplace      bool
 		expectedAction       multistep.StepAction
 		mockGetVmRefsByName  func(vmName string) (vmrs []*proxmox.VmRef, err error)
 		mockGetVmConfig      func(vmr *proxmox.VmRef) (map[string]interface{}, error)
@@ -324,6 +437,23 @@ func TestStartVMWithForce(t *testing.T) {
 				return map[string]interface{}{"template": 1.0}, nil
 			},
 		},
+		{
+			name: "Keep existing template until replaced when force_mode is replace",
+			config: &Config{
+				PackerConfig: common.PackerConfig{
+					PackerForce: true,
+				},
+				VMID:          100,
+				ForceMode:     "replace",
+				ForceKeepVMID: true,
+			},
+			expectedCallToDelete: false,
+			expectedReplace:      true,
+			expectedAction:       multistep.ActionContinue,
+			mockGetVmConfig: func(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+				return map[string]interface{}{"template": 1.0}, nil
+			},
+		},
 		{
 			name: "Don't delete VM when it's not a template",
 			config: &Config{
@@ -338,6 +468,36 @@ func TestStartVMWithForce(t *testing.T) {
 				return map[string]interface{}{}, nil
 			},
 		},
+		{
+			name: "Delete existing VM when it's not a template and output_mode is vm",
+			config: &Config{
+				PackerConfig: common.PackerConfig{
+					PackerForce: true,
+				},
+				VMID:       100,
+				OutputMode: "vm",
+			},
+			expectedCallToDelete: true,
+			expectedAction:       multistep.ActionContinue,
+			mockGetVmConfig: func(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+				return map[string]interface{}{}, nil
+			},
+		},
+		{
+			name: "Don't delete template when output_mode is vm",
+			config: &Config{
+				PackerConfig: common.PackerConfig{
+					PackerForce: true,
+				},
+				VMID:       100,
+				OutputMode: "vm",
+			},
+			expectedCallToDelete: false,
+			expectedAction:       multistep.ActionHalt,
+			mockGetVmConfig: func(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+				return map[string]interface{}{"template": 1.0}, nil
+			},
+		},
 		{
 			name: "Don't delete VM when force disabled",
 			config: &Config{
@@ -439,6 +599,13 @@ func TestStartVMWithForce(t *testing.T) {
 			if !deleteWasCalled && c.expectedCallToDelete {
 				t.Error("Expected call of deleteVm")
 			}
+			replaced, replacing := state.GetOk("replacedTemplate")
+			assert.Equal(t, c.expectedReplace, replacing)
+			if replacing {
+				// The new template is built under another ID
+				assert.Equal(t, 100, replaced.(*proxmox.VmRef).VmId())
+				assert.Equal(t, 101, state.Get("vmRef").(*proxmox.VmRef).VmId())
+			}
 		})
 	}
 }
@@ -1309,3 +1476,80 @@ func TestGenerateProxmoxDisks(t *testing.T) {
 		})
 	}
 }
+
+func TestStartExistingVM(t *testing.T) {
+	cs := []struct {
+		name            string
+		vmConfig        map[string]interface{}
+		status          string
+		checkErr        error
+		expectStart     bool
+		expectedAction  multistep.StepAction
+		expectedStarted bool
+	}{
+		{
+			name:            "stopped VM is started",
+			vmConfig:        map[string]interface{}{"name": "appliance"},
+			status:          "stopped",
+			expectStart:     true,
+			expectedAction:  multistep.ActionContinue,
+			expectedStarted: true,
+		},
+		{
+			name:           "running VM is used as is",
+			vmConfig:       map[string]interface{}{"name": "appliance"},
+			status:         "running",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "templates cannot be snapshotted",
+			vmConfig:       map[string]interface{}{"name": "appliance", "template": 1.0},
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name:           "missing VM halts",
+			checkErr:       &APIError{Kind: ErrNotFound, Err: fmt.Errorf("vm '100' not found")},
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			started := false
+			mock := &startVMMock{
+				create: func(*proxmox.VmRef, proxmox.ConfigQemu, multistep.StateBag) error {
+					t.Error("Did not expect a VM to be created")
+					return nil
+				},
+				checkVmRef: func(vmr *proxmox.VmRef) error {
+					return c.checkErr
+				},
+				getVmConfig: func(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+					return c.vmConfig, nil
+				},
+				getVmState: func(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+					return map[string]interface{}{"status": c.status}, nil
+				},
+				startVm: func(vmr *proxmox.VmRef) (string, error) {
+					started = true
+					return "", nil
+				},
+			}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{OutputMode: "snapshot", VMID: 100, SnapshotName: "release"})
+			state.Put("proxmoxClient", mock)
+
+			step := stepStartVM{vmCreator: mock}
+			assert.Equal(t, c.expectedAction, step.Run(context.TODO(), state))
+			assert.Equal(t, c.expectStart, started)
+			_, existingStarted := state.GetOk("existingVMStarted")
+			assert.Equal(t, c.expectedStarted, existingStarted)
+			if c.expectedAction == multistep.ActionContinue {
+				_, existing := state.GetOk("existingVM")
+				assert.True(t, existing)
+				assert.Equal(t, 100, state.Get("vmRef").(*proxmox.VmRef).VmId())
+			}
+		})
+	}
+}
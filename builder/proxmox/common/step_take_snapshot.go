@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepTakeSnapshot takes the snapshot snapshot_name of the VM with
+// `output_mode = "snapshot"`, described like a template would be. The VM is
+// shut down afterwards when the build started it.
+type stepTakeSnapshot struct{}
+
+type snapshotTaker interface {
+	TaskClient
+	vmStopper
+	DeleteSnapshot(*proxmox.VmRef, string) (string, error)
+}
+
+var _ snapshotTaker = &Client{}
+
+func (s *stepTakeSnapshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(snapshotTaker)
+	c := state.Get("config").(*Config)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if c.PackerForce {
+		_, err := client.DeleteSnapshot(vmRef, c.SnapshotName)
+		switch {
+		case err == nil:
+			ui.Sayf("Deleted existing snapshot %s", c.SnapshotName)
+		case !errors.Is(err, ErrNotFound):
+			return halt(fmt.Errorf("Error deleting existing snapshot %s: %s", c.SnapshotName, err))
+		}
+	}
+
+	description, err := templateDescription(state, c)
+	if err != nil {
+		return halt(fmt.Errorf("Error rendering template_description: %s", err))
+	}
+	ui.Sayf("Taking snapshot %s of VM %d", c.SnapshotName, vmRef.VmId())
+	tracker := &TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
+	params := map[string]interface{}{
+		"snapname":    c.SnapshotName,
+		"description": description,
+	}
+	if _, err := tracker.Run(ctx, params, guestURL(vmRef)+"/snapshot"); err != nil {
+		return halt(fmt.Errorf("Error taking snapshot %s: %s", c.SnapshotName, err))
+	}
+
+	if _, ok := state.GetOk("existingVMStarted"); ok {
+		if err := shutdownVM(ctx, state, client, vmRef); err != nil {
+			ui.Error(fmt.Sprintf("Error stopping VM %d. Please stop it manually: %s", vmRef.VmId(), err))
+		}
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepTakeSnapshot) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotMock struct {
+	*taskClientMock
+	deleteErr error
+	deleted   bool
+	shutdown  bool
+}
+
+func (m *snapshotMock) DeleteSnapshot(vmr *proxmox.VmRef, name string) (string, error) {
+	m.deleted = true
+	return "", m.deleteErr
+}
+func (m *snapshotMock) ShutdownVm(vmr *proxmox.VmRef) (string, error) {
+	m.shutdown = true
+	return "", nil
+}
+
+func (m *snapshotMock) StopVm(vmr *proxmox.VmRef) (string, error) {
+	return "", nil
+}
+func (m *snapshotMock) GetVmState(vmr *proxmox.VmRef) (map[string]interface{}, error) {
+	return map[string]interface{}{"status": "stopped"}, nil
+}
+
+var _ snapshotTaker = &snapshotMock{}
+
+func TestTakeSnapshot(t *testing.T) {
+	cs := []struct {
+		name           string
+		force          bool
+		deleteErr      error
+		started        bool
+		expectDelete   bool
+		expectShutdown bool
+		expectedPost   string
+		expectedAction multistep.StepAction
+	}{
+		{
+			name:           "snapshot is taken",
+			expectedPost:   "/nodes/pve/qemu/100/snapshot",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "VM started by the build is shut down",
+			started:        true,
+			expectShutdown: true,
+			expectedPost:   "/nodes/pve/qemu/100/snapshot",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "force replaces the snapshot",
+			force:          true,
+			expectDelete:   true,
+			expectedPost:   "/nodes/pve/qemu/100/snapshot",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "force without an existing snapshot",
+			force:          true,
+			deleteErr:      &APIError{Kind: ErrNotFound, Err: fmt.Errorf("snapshot 'release' does not exist")},
+			expectDelete:   true,
+			expectedPost:   "/nodes/pve/qemu/100/snapshot",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "error deleting the existing snapshot halts",
+			force:          true,
+			deleteErr:      &APIError{Kind: ErrLocked, Err: fmt.Errorf("VM is locked (snapshot)")},
+			expectDelete:   true,
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &snapshotMock{taskClientMock: &taskClientMock{polls: 1, exitStatus: "OK"}, deleteErr: c.deleteErr}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{
+				PackerConfig: common.PackerConfig{PackerForce: c.force},
+				OutputMode:   "snapshot",
+				SnapshotName: "release",
+			})
+			state.Put("proxmoxClient", client)
+			vmRef := proxmox.NewVmRef(100)
+			vmRef.SetNode("pve")
+			state.Put("vmRef", vmRef)
+			if c.started {
+				state.Put("existingVMStarted", true)
+			}
+
+			step := stepTakeSnapshot{}
+			require.Equal(t, c.expectedAction, step.Run(context.TODO(), state))
+			require.Equal(t, c.expectedPost, client.posted)
+			require.Equal(t, c.expectDelete, client.deleted)
+			require.Equal(t, c.expectShutdown, client.shutdown)
+		})
+	}
+}
@@ -34,7 +34,7 @@ type commandTyper interface {
 	Sendkey(*proxmox.VmRef, string) error
 }
 
-var _ commandTyper = &proxmox.Client{}
+var _ commandTyper = &Client{}
 
 func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
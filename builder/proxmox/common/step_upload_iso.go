@@ -5,8 +5,10 @@ package proxmox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 
@@ -21,11 +23,12 @@ type stepUploadISO struct {
 }
 
 type uploader interface {
-	Upload(node string, storage string, contentType string, filename string, file io.Reader) error
+	TaskClient
+	Upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader) (upid string, err error)
 	DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
 }
 
-var _ uploader = &proxmoxapi.Client{}
+var _ uploader = &Client{}
 
 func (s *stepUploadISO) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
@@ -72,7 +75,11 @@ func (s *stepUploadISO) Run(ctx context.Context, state multistep.StateBag) multi
 	}
 
 	filename := filepath.Base(isoPath)
-	err = client.Upload(c.Node, s.ISO.ISOStoragePool, "iso", filename, r)
+	upid, err := client.Upload(ctx, c.Node, s.ISO.ISOStoragePool, "iso", filename, r)
+	if err == nil {
+		tracker := &TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+		_, err = tracker.Wait(ctx, upid)
+	}
 	if err != nil {
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -98,6 +105,10 @@ func (s *stepUploadISO) Cleanup(state multistep.StateBag) {
 		vmRef.SetVmType("qemu")
 
 		_, err := client.DeleteVolume(vmRef, s.ISO.ISOStoragePool, s.ISO.ISOFile)
+		if errors.Is(err, ErrNotFound) {
+			log.Printf("generated ISO %s is already gone", s.ISO.ISOFile)
+			return
+		}
 		if err != nil {
 			state.Put("error", err)
 			ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
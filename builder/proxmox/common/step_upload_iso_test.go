@@ -16,18 +16,19 @@ import (
 )
 
 type uploaderMock struct {
+	*taskClientMock
 	uploadFail      bool
 	deleteFail      bool
 	uploadWasCalled bool
 	deleteWasCalled bool
 }
 
-func (m *uploaderMock) Upload(node string, storage string, contentType string, filename string, file io.Reader) error {
+func (m *uploaderMock) Upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader) (string, error) {
 	m.uploadWasCalled = true
 	if m.uploadFail {
-		return fmt.Errorf("Testing induced Upload failure")
+		return "", fmt.Errorf("Testing induced Upload failure")
 	}
-	return nil
+	return testUPID, nil
 }
 
 func (m *uploaderMock) DeleteVolume(vmr *proxmox.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error) {
@@ -187,7 +188,11 @@ func TestUploadISO(t *testing.T) {
 
 	for _, c := range cs {
 		t.Run(c.name, func(t *testing.T) {
-			m := &uploaderMock{uploadFail: c.failUpload, deleteFail: c.failDelete}
+			m := &uploaderMock{
+				taskClientMock: &taskClientMock{polls: 1, exitStatus: "OK"},
+				uploadFail:     c.failUpload,
+				deleteFail:     c.failDelete,
+			}
 
 			state := new(multistep.BasicStateBag)
 			state.Put("ui", packersdk.TestUi(t))
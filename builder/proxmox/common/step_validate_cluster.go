@@ -0,0 +1,238 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StorageRequirement is a storage pool used by a build, along with the
+// content type it must allow.
+type StorageRequirement struct {
+	// The configuration option the storage pool comes from, used in errors.
+	Option      string
+	StoragePool string
+	Content     string
+	// Set when the build uploads files to the storage pool, such as ISOs.
+	Upload bool
+}
+
+// stepValidateCluster checks, before anything is created, that the node,
+// storage pools, resource pool, bridges and PCI mappings of the
+// configuration exist in the cluster. Every mismatch is reported at once.
+type stepValidateCluster struct {
+	// Storage pools used by the builder on top of those of Config
+	storage []StorageRequirement
+	// Content type the disk storage pools must allow
+	diskContent string
+}
+
+type clusterInspector interface {
+	GetResourceList(resourceType string) (list []interface{}, err error)
+	GetItemListInterfaceArray(url string) ([]interface{}, error)
+}
+
+var _ clusterInspector = &Client{}
+
+func (s *stepValidateCluster) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(clusterInspector)
+	c := state.Get("config").(*Config)
+
+	ui.Say("Validating configuration against the cluster...")
+	requirements := append(c.storageRequirements(s.diskContent), s.storage...)
+	errs := validateCluster(client, c, requirements)
+	if errs != nil && len(errs.Errors) > 0 {
+		state.Put("error", errs)
+		ui.Error(errs.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepValidateCluster) Cleanup(state multistep.StateBag) {}
+
+// validateCluster looks up the cluster resources once, and returns every
+// mismatch with the configuration. Lookups of bridges and PCI mappings that
+// fail, for example because of missing audit privileges, are skipped.
+func validateCluster(client clusterInspector, c *Config, requirements []StorageRequirement) *packersdk.MultiError {
+	var errs *packersdk.MultiError
+
+	resources, err := client.GetResourceList("")
+	if err != nil {
+		return packersdk.MultiErrorAppend(errs, fmt.Errorf("error listing cluster resources: %s", err))
+	}
+	nodes := map[string]map[string]interface{}{}
+	storages := map[string]map[string]interface{}{}
+	pools := map[string]bool{}
+	for _, r := range resources {
+		resource, _ := r.(map[string]interface{})
+		node, _ := resource["node"].(string)
+		switch resource["type"] {
+		case "node":
+			nodes[node] = resource
+		case "storage":
+			if node == c.Node {
+				name, _ := resource["storage"].(string)
+				storages[name] = resource
+			}
+		case "pool":
+			name, _ := resource["pool"].(string)
+			pools[name] = true
+		}
+	}
+
+	node, ok := nodes[c.Node]
+	online := ok && node["status"] == "online"
+	if !ok {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("node: %s not found in the cluster", c.Node))
+	} else if !online {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("node: %s is %v", c.Node, node["status"]))
+	}
+
+	if c.Pool != "" && !pools[c.Pool] {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("pool: %s not found", c.Pool))
+	}
+
+	for _, req := range requirements {
+		storage, ok := storages[req.StoragePool]
+		if !ok {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: storage pool %s not found on node %s", req.Option, req.StoragePool, c.Node))
+			continue
+		}
+		if status, _ := storage["status"].(string); status != "available" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: storage pool %s is not available on node %s", req.Option, req.StoragePool, c.Node))
+			continue
+		}
+		content, _ := storage["content"].(string)
+		if !slices.Contains(strings.Split(content, ","), req.Content) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: storage pool %s does not allow %s content", req.Option, req.StoragePool, req.Content))
+		}
+	}
+
+	// Bridges are per node, they cannot be checked on a node that is down
+	if online {
+		for idx, nic := range c.NICs {
+			if nic.Bridge == "" {
+				continue
+			}
+			found, err := HasBridge(client, c.Node, nic.Bridge)
+			if err != nil {
+				log.Printf("skipping check of bridge %s: %s", nic.Bridge, err)
+				continue
+			}
+			if !found {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].bridge: %s not found on node %s", idx, nic.Bridge, c.Node))
+			}
+		}
+	}
+
+	var mappings map[string]bool
+	for idx, dev := range c.PCIDevices {
+		if dev.Mapping == "" {
+			continue
+		}
+		if mappings == nil {
+			mappings, err = pciMappings(client)
+			if err != nil {
+				log.Printf("skipping check of PCI mappings: %s", err)
+				break
+			}
+		}
+		if !mappings[dev.Mapping] {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("pci_devices[%d].mapping: %s not found", idx, dev.Mapping))
+		}
+	}
+
+	return errs
+}
+
+// storageRequirements returns the storage pools of the configuration, along
+// with the content type they must allow. Disk storage pools must allow
+// diskContent, or images when empty.
+func (c *Config) storageRequirements(diskContent string) []StorageRequirement {
+	if diskContent == "" {
+		diskContent = "images"
+	}
+	var reqs []StorageRequirement
+	for idx, disk := range c.Disks {
+		if disk.StoragePool != "" {
+			reqs = append(reqs, StorageRequirement{Option: fmt.Sprintf("disks[%d].storage_pool", idx), StoragePool: disk.StoragePool, Content: diskContent})
+		}
+	}
+	if c.CloudInit && c.CloudInitStoragePool != "" {
+		reqs = append(reqs, StorageRequirement{Option: "cloud_init_storage_pool", StoragePool: c.CloudInitStoragePool, Content: "images"})
+	}
+	if c.EFIConfig.EFIStoragePool != "" {
+		reqs = append(reqs, StorageRequirement{Option: "efi_config.efi_storage_pool", StoragePool: c.EFIConfig.EFIStoragePool, Content: "images"})
+	}
+	if c.TPMConfig.TPMStoragePool != "" {
+		reqs = append(reqs, StorageRequirement{Option: "tpm_config.tpm_storage_pool", StoragePool: c.TPMConfig.TPMStoragePool, Content: "images"})
+	}
+	for _, iso := range c.ISOs {
+		if iso.ISOFile != "" {
+			if storage, _, ok := strings.Cut(iso.ISOFile, ":"); ok {
+				reqs = append(reqs, StorageRequirement{Option: "iso_file", StoragePool: storage, Content: "iso"})
+			}
+		} else if iso.ISOStoragePool != "" {
+			reqs = append(reqs, StorageRequirement{Option: "iso_storage_pool", StoragePool: iso.ISOStoragePool, Content: "iso", Upload: true})
+		}
+	}
+	return reqs
+}
+
+// BridgeLister lists the network bridges of the nodes and the SDN VNets.
+type BridgeLister interface {
+	GetItemListInterfaceArray(url string) ([]interface{}, error)
+}
+
+// HasBridge returns whether the bridge exists on the node, either as a
+// Linux or OVS bridge or as an SDN VNet.
+func HasBridge(client BridgeLister, node, bridge string) (bool, error) {
+	ifaces, err := client.GetItemListInterfaceArray(fmt.Sprintf("/nodes/%s/network?type=any_bridge", node))
+	if err != nil {
+		return false, fmt.Errorf("error listing network interfaces of node %s: %s", node, err)
+	}
+	for _, r := range ifaces {
+		iface, _ := r.(map[string]interface{})
+		if iface["iface"] == bridge {
+			return true, nil
+		}
+	}
+	// SDN is optional, clusters without it have no VNets
+	vnets, err := client.GetItemListInterfaceArray("/cluster/sdn/vnets")
+	if err != nil {
+		log.Printf("error listing SDN VNets: %s", err)
+		return false, nil
+	}
+	for _, r := range vnets {
+		vnet, _ := r.(map[string]interface{})
+		if vnet["vnet"] == bridge {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pciMappings returns the IDs of the cluster PCI resource mappings.
+func pciMappings(client clusterInspector) (map[string]bool, error) {
+	items, err := client.GetItemListInterfaceArray("/cluster/mapping/pci")
+	if err != nil {
+		return nil, err
+	}
+	mappings := map[string]bool{}
+	for _, r := range items {
+		mapping, _ := r.(map[string]interface{})
+		id, _ := mapping["id"].(string)
+		mappings[id] = true
+	}
+	return mappings, nil
+}
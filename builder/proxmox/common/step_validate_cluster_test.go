@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type clusterInspectorMock struct {
+	resources []interface{}
+	items     map[string][]interface{}
+}
+
+func (m *clusterInspectorMock) GetResourceList(resourceType string) ([]interface{}, error) {
+	return m.resources, nil
+}
+
+func (m *clusterInspectorMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	items, ok := m.items[url]
+	if !ok {
+		return nil, errors.New("403 Permission check failed")
+	}
+	return items, nil
+}
+
+var _ clusterInspector = &clusterInspectorMock{}
+
+func newClusterInspectorMock() *clusterInspectorMock {
+	return &clusterInspectorMock{
+		resources: []interface{}{
+			map[string]interface{}{"type": "node", "node": "pve1", "status": "online"},
+			map[string]interface{}{"type": "node", "node": "pve2", "status": "offline"},
+			map[string]interface{}{"type": "storage", "node": "pve1", "storage": "local", "status": "available", "content": "iso,vztmpl,backup"},
+			map[string]interface{}{"type": "storage", "node": "pve1", "storage": "local-lvm", "status": "available", "content": "images,rootdir"},
+			map[string]interface{}{"type": "storage", "node": "pve1", "storage": "nfs", "status": "unknown", "content": "images"},
+			map[string]interface{}{"type": "storage", "node": "pve2", "storage": "ceph", "status": "available", "content": "images"},
+			map[string]interface{}{"type": "pool", "pool": "packer"},
+		},
+		items: map[string][]interface{}{
+			"/nodes/pve1/network?type=any_bridge": {
+				map[string]interface{}{"iface": "vmbr0"},
+			},
+			"/cluster/sdn/vnets": {
+				map[string]interface{}{"vnet": "vnet10"},
+			},
+			"/cluster/mapping/pci": {
+				map[string]interface{}{"id": "gpu"},
+			},
+		},
+	}
+}
+
+func TestValidateCluster(t *testing.T) {
+	cs := []struct {
+		name           string
+		config         *Config
+		requirements   []StorageRequirement
+		mock           func(m *clusterInspectorMock)
+		expectedErrors []string
+	}{
+		{
+			name: "valid configuration",
+			config: &Config{
+				Node:       "pve1",
+				Pool:       "packer",
+				Disks:      []diskConfig{{StoragePool: "local-lvm"}},
+				NICs:       []NICConfig{{Bridge: "vmbr0"}, {Bridge: "vnet10"}},
+				PCIDevices: []pciDeviceConfig{{Mapping: "gpu"}},
+				ISOs:       []ISOsConfig{{ISOFile: "local:iso/debian.iso"}},
+			},
+		},
+		{
+			name: "every mismatch is reported",
+			config: &Config{
+				Node:       "pve1",
+				Pool:       "missing",
+				Disks:      []diskConfig{{StoragePool: "local"}, {StoragePool: "ceph"}, {StoragePool: "nfs"}},
+				NICs:       []NICConfig{{Bridge: "vmbr1"}},
+				PCIDevices: []pciDeviceConfig{{Mapping: "nic"}},
+				ISOs:       []ISOsConfig{{ISOStoragePool: "local-lvm"}},
+			},
+			expectedErrors: []string{
+				"pool: missing not found",
+				"disks[0].storage_pool: storage pool local does not allow images content",
+				"disks[1].storage_pool: storage pool ceph not found on node pve1",
+				"disks[2].storage_pool: storage pool nfs is not available on node pve1",
+				"iso_storage_pool: storage pool local-lvm does not allow iso content",
+				"network_adapters[0].bridge: vmbr1 not found on node pve1",
+				"pci_devices[0].mapping: nic not found",
+			},
+		},
+		{
+			name: "builder storage requirements",
+			config: &Config{
+				Node: "pve1",
+			},
+			requirements: []StorageRequirement{
+				{Option: "template_file", StoragePool: "local-lvm", Content: "vztmpl"},
+			},
+			expectedErrors: []string{
+				"template_file: storage pool local-lvm does not allow vztmpl content",
+			},
+		},
+		{
+			name: "offline node",
+			config: &Config{
+				Node: "pve2",
+				NICs: []NICConfig{{Bridge: "vmbr0"}},
+			},
+			expectedErrors: []string{
+				"node: pve2 is offline",
+			},
+		},
+		{
+			name: "unknown node",
+			config: &Config{
+				Node: "pve3",
+			},
+			expectedErrors: []string{
+				"node: pve3 not found in the cluster",
+			},
+		},
+		{
+			name: "lookups without privileges are skipped",
+			config: &Config{
+				Node:       "pve1",
+				NICs:       []NICConfig{{Bridge: "vmbr1"}},
+				PCIDevices: []pciDeviceConfig{{Mapping: "nic"}},
+			},
+			mock: func(m *clusterInspectorMock) {
+				m.items = nil
+			},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := newClusterInspectorMock()
+			if c.mock != nil {
+				c.mock(m)
+			}
+			errs := validateCluster(m, c.config, append(c.config.storageRequirements(""), c.requirements...))
+
+			var got []string
+			if errs != nil {
+				for _, err := range errs.Errors {
+					got = append(got, err.Error())
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(c.expectedErrors, "\n") {
+				t.Errorf("Expected errors\n%s\ngot\n%s", strings.Join(c.expectedErrors, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestStepValidateCluster(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("proxmoxClient", newClusterInspectorMock())
+	state.Put("config", &Config{
+		Node:  "pve1",
+		Disks: []diskConfig{{StoragePool: "local-lvm"}},
+	})
+
+	step := &stepValidateCluster{diskContent: "rootdir"}
+	if action := step.Run(context.TODO(), state); action != multistep.ActionContinue {
+		t.Fatalf("Expected the step to continue, got %v: %v", action, state.Get("error"))
+	}
+
+	step = &stepValidateCluster{diskContent: "vztmpl"}
+	if action := step.Run(context.TODO(), state); action != multistep.ActionHalt {
+		t.Fatalf("Expected the step to halt, got %v", action)
+	}
+	if _, ok := state.Get("error").(*packersdk.MultiError); !ok {
+		t.Errorf("Expected a MultiError, got %v", state.Get("error"))
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Number of log lines reported when a task fails or times out
+const taskLogTail = 10
+
+// TaskClient starts Proxmox tasks without waiting for them, and follows
+// their progress.
+type TaskClient interface {
+	CreateItemReturnStatus(params map[string]interface{}, url string) (exitStatus string, err error)
+	GetItemList(url string) (list map[string]interface{}, err error)
+	GetItemListInterfaceArray(url string) ([]interface{}, error)
+	Delete(url string) (err error)
+}
+
+var _ TaskClient = &Client{}
+
+// TaskTracker runs Proxmox tasks, streaming their log to the UI while
+// polling their status. Cancelling the context stops the task.
+type TaskTracker struct {
+	Client TaskClient
+	Ui     packersdk.Ui
+	// Maximum time a task may run. No limit when zero.
+	Timeout time.Duration
+	// Time between two polls of the task. Defaults to 2 seconds.
+	PollInterval time.Duration
+	// Policy to start the task again when it fails because the guest is
+	// locked.
+	Retry RetryConfig
+}
+
+// Run posts params to url, which starts a task, and waits for the task to
+// end. It returns the exit status of the task, and an *APIError including
+// the last lines of its log when it did not succeed. Tasks that could not
+// lock the guest did nothing, they are started again.
+func (t *TaskTracker) Run(ctx context.Context, params map[string]interface{}, url string) (exitStatus string, err error) {
+	locked := func(err error) bool { return errors.Is(err, ErrLocked) }
+	err = t.Retry.do("POST "+url, locked, func() error {
+		exitStatus, err = t.run(ctx, params, url)
+		return err
+	})
+	return exitStatus, err
+}
+
+func (t *TaskTracker) run(ctx context.Context, params map[string]interface{}, url string) (string, error) {
+	body, err := t.Client.CreateItemReturnStatus(params, url)
+	if err != nil {
+		return "", ClassifyError(err, body)
+	}
+	var resp struct {
+		Data *string `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return "", fmt.Errorf("unexpected response %q: %s", body, err)
+	}
+	// Some calls, such as converting a container to a template, complete
+	// without a task
+	if resp.Data == nil {
+		return "OK", nil
+	}
+	return t.Wait(ctx, *resp.Data)
+}
+
+// Wait waits for the task with the given UPID to end, see Run.
+func (t *TaskTracker) Wait(ctx context.Context, upid string) (string, error) {
+	// UPID:<node>:<pid>:<pstart>:<starttime>:<type>:<id>:<user>:
+	fields := strings.Split(upid, ":")
+	if len(fields) < 3 || fields[0] != "UPID" {
+		return "", fmt.Errorf("invalid task ID %q", upid)
+	}
+	taskURL := fmt.Sprintf("/nodes/%s/tasks/%s", fields[1], upid)
+
+	interval := t.PollInterval
+	if interval == 0 {
+		interval = 2 * time.Second
+	}
+	var deadline <-chan time.Time
+	if t.Timeout > 0 {
+		timer := time.NewTimer(t.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	logs := &taskLog{}
+	for {
+		logs.fetch(t.Client, t.Ui, taskURL)
+
+		status, err := t.Client.GetItemList(taskURL + "/status")
+		if err != nil {
+			log.Printf("error polling task %s: %s", upid, err)
+		} else if data, _ := status["data"].(map[string]interface{}); data["status"] == "stopped" {
+			// Lines written right before the end
+			logs.fetch(t.Client, t.Ui, taskURL)
+			exitStatus, _ := data["exitstatus"].(string)
+			if !strings.HasPrefix(exitStatus, "OK") && !strings.HasPrefix(exitStatus, "WARNINGS") {
+				return exitStatus, &APIError{
+					Kind: errorKind(0, exitStatus),
+					Err:  fmt.Errorf("task %s failed: %s%s", upid, exitStatus, logs.tail()),
+				}
+			}
+			return exitStatus, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("stopping task %s", upid)
+			if err := t.Client.Delete(taskURL); err != nil {
+				t.Ui.Error(fmt.Sprintf("Error stopping task %s: %s", upid, err))
+			}
+			return "", ctx.Err()
+		case <-deadline:
+			return "", &APIError{
+				Kind: ErrTimeout,
+				Err:  fmt.Errorf("timeout waiting for task %s after %s%s", upid, t.Timeout, logs.tail()),
+			}
+		case <-time.After(interval):
+		}
+	}
+}
+
+// taskLog follows the log of a task.
+type taskLog struct {
+	read  int
+	lines []string
+}
+
+// fetch shows the lines written to the log since the last call. Errors are
+// only logged, the log is informative.
+func (l *taskLog) fetch(client TaskClient, ui packersdk.Ui, taskURL string) {
+	entries, err := client.GetItemListInterfaceArray(fmt.Sprintf("%s/log?start=%d&limit=500", taskURL, l.read))
+	if err != nil {
+		log.Printf("error reading task log: %s", err)
+		return
+	}
+	for _, e := range entries {
+		entry, _ := e.(map[string]interface{})
+		line, _ := entry["t"].(string)
+		// An empty log reads as a single placeholder line
+		if line == "no content" && len(entries) == 1 {
+			return
+		}
+		l.read++
+		if line == "" {
+			continue
+		}
+		ui.Message(line)
+		l.lines = append(l.lines, line)
+		if len(l.lines) > taskLogTail {
+			l.lines = l.lines[1:]
+		}
+	}
+}
+
+// tail returns the last lines of the log, for errors.
+func (l *taskLog) tail() string {
+	if len(l.lines) == 0 {
+		return ""
+	}
+	return ", last log lines:\n" + strings.Join(l.lines, "\n")
+}
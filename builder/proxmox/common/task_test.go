@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const testUPID = "UPID:pve1:0001A2B3:00C4D5E6:66320000:qmclone:100:root@pam:"
+
+// taskClientMock runs a fake task, which writes one log line per poll and
+// stops after the given number of polls. It never stops when polls is 0.
+type taskClientMock struct {
+	polls      int
+	exitStatus string
+	logLines   []string
+
+	posted  string
+	polled  int
+	stopped string
+}
+
+func (m *taskClientMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	m.posted = url
+	return fmt.Sprintf(`{"data":%q}`, testUPID), nil
+}
+
+func (m *taskClientMock) GetItemList(url string) (map[string]interface{}, error) {
+	m.polled++
+	status := map[string]interface{}{"status": "running"}
+	if m.polls > 0 && m.polled >= m.polls {
+		status = map[string]interface{}{"status": "stopped", "exitstatus": m.exitStatus}
+	}
+	return map[string]interface{}{"data": status}, nil
+}
+
+func (m *taskClientMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	var start int
+	if _, err := fmt.Sscanf(url[strings.Index(url, "start="):], "start=%d", &start); err != nil {
+		return nil, err
+	}
+	// One more line becomes available at every poll, all of them once stopped
+	available := m.polled + 1
+	if available > len(m.logLines) || (m.polls > 0 && m.polled >= m.polls) {
+		available = len(m.logLines)
+	}
+	if start >= available {
+		return []interface{}{map[string]interface{}{"n": float64(1), "t": "no content"}}, nil
+	}
+	var entries []interface{}
+	for i := start; i < available; i++ {
+		entries = append(entries, map[string]interface{}{"n": float64(i + 1), "t": m.logLines[i]})
+	}
+	return entries, nil
+}
+
+func (m *taskClientMock) Delete(url string) error {
+	m.stopped = url
+	return nil
+}
+
+var _ TaskClient = &taskClientMock{}
+
+func testLogLines(n int) []string {
+	var lines []string
+	for i := 1; i <= n; i++ {
+		lines = append(lines, fmt.Sprintf("transferred %d of %d", i, n))
+	}
+	return lines
+}
+
+func TestTaskTracker(t *testing.T) {
+	cs := []struct {
+		name           string
+		client         *taskClientMock
+		timeout        time.Duration
+		cancel         bool
+		expectedError  string
+		expectedLines  int
+		expectedStop   bool
+		expectedStatus string
+	}{
+		{
+			name:           "successful task streams its whole log",
+			client:         &taskClientMock{polls: 3, exitStatus: "OK", logLines: testLogLines(5)},
+			expectedLines:  5,
+			expectedStatus: "OK",
+		},
+		{
+			name:           "warnings are not errors",
+			client:         &taskClientMock{polls: 1, exitStatus: "WARNINGS: 1", logLines: testLogLines(1)},
+			expectedLines:  1,
+			expectedStatus: "WARNINGS: 1",
+		},
+		{
+			name:           "failed task reports the last log lines",
+			client:         &taskClientMock{polls: 20, exitStatus: "storage full", logLines: testLogLines(20)},
+			expectedError:  "failed: storage full, last log lines:\ntransferred 11 of 20\n",
+			expectedLines:  20,
+			expectedStatus: "storage full",
+		},
+		{
+			name:          "timeout reports the last log lines",
+			client:        &taskClientMock{logLines: testLogLines(3)},
+			timeout:       50 * time.Millisecond,
+			expectedError: "timeout waiting for task " + testUPID + " after 50ms, last log lines:\ntransferred 1 of 3",
+			expectedLines: 3,
+		},
+		{
+			name:          "cancellation stops the task",
+			client:        &taskClientMock{logLines: testLogLines(1)},
+			cancel:        true,
+			expectedError: "context canceled",
+			expectedLines: 1,
+			expectedStop:  true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			ui := &packersdk.BasicUi{
+				Reader:      new(strings.Reader),
+				Writer:      new(strings.Builder),
+				ErrorWriter: new(strings.Builder),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if c.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			tracker := &TaskTracker{Client: c.client, Ui: ui, Timeout: c.timeout, PollInterval: time.Millisecond}
+			if c.timeout > 0 || c.cancel {
+				tracker.PollInterval = 5 * time.Millisecond
+			}
+			status, err := tracker.Run(ctx, nil, "/nodes/pve1/qemu/100/clone")
+
+			if c.client.posted != "/nodes/pve1/qemu/100/clone" {
+				t.Errorf("Expected the task to be started, got %q", c.client.posted)
+			}
+			if c.expectedError == "" && err != nil {
+				t.Fatalf("Expected task to succeed, got %s", err)
+			}
+			if c.expectedError != "" && (err == nil || !strings.Contains(err.Error(), c.expectedError)) {
+				t.Fatalf("Expected error containing %q, got %v", c.expectedError, err)
+			}
+			if status != c.expectedStatus {
+				t.Errorf("Expected exit status %q, got %q", c.expectedStatus, status)
+			}
+
+			output := ui.Writer.(*strings.Builder).String()
+			if n := strings.Count(output, "transferred "); n != c.expectedLines {
+				t.Errorf("Expected %d log lines to be shown, got %d:\n%s", c.expectedLines, n, output)
+			}
+			if strings.Contains(output, "no content") {
+				t.Error("Expected the empty log placeholder not to be shown")
+			}
+
+			stopped := c.client.stopped == "/nodes/pve1/tasks/"+testUPID
+			if stopped != c.expectedStop {
+				t.Errorf("Expected task stopped=%v, got %q", c.expectedStop, c.client.stopped)
+			}
+		})
+	}
+}
+
+func TestTaskTrackerWithoutTask(t *testing.T) {
+	client := &converterMock{createTemplate: func(string) error { return nil }}
+	tracker := &TaskTracker{Client: client, Ui: packersdk.TestUi(t)}
+	status, err := tracker.Run(context.Background(), nil, "/nodes/pve1/lxc/100/template")
+	if err != nil || status != "OK" {
+		t.Errorf("Expected a call without task to succeed, got %q, %v", status, err)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// idRange is an inclusive range of VMIDs, such as `vm_id_range`. The zero
+// value is no range.
+type idRange struct {
+	min, max int
+}
+
+// parseIDRange parses a range of VMIDs formatted as `<first>-<last>`.
+func parseIDRange(option string, s string) (idRange, error) {
+	first, last, ok := strings.Cut(s, "-")
+	r := idRange{}
+	var err error
+	if ok {
+		if r.min, err = strconv.Atoi(strings.TrimSpace(first)); err == nil {
+			r.max, err = strconv.Atoi(strings.TrimSpace(last))
+		}
+	}
+	if !ok || err != nil {
+		return idRange{}, fmt.Errorf("%s must be a range of VMIDs such as 9000-9099, got %q", option, s)
+	}
+	if r.min < 100 || r.max > 999999999 || r.min > r.max {
+		return idRange{}, fmt.Errorf("%s must be an ascending range within 100-999999999, got %q", option, s)
+	}
+	return r, nil
+}
+
+func (r idRange) isSet() bool {
+	return r != idRange{}
+}
+
+func (r idRange) contains(id int) bool {
+	return id >= r.min && id <= r.max
+}
+
+func (r idRange) String() string {
+	return fmt.Sprintf("%d-%d", r.min, r.max)
+}
+
+type resourceLister interface {
+	GetResourceList(resourceType string) (list []interface{}, err error)
+}
+
+// firstFreeID returns the lowest VMID of the range that no guest of the
+// cluster uses. Another build may take it before the guest is created, callers
+// retry on duplicate ID errors.
+func firstFreeID(client resourceLister, r idRange) (int, error) {
+	guests, err := client.GetResourceList("vm")
+	if err != nil {
+		return 0, fmt.Errorf("error listing the guests of the cluster: %w", err)
+	}
+	used := map[int]bool{}
+	for _, guest := range guests {
+		if g, ok := guest.(map[string]interface{}); ok {
+			if id, ok := g["vmid"].(float64); ok {
+				used[int(id)] = true
+			}
+		}
+	}
+	for id := r.min; id <= r.max; id++ {
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("no free VMID left in range %s", r)
+}
@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id for the builder
+const BuilderID = "proxmox.import"
+
+// State key of the locally downloaded disk image
+const downloadPathKey = "downloaded_image_path"
+
+type Builder struct {
+	config Config
+}
+
+// Builder implements packersdk.Builder
+var _ packersdk.Builder = &Builder{}
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return b.config.Prepare(raws...)
+}
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("import-config", &b.config)
+
+	preSteps := []multistep.Step{
+		&proxmox.StepSshKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
+		},
+	}
+	if len(b.config.ImageURLs) > 0 {
+		if b.config.ImageDownloadPVE {
+			preSteps = append(preSteps, &stepDownloadImageOnPVE{})
+		} else {
+			preSteps = append(preSteps,
+				&commonsteps.StepDownload{
+					Checksum:    b.config.ImageChecksum,
+					Description: "disk image",
+					Extension:   b.config.ImageFormat,
+					ResultKey:   downloadPathKey,
+					TargetPath:  b.config.ImageTargetPath,
+					Url:         b.config.ImageURLs,
+				},
+				&stepUploadImage{},
+			)
+		}
+	}
+	postSteps := []multistep.Step{}
+
+	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &importVMCreator{})
+	sb.RequiredStorage = []proxmox.StorageRequirement{
+		{Option: "boot_disk.storage_pool", StoragePool: b.config.BootDisk.StoragePool, Content: "images"},
+	}
+	if storage, _, ok := strings.Cut(b.config.ImageFile, ":"); ok {
+		sb.RequiredStorage = append(sb.RequiredStorage, proxmox.StorageRequirement{Option: "image_file", StoragePool: storage, Content: "import"})
+	} else if b.config.ImageStoragePool != "" {
+		sb.RequiredStorage = append(sb.RequiredStorage, proxmox.StorageRequirement{Option: "image_storage_pool", StoragePool: b.config.ImageStoragePool, Content: "import", Upload: true})
+	}
+	if b.config.ImageDownloadPVE {
+		sb.RequiredPrivileges = []proxmox.PrivilegeRequirement{
+			{Feature: "image_download_pve", Paths: []string{"/nodes/" + b.config.Node}, Privileges: []string{"Sys.Audit", "Sys.Modify"}},
+		}
+	}
+	return sb.Run(ctx, ui, hook, state)
+}
+
+type importVMCreator struct{}
+
+func (*importVMCreator) Create(ctx context.Context, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmox.Client)
+	c := state.Get("import-config").(*Config)
+	comm := state.Get("config").(*proxmox.Config).Comm
+
+	// Cloud images are configured through cloud-init, so give the build VM
+	// the credentials and network configuration needed to connect to it.
+	// The cloud-init drive is removed again before the template is created.
+	config.CIuser = comm.SSHUsername
+	config.CIpassword = comm.SSHPassword
+	config.Sshkeys = string(comm.SSHPublicKey)
+	ipconfigs := make(proxmoxapi.IpconfigMap)
+	for idx := range c.NICs {
+		ipconfigs[idx] = "ip=dhcp"
+	}
+	config.Ipconfig = ipconfigs
+
+	err := config.Create(vmRef, client.Client)
+	if err != nil {
+		return proxmox.ClassifyError(err, "")
+	}
+	return importBootDisk(ctx, state.Get("ui").(packersdk.Ui), client, vmRef, c)
+}
+
+type diskImporter interface {
+	proxmox.TaskClient
+	GetVmConfig(vmr *proxmoxapi.VmRef) (vmConfig map[string]interface{}, err error)
+	ResizeQemuDiskRaw(vmr *proxmoxapi.VmRef, disk string, size string) (exitStatus interface{}, err error)
+}
+
+var _ diskImporter = &proxmox.Client{}
+
+// Number of disks Proxmox supports per bus type
+var maxDisksPerBus = map[string]int{
+	"ide":    4,
+	"sata":   6,
+	"scsi":   31,
+	"virtio": 16,
+}
+
+// importBootDisk attaches the disk image to the first free slot of the
+// configured bus, using Proxmox's import-from disk option, and boots from it.
+// A cloud-init drive is attached for the duration of the build.
+func importBootDisk(ctx context.Context, ui packersdk.Ui, client diskImporter, vmRef *proxmoxapi.VmRef, c *Config) error {
+	vmParams, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		return fmt.Errorf("error fetching VM config: %s", err)
+	}
+	if vmParams == nil {
+		vmParams = make(map[string]interface{})
+	}
+
+	changes := make(map[string]interface{})
+
+	slot := freeSlot(vmParams, c.BootDisk.Type)
+	if slot == "" {
+		return fmt.Errorf("found no free %s slot to import the disk image into", c.BootDisk.Type)
+	}
+	changes[slot] = bootDiskParam(c.BootDisk, c.ImageFile)
+	// Mark the slot as taken, in case the boot disk is an ide disk as well
+	vmParams[slot] = changes[slot]
+
+	cloudInitSlot := freeSlot(vmParams, "ide")
+	if cloudInitSlot == "" {
+		return fmt.Errorf("found no free ide slot for the cloud-init drive")
+	}
+	changes[cloudInitSlot] = c.BootDisk.StoragePool + ":cloudinit"
+	// Boot from the imported disk, unless a boot order was configured
+	if c.Boot == "" {
+		changes["boot"] = "order=" + slot
+	}
+
+	ui.Say(fmt.Sprintf("Importing disk image %s as %s", c.ImageFile, slot))
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+	_, err = tracker.Run(ctx, changes, fmt.Sprintf("/nodes/%s/qemu/%d/config", vmRef.Node(), vmRef.VmId()))
+	if err != nil {
+		return fmt.Errorf("error importing disk image %s: %s", c.ImageFile, err)
+	}
+
+	if c.BootDisk.Size != "" {
+		_, err = client.ResizeQemuDiskRaw(vmRef, slot, c.BootDisk.Size)
+		if err != nil {
+			return fmt.Errorf("error resizing imported disk to %s: %s", c.BootDisk.Size, err)
+		}
+	}
+	return nil
+}
+
+// freeSlot returns the first unused disk slot of the given bus type, or an
+// empty string if all slots are taken.
+func freeSlot(vmParams map[string]interface{}, bus string) string {
+	for i := 0; i < maxDisksPerBus[bus]; i++ {
+		slot := fmt.Sprintf("%s%d", bus, i)
+		if vmParams[slot] == nil {
+			return slot
+		}
+	}
+	return ""
+}
+
+func bootDiskParam(disk bootDiskConfig, imageFile string) string {
+	// A size of 0 lets Proxmox allocate the disk with the size of the image
+	options := []string{
+		disk.StoragePool + ":0",
+		"import-from=" + imageFile,
+	}
+	if disk.DiskFormat != "" {
+		options = append(options, "format="+disk.DiskFormat)
+	}
+	if disk.CacheMode != "" && disk.CacheMode != "none" {
+		options = append(options, "cache="+disk.CacheMode)
+	}
+	if disk.IOThread {
+		options = append(options, "iothread=1")
+	}
+	if disk.Discard {
+		options = append(options, "discard=on")
+	}
+	if disk.SSD {
+		options = append(options, "ssd=1")
+	}
+	return strings.Join(options, ",")
+}
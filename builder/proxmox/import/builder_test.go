@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type diskImporterMock struct {
+	vmConfig     map[string]interface{}
+	setConfigErr error
+	changes      map[string]interface{}
+	resizedDisk  string
+	resizedTo    string
+}
+
+func (m *diskImporterMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error) {
+	return m.vmConfig, nil
+}
+func (m *diskImporterMock) CreateItemReturnStatus(changes map[string]interface{}, url string) (string, error) {
+	if url != "/nodes/pve/qemu/100/config" {
+		return "", fmt.Errorf("unexpected POST %s", url)
+	}
+	m.changes = changes
+	if m.setConfigErr != nil {
+		return "", m.setConfigErr
+	}
+	return `{"data":"UPID:pve:00001234:00005678:65A0B1C2:qmconfig:100:root@pam:"}`, nil
+}
+func (m *diskImporterMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": "OK"}}, nil
+}
+func (m *diskImporterMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+func (m *diskImporterMock) Delete(url string) error {
+	return nil
+}
+func (m *diskImporterMock) ResizeQemuDiskRaw(vmRef *proxmox.VmRef, disk string, size string) (interface{}, error) {
+	m.resizedDisk = disk
+	m.resizedTo = size
+	return nil, nil
+}
+
+var _ diskImporter = &diskImporterMock{}
+
+func TestImportBootDisk(t *testing.T) {
+	cs := []struct {
+		name            string
+		config          *Config
+		vmConfig        map[string]interface{}
+		setConfigErr    error
+		expectError     bool
+		expectedChanges map[string]interface{}
+		expectedResize  string
+	}{
+		{
+			name: "import into first free scsi slot and boot from it",
+			config: &Config{
+				ImageFile: "local:import/noble.qcow2",
+				BootDisk:  bootDiskConfig{Type: "scsi", StoragePool: "local-lvm"},
+			},
+			vmConfig: map[string]interface{}{
+				"scsi0": "local-lvm:vm-100-disk-0,size=4G",
+				"ide2":  "local:iso/seed.iso,media=cdrom",
+			},
+			expectedChanges: map[string]interface{}{
+				"scsi1": "local-lvm:0,import-from=local:import/noble.qcow2",
+				"ide0":  "local-lvm:cloudinit",
+				"boot":  "order=scsi1",
+			},
+		},
+		{
+			name: "ide boot disk does not share the cloud-init slot",
+			config: &Config{
+				ImageFile: "local:import/noble.qcow2",
+				BootDisk:  bootDiskConfig{Type: "ide", StoragePool: "local-lvm", Discard: true, SSD: true},
+			},
+			vmConfig: map[string]interface{}{},
+			expectedChanges: map[string]interface{}{
+				"ide0": "local-lvm:0,import-from=local:import/noble.qcow2,discard=on,ssd=1",
+				"ide1": "local-lvm:cloudinit",
+				"boot": "order=ide0",
+			},
+		},
+		{
+			name: "configured boot order and disk size",
+			config: &Config{
+				Config:    proxmoxcommon.Config{Boot: "order=virtio0;net0"},
+				ImageFile: "local:import/noble.qcow2",
+				BootDisk:  bootDiskConfig{Type: "virtio", StoragePool: "ceph", Size: "20G", DiskFormat: "raw", CacheMode: "writeback"},
+			},
+			vmConfig: map[string]interface{}{},
+			expectedChanges: map[string]interface{}{
+				"virtio0": "ceph:0,import-from=local:import/noble.qcow2,format=raw,cache=writeback",
+				"ide0":    "ceph:cloudinit",
+			},
+			expectedResize: "20G",
+		},
+		{
+			name: "no free ide slot for cloud-init, error",
+			config: &Config{
+				ImageFile: "local:import/noble.qcow2",
+				BootDisk:  bootDiskConfig{Type: "scsi", StoragePool: "local-lvm"},
+			},
+			vmConfig: map[string]interface{}{
+				"ide0": "x", "ide1": "x", "ide2": "x", "ide3": "x",
+			},
+			expectError: true,
+		},
+		{
+			name: "SetVmConfig error is returned",
+			config: &Config{
+				ImageFile: "local:import/noble.qcow2",
+				BootDisk:  bootDiskConfig{Type: "scsi", StoragePool: "local-lvm"},
+			},
+			setConfigErr: fmt.Errorf("import failed"),
+			expectError:  true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			client := &diskImporterMock{vmConfig: c.vmConfig, setConfigErr: c.setConfigErr}
+
+			vmRef := proxmox.NewVmRef(100)
+			vmRef.SetNode("pve")
+			err := importBootDisk(context.TODO(), packersdk.TestUi(t), client, vmRef, c.config)
+			if err != nil && !c.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err == nil && c.expectError {
+				t.Fatal("expected an error, got none")
+			}
+			if c.expectError {
+				return
+			}
+
+			if len(client.changes) != len(c.expectedChanges) {
+				t.Errorf("Expected changes %v, got %v", c.expectedChanges, client.changes)
+			}
+			for key, val := range c.expectedChanges {
+				if client.changes[key] != val {
+					t.Errorf("Expected %q to be %q, got %q", key, val, client.changes[key])
+				}
+			}
+			if client.resizedTo != c.expectedResize {
+				t.Errorf("Expected resize to %q, got %q", c.expectedResize, client.resizedTo)
+			}
+		})
+	}
+}
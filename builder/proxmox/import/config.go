@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,bootDiskConfig
+
+package proxmoximport
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	common "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type Config struct {
+	common.Config `mapstructure:",squash"`
+
+	// Path to a disk image already present on the Proxmox cluster, expressed
+	// as a proxmox datastore path of the `import` content type, for example
+	// `local:import/noble-server-cloudimg-amd64.qcow2`.
+	// Either `image_file` OR `image_url` must be specifed.
+	ImageFile string `mapstructure:"image_file"`
+	// URL or local path to the disk image to import. Packer downloads the
+	// image and uploads it to `image_storage_pool`, unless `image_download_pve`
+	// is set.
+	ImageURL string `mapstructure:"image_url"`
+	// Multiple URLs for the disk image. Packer tries them in order until one
+	// succeeds.
+	ImageURLs []string `mapstructure:"image_urls"`
+	// The checksum of the disk image, in the same format as `iso_checksum`,
+	// for example `sha256:<hash>` or `file:<url of a checksum file>`.
+	// Required when `image_url` or `image_urls` is set; `none` disables the check.
+	ImageChecksum string `mapstructure:"image_checksum"`
+	// The path where the downloaded disk image is stored locally before
+	// being uploaded. Defaults to the Packer cache directory.
+	ImageTargetPath string `mapstructure:"image_target_path"`
+	// Proxmox storage pool onto which to upload or download the disk image.
+	// The storage must allow the `import` content type (Proxmox VE 8.2 and later).
+	ImageStoragePool string `mapstructure:"image_storage_pool"`
+	// Download the disk image directly from the PVE node rather than through Packer.
+	//
+	// Defaults to `false`
+	ImageDownloadPVE bool `mapstructure:"image_download_pve"`
+	// The format of the disk image. Can be `qcow2`, `raw` or `vmdk`.
+	// Defaults to the extension of the image file name.
+	ImageFormat string `mapstructure:"image_format"`
+
+	// The disk the image is imported into. It becomes the boot disk of the VM,
+	// unless `boot` is set. Disks listed in `disks` are added in addition.
+	//
+	// HCL2 example:
+	//
+	// ```hcl
+	//
+	//	boot_disk {
+	//	  type         = "scsi"
+	//	  storage_pool = "local-lvm"
+	//	  disk_size    = "20G"
+	//	}
+	//
+	// ```
+	// See [Boot Disk](#boot-disk) for additional options.
+	BootDisk bootDiskConfig `mapstructure:"boot_disk" required:"true"`
+}
+
+type bootDiskConfig struct {
+	// The type of disk. Can be `scsi`, `sata`, `virtio` or
+	// `ide`. Defaults to `scsi`.
+	Type string `mapstructure:"type"`
+	// Required. Name of the Proxmox storage pool
+	// to import the disk image into.
+	StoragePool string `mapstructure:"storage_pool"`
+	// The size the disk is grown to after the import, for example `20G`.
+	// Must not be smaller than the virtual size of the image.
+	// Defaults to the virtual size of the image.
+	Size string `mapstructure:"disk_size"`
+	// How to cache operations to the disk. Can be
+	// `none`, `writethrough`, `writeback`, `unsafe` or `directsync`.
+	// Defaults to `none`.
+	CacheMode string `mapstructure:"cache_mode"`
+	// The format of the imported disk. Can be `raw`, `qcow2` or `vmdk`.
+	// Defaults to the default format of the storage pool.
+	DiskFormat string `mapstructure:"format"`
+	// Create one I/O thread per storage controller, rather
+	// than a single thread for all I/O. This can increase performance when
+	// multiple disks are used. Requires `virtio-scsi-single` controller and a
+	// `scsi` or `virtio` disk. Defaults to `false`.
+	IOThread bool `mapstructure:"io_thread"`
+	// Relay TRIM commands to the underlying storage. Defaults
+	// to `false`. See the
+	// [Proxmox documentation](https://pve.proxmox.com/pve-docs/pve-admin-guide.html#qm_hard_disk_discard)
+	// for for further information.
+	Discard bool `mapstructure:"discard"`
+	// Drive will be presented to the guest as solid-state drive
+	// rather than a rotational disk. Not supported for `virtio` disks.
+	// Defaults to `false`.
+	SSD bool `mapstructure:"ssd"`
+}
+
+var validImageFormats = []string{"qcow2", "raw", "vmdk"}
+
+func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
+	var errs *packersdk.MultiError
+	_, warnings, merrs := c.Config.Prepare(c, raws...)
+	if merrs != nil {
+		errs = packersdk.MultiErrorAppend(errs, merrs)
+	}
+
+	// Either a disk image already on the cluster should be referenced in
+	// image_file, OR a URL (possibly to a local file) to an image that will
+	// be downloaded.
+	if c.ImageURL != "" {
+		c.ImageURLs = append([]string{c.ImageURL}, c.ImageURLs...)
+		c.ImageURL = ""
+	}
+	if c.ImageFile != "" && len(c.ImageURLs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("only one of image_file or image_url(s) can be specified"))
+	}
+	if c.ImageFile == "" && len(c.ImageURLs) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("one of image_file or image_url(s) must be specified"))
+	}
+	if c.ImageFile != "" && !strings.Contains(c.ImageFile, ":") {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("image_file %q must be a proxmox datastore path, such as local:import/image.qcow2", c.ImageFile))
+	}
+	if c.ImageDownloadPVE && len(c.ImageURLs) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("image_download_pve can only be used together with image_url"))
+	}
+
+	if len(c.ImageURLs) > 0 {
+		if c.ImageStoragePool == "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("image_storage_pool must be specified when using image_url"))
+		}
+
+		if c.ImageFormat == "" {
+			c.ImageFormat = imageFormatFromName(imageFileName(c.ImageURLs[0]))
+			log.Printf("image_format not set, using %q from the image file name", c.ImageFormat)
+		}
+
+		switch c.ImageChecksum {
+		case "":
+			errs = packersdk.MultiErrorAppend(errs, errors.New("image_checksum must be specified"))
+		case "none":
+			warnings = append(warnings, "An image_checksum of 'none' was specified. A checksum is highly recommended.")
+		default:
+			// Resolves checksum files and validates the checksum type
+			isoConfig := commonsteps.ISOConfig{
+				ISOChecksum:     c.ImageChecksum,
+				ISOUrls:         c.ImageURLs,
+				TargetExtension: c.ImageFormat,
+			}
+			_, isoErrs := isoConfig.Prepare(&c.Ctx)
+			for _, err := range isoErrs {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("image_checksum: %s", err))
+			}
+			c.ImageChecksum = isoConfig.ISOChecksum
+		}
+	} else if c.ImageFormat == "" {
+		c.ImageFormat = imageFormatFromName(c.ImageFile)
+	}
+	if c.ImageFormat != "" && !slices.Contains(validImageFormats, c.ImageFormat) {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("image_format %q is not supported, must be one of %s", c.ImageFormat, strings.Join(validImageFormats, ", ")))
+	}
+	if c.ImageFormat == "" && len(c.ImageURLs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("image_format could not be determined from the image file name and must be specified"))
+	}
+
+	switch c.BootDisk.Type {
+	case "ide", "sata", "scsi", "virtio":
+	case "":
+		log.Printf("boot_disk type not set, using default 'scsi'")
+		c.BootDisk.Type = "scsi"
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("boot_disk type %q is not supported, must be one of ide, sata, scsi or virtio", c.BootDisk.Type))
+	}
+	if c.BootDisk.StoragePool == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("boot_disk storage_pool must be specified"))
+	}
+	switch c.BootDisk.CacheMode {
+	case "", "none", "writethrough", "writeback", "unsafe", "directsync":
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("boot_disk cache_mode %q is not supported", c.BootDisk.CacheMode))
+	}
+	if c.BootDisk.SSD && c.BootDisk.Type == "virtio" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("boot_disk ssd is not supported for virtio disks"))
+	}
+	if c.BootDisk.IOThread && c.BootDisk.Type != "scsi" && c.BootDisk.Type != "virtio" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("boot_disk io_thread is only supported for scsi and virtio disks"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+	return nil, warnings, nil
+}
+
+// imageFileName returns the file name of the image at the given URL or path,
+// without any query string.
+func imageFileName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawURL)
+}
+
+func imageFormatFromName(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	switch ext {
+	case "img":
+		// Most cloud images with the .img extension are qcow2 images
+		return "qcow2"
+	default:
+		if slices.Contains(validImageFormats, ext) {
+			return ext
+		}
+		return ""
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":  "https://my-proxmox.my-domain:8006/api2/json",
+		"username":     "apiuser@pve",
+		"token":        "xxxx-xxxx-xxxx-xxxx",
+		"node":         "my-proxmox",
+		"ssh_username": "root",
+		"image_file":   "local:import/noble-server-cloudimg-amd64.qcow2",
+		"boot_disk": map[string]interface{}{
+			"storage_pool": "local-lvm",
+		},
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	var c Config
+	_, _, err := c.Prepare(&c, make(map[string]interface{}))
+	if err == nil {
+		t.Fatal("Expected empty configuration to fail")
+	}
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatal("Expected errors to be packersdk.MultiError")
+	}
+
+	required := []string{"username", "token", "proxmox_url", "node", "ssh_username", "image_file", "storage_pool"}
+	for _, param := range required {
+		found := false
+		for _, err := range errs.Errors {
+			if strings.Contains(err.Error(), param) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error about missing parameters %q", param)
+		}
+	}
+}
+
+func TestImageSource(t *testing.T) {
+	tests := []struct {
+		name           string
+		overrides      map[string]interface{}
+		expectFailure  bool
+		expectedFormat string
+	}{
+		{
+			name:           "image_file only, no error",
+			overrides:      map[string]interface{}{},
+			expectFailure:  false,
+			expectedFormat: "qcow2",
+		},
+		{
+			name: "image_url with checksum, no error",
+			overrides: map[string]interface{}{
+				"image_file":         "",
+				"image_url":          "https://cloud-images.ubuntu.com/noble/current/noble-server-cloudimg-amd64.img",
+				"image_checksum":     "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				"image_storage_pool": "local",
+			},
+			expectFailure:  false,
+			expectedFormat: "qcow2",
+		},
+		{
+			name: "explicit image_format, no error",
+			overrides: map[string]interface{}{
+				"image_file":         "",
+				"image_url":          "https://example.com/disk.raw?token=abc",
+				"image_checksum":     "none",
+				"image_storage_pool": "local",
+				"image_format":       "vmdk",
+			},
+			expectFailure:  false,
+			expectedFormat: "vmdk",
+		},
+		{
+			name: "image_url without checksum, fail",
+			overrides: map[string]interface{}{
+				"image_file":         "",
+				"image_url":          "https://example.com/disk.qcow2",
+				"image_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "image_url without storage pool, fail",
+			overrides: map[string]interface{}{
+				"image_file":     "",
+				"image_url":      "https://example.com/disk.qcow2",
+				"image_checksum": "none",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "image_file and image_url, fail",
+			overrides: map[string]interface{}{
+				"image_url":          "https://example.com/disk.qcow2",
+				"image_checksum":     "none",
+				"image_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "unknown format, fail",
+			overrides: map[string]interface{}{
+				"image_file":         "",
+				"image_url":          "https://example.com/disk",
+				"image_checksum":     "none",
+				"image_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "image_download_pve without url, fail",
+			overrides: map[string]interface{}{
+				"image_download_pve": true,
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatalf("expected failure, but prepare succeeded")
+			}
+			if err == nil && c.ImageFormat != tt.expectedFormat {
+				t.Errorf("Expected image_format %q, got %q", tt.expectedFormat, c.ImageFormat)
+			}
+		})
+	}
+}
+
+func TestBootDisk(t *testing.T) {
+	tests := []struct {
+		name          string
+		bootDisk      map[string]interface{}
+		expectFailure bool
+		expectedType  string
+	}{
+		{
+			name:          "type defaults to scsi",
+			bootDisk:      map[string]interface{}{"storage_pool": "local-lvm"},
+			expectFailure: false,
+			expectedType:  "scsi",
+		},
+		{
+			name:          "virtio with io_thread, no error",
+			bootDisk:      map[string]interface{}{"storage_pool": "local-lvm", "type": "virtio", "io_thread": true},
+			expectFailure: false,
+			expectedType:  "virtio",
+		},
+		{
+			name:          "invalid type, fail",
+			bootDisk:      map[string]interface{}{"storage_pool": "local-lvm", "type": "nvme"},
+			expectFailure: true,
+		},
+		{
+			name:          "virtio with ssd, fail",
+			bootDisk:      map[string]interface{}{"storage_pool": "local-lvm", "type": "virtio", "ssd": true},
+			expectFailure: true,
+		},
+		{
+			name:          "sata with io_thread, fail",
+			bootDisk:      map[string]interface{}{"storage_pool": "local-lvm", "type": "sata", "io_thread": true},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid cache mode, fail",
+			bootDisk:      map[string]interface{}{"storage_pool": "local-lvm", "cache_mode": "fast"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["boot_disk"] = tt.bootDisk
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatalf("expected failure, but prepare succeeded")
+			}
+			if err == nil && c.BootDisk.Type != tt.expectedType {
+				t.Errorf("Expected boot_disk type %q, got %q", tt.expectedType, c.BootDisk.Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepDownloadImageOnPVE downloads a disk image directly to the import storage
+// of the PVE node. The checksum is verified on the PVE node, not by Packer.
+type stepDownloadImageOnPVE struct{}
+
+func (s *stepDownloadImageOnPVE) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(proxmox.TaskClient)
+	c := state.Get("import-config").(*Config)
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
+
+	for _, imageURL := range c.ImageURLs {
+		filename := storageFileName(imageURL, c.ImageFormat)
+		params := map[string]interface{}{
+			"content":  "import",
+			"url":      imageURL,
+			"filename": filename,
+		}
+		if c.ImageChecksum != "none" {
+			// Prepare normalized the checksum to the <type>:<value> form
+			checksumType, checksum, _ := strings.Cut(c.ImageChecksum, ":")
+			params["checksum-algorithm"] = checksumType
+			params["checksum"] = checksum
+		}
+
+		ui.Say(fmt.Sprintf("Downloading disk image %s to node %s", imageURL, c.Node))
+		_, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/storage/%s/download-url", c.Node, c.ImageStoragePool))
+		if ctx.Err() != nil {
+			state.Put("error", ctx.Err())
+			return multistep.ActionHalt
+		}
+		// On error continues with the next URL and logs the error
+		if err != nil {
+			log.Printf("[ERROR] - failed to download disk image from %s: %s", imageURL, err)
+			continue
+		}
+
+		c.ImageFile = fmt.Sprintf("%s:import/%s", c.ImageStoragePool, filename)
+		ui.Message(fmt.Sprintf("Downloaded disk image to %s", c.ImageFile))
+		return multistep.ActionContinue
+	}
+
+	err := fmt.Errorf("failed to download disk image with all the provided URLs, attempted: %s", strings.Join(c.ImageURLs, ", "))
+	state.Put("error", err)
+	ui.Error(err.Error())
+	return multistep.ActionHalt
+}
+
+func (s *stepDownloadImageOnPVE) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepUploadImage uploads a downloaded disk image to the import storage of
+// the node, and points image_file at the uploaded volume. The image is
+// removed during cleanup when the build fails.
+type stepUploadImage struct {
+	// Volume of the uploaded image
+	uploaded string
+}
+
+type imageUploader interface {
+	proxmoxcommon.TaskClient
+	Upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader) (upid string, err error)
+	DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+}
+
+var _ imageUploader = &proxmoxcommon.Client{}
+
+func (s *stepUploadImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(imageUploader)
+	c := state.Get("import-config").(*Config)
+
+	p := state.Get(downloadPathKey).(string)
+	if p == "" {
+		err := fmt.Errorf("path to downloaded disk image was empty")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	imagePath, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	r, err := os.Open(imagePath)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer r.Close()
+
+	filename := storageFileName(c.ImageURLs[0], c.ImageFormat)
+	ui.Say(fmt.Sprintf("Uploading disk image %s to %s", filename, c.ImageStoragePool))
+	upid, err := client.Upload(ctx, c.Node, c.ImageStoragePool, "import", filename, r)
+	if err == nil {
+		tracker := &proxmoxcommon.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+		_, err = tracker.Wait(ctx, upid)
+	}
+	if err != nil {
+		err := fmt.Errorf("error uploading disk image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	c.ImageFile = fmt.Sprintf("%s:import/%s", c.ImageStoragePool, filename)
+	s.uploaded = c.ImageFile
+	ui.Message(fmt.Sprintf("Uploaded disk image to %s", c.ImageFile))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadImage) Cleanup(state multistep.StateBag) {
+	if s.uploaded == "" {
+		return
+	}
+	if _, ok := state.GetOk("success"); ok {
+		return
+	}
+	c := state.Get("import-config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(imageUploader)
+
+	// Fake a VM reference, DeleteVolume just needs the node to be valid
+	vmRef := &proxmoxapi.VmRef{}
+	vmRef.SetNode(c.Node)
+	vmRef.SetVmType("qemu")
+
+	_, err := client.DeleteVolume(vmRef, c.ImageStoragePool, s.uploaded)
+	if errors.Is(err, proxmoxcommon.ErrNotFound) {
+		log.Printf("uploaded disk image %s is already gone", s.uploaded)
+		s.uploaded = ""
+		return
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+		return
+	}
+	ui.Message(fmt.Sprintf("Deleted uploaded disk image %s", s.uploaded))
+	s.uploaded = ""
+}
+
+// storageFileName returns the name an image is stored under on the import
+// storage. Proxmox derives the image format from the file extension, so the
+// extension is replaced when it does not match the format (e.g. `.img`).
+func storageFileName(imageURL string, format string) string {
+	name := imageFileName(imageURL)
+	if ext := path.Ext(name); strings.ToLower(ext) != "."+format {
+		name = strings.TrimSuffix(name, ext) + "." + format
+	}
+	return name
+}
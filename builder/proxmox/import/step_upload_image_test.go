@@ -0,0 +1,168 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type imageUploaderMock struct {
+	uploadFail  bool
+	contentType string
+	filename    string
+	deleted     string
+}
+
+func (m *imageUploaderMock) Upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader) (string, error) {
+	m.contentType = contentType
+	m.filename = filename
+	if m.uploadFail {
+		return "", fmt.Errorf("Testing induced Upload failure")
+	}
+	return "UPID:pve:00001234:00005678:65A0B1C2:imgcopy::root@pam:", nil
+}
+
+func (m *imageUploaderMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	return "", fmt.Errorf("unexpected POST %s", url)
+}
+
+func (m *imageUploaderMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": "OK"}}, nil
+}
+
+func (m *imageUploaderMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (m *imageUploaderMock) Delete(url string) error {
+	return nil
+}
+
+func (m *imageUploaderMock) DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (interface{}, error) {
+	m.deleted = volumeName
+	return nil, nil
+}
+
+var _ imageUploader = &imageUploaderMock{}
+
+func TestUploadImage(t *testing.T) {
+	cs := []struct {
+		name              string
+		imageURL          string
+		imageFormat       string
+		failUpload        bool
+		expectedFilename  string
+		expectedImageFile string
+		expectedAction    multistep.StepAction
+	}{
+		{
+			name:              "upload keeps a matching extension",
+			imageURL:          "https://example.com/images/debian-12-generic-amd64.qcow2",
+			imageFormat:       "qcow2",
+			expectedFilename:  "debian-12-generic-amd64.qcow2",
+			expectedImageFile: "local:import/debian-12-generic-amd64.qcow2",
+			expectedAction:    multistep.ActionContinue,
+		},
+		{
+			name:              "upload replaces the extension with the format",
+			imageURL:          "https://cloud-images.ubuntu.com/noble/current/noble-server-cloudimg-amd64.img?mirror=1",
+			imageFormat:       "qcow2",
+			expectedFilename:  "noble-server-cloudimg-amd64.qcow2",
+			expectedImageFile: "local:import/noble-server-cloudimg-amd64.qcow2",
+			expectedAction:    multistep.ActionContinue,
+		},
+		{
+			name:             "failed upload should return halt",
+			imageURL:         "https://example.com/disk.raw",
+			imageFormat:      "raw",
+			failUpload:       true,
+			expectedFilename: "disk.raw",
+			expectedAction:   multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			downloaded := filepath.Join(t.TempDir(), "image")
+			if err := os.WriteFile(downloaded, []byte("image"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			m := &imageUploaderMock{uploadFail: c.failUpload}
+			config := &Config{
+				Config:           proxmoxcommon.Config{Node: "pve"},
+				ImageURLs:        []string{c.imageURL},
+				ImageFormat:      c.imageFormat,
+				ImageStoragePool: "local",
+			}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("import-config", config)
+			state.Put("proxmoxClient", m)
+			state.Put(downloadPathKey, downloaded)
+
+			step := stepUploadImage{}
+			action := step.Run(context.TODO(), state)
+			if action != c.expectedAction {
+				t.Errorf("Expected action to be %v, got %v", c.expectedAction, action)
+			}
+			if m.contentType != "import" {
+				t.Errorf("Expected upload with content type %q, got %q", "import", m.contentType)
+			}
+			if m.filename != c.expectedFilename {
+				t.Errorf("Expected upload as %q, got %q", c.expectedFilename, m.filename)
+			}
+			if config.ImageFile != c.expectedImageFile {
+				t.Errorf("Expected image_file to be %q, got %q", c.expectedImageFile, config.ImageFile)
+			}
+		})
+	}
+}
+
+func TestUploadImageCleanup(t *testing.T) {
+	cs := []struct {
+		name            string
+		success         bool
+		expectedDeleted string
+	}{
+		{
+			name:            "failed build deletes the uploaded image",
+			expectedDeleted: "local:import/disk.raw",
+		},
+		{
+			name:    "successful build keeps the uploaded image",
+			success: true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &imageUploaderMock{}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("import-config", &Config{Config: proxmoxcommon.Config{Node: "pve"}, ImageStoragePool: "local"})
+			state.Put("proxmoxClient", m)
+			if c.success {
+				state.Put("success", true)
+			}
+
+			step := stepUploadImage{uploaded: "local:import/disk.raw"}
+			step.Cleanup(state)
+			if m.deleted != c.expectedDeleted {
+				t.Errorf("Expected %q to be deleted, got %q", c.expectedDeleted, m.deleted)
+			}
+		})
+	}
+}
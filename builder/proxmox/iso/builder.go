@@ -39,6 +39,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	b.config.ISOs = isoArray
 
 	state.Put("iso-config", &b.config)
+	if b.config.BootISO.ISOChecksum != "" {
+		state.Put("source_iso_checksum", b.config.BootISO.ISOChecksum)
+	}
 
 	preSteps := []multistep.Step{}
 	postSteps := []multistep.Step{}
@@ -49,7 +52,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 type isoVMCreator struct{}
 
-func (*isoVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
-	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
-	return config.Create(vmRef, client)
+func (*isoVMCreator) Create(ctx context.Context, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmox.Client)
+	return proxmox.ClassifyError(config.Create(vmRef, client.Client), "")
 }
@@ -0,0 +1,136 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxlxc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id for the builder
+const BuilderID = "proxmox.lxc"
+
+type Builder struct {
+	config Config
+}
+
+// Builder implements packersdk.Builder
+var _ packersdk.Builder = &Builder{}
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return b.config.Prepare(raws...)
+}
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("lxc-config", &b.config)
+
+	preSteps := []multistep.Step{}
+	// The ssh communicator connects to the container itself, so an
+	// ephemeral key is injected on creation unless credentials are given.
+	if b.config.Comm.Type == "ssh" {
+		preSteps = append(preSteps, &proxmox.StepSshKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
+		})
+	}
+	postSteps := []multistep.Step{}
+
+	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &lxcCreator{})
+	sb.CustomConnect = map[string]multistep.Step{
+		"pct": &stepConnectPct{},
+	}
+	sb.DiskContent = "rootdir"
+	if storage, _, ok := strings.Cut(b.config.TemplateFile, ":"); ok {
+		sb.RequiredStorage = []proxmox.StorageRequirement{
+			{Option: "template_file", StoragePool: storage, Content: "vztmpl"},
+		}
+	}
+	return sb.Run(ctx, ui, hook, state)
+}
+
+// lxcCreator creates a container instead of a VM. The qemu configuration
+// prepared by the shared builder is ignored.
+type lxcCreator struct{}
+
+func (*lxcCreator) Create(ctx context.Context, vmRef *proxmoxapi.VmRef, _ proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmox.Client)
+	c := state.Get("lxc-config").(*Config)
+	comm := state.Get("config").(*proxmox.Config).Comm
+
+	config := generateConfigLxc(c)
+	config.SSHPublicKeys = string(comm.SSHPublicKey)
+
+	return proxmox.ClassifyError(config.CreateLxc(vmRef, client.Client), "")
+}
+
+func generateConfigLxc(c *Config) proxmoxapi.ConfigLxc {
+	config := proxmoxapi.NewConfigLxc()
+	config.Ostemplate = c.TemplateFile
+	config.Hostname = c.VMName
+	config.Description = "Packer ephemeral build VM"
+	config.Cores = c.Cores
+	config.Memory = c.Memory
+	config.Swap = c.Swap
+	config.OsType = c.OSType
+	config.OnBoot = c.Onboot
+	config.Tags = c.Tags
+	config.Unprivileged = c.Unprivileged
+	config.Password = c.RootPassword
+	config.Nameserver = c.Nameserver
+	config.SearchDomain = c.Searchdomain
+	if c.Pool != "" {
+		pool := proxmoxapi.PoolName(c.Pool)
+		config.Pool = &pool
+	}
+	if c.Nesting {
+		config.Features = proxmoxapi.QemuDevice{"nesting": 1}
+	}
+
+	if len(c.Disks) > 0 {
+		config.RootFs = proxmoxapi.QemuDevice{
+			"storage": c.Disks[0].StoragePool,
+			"size":    c.Disks[0].Size,
+		}
+	}
+
+	config.Networks = make(proxmoxapi.QemuDevices)
+	for idx, nic := range c.NICs {
+		dev := proxmoxapi.QemuDevice{
+			"name":   fmt.Sprintf("eth%d", idx),
+			"bridge": nic.Bridge,
+		}
+		ipconfig := lxcIpconfig{}
+		if idx < len(c.Ipconfigs) {
+			ipconfig = c.Ipconfigs[idx]
+		}
+		for k, v := range ipconfig.options() {
+			dev[k] = v
+		}
+		if nic.MACAddress != "" {
+			dev["hwaddr"] = nic.MACAddress
+		}
+		if nic.VLANTag != "" {
+			dev["tag"] = nic.VLANTag
+		}
+		if nic.Firewall {
+			dev["firewall"] = 1
+		}
+		if nic.MTU > 0 {
+			dev["mtu"] = nic.MTU
+		}
+		config.Networks[idx] = dev
+	}
+
+	return config
+}
@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxlxc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// pctCommunicator runs commands and transfers files inside a container by
+// wrapping a communicator to the Proxmox node the container runs on, and
+// invoking `pct exec`, `pct push` and `pct pull` there.
+type pctCommunicator struct {
+	// Communicator connected to the Proxmox node
+	node packersdk.Communicator
+	vmID int
+}
+
+var _ packersdk.Communicator = &pctCommunicator{}
+
+func (c *pctCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	nodeCmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("pct exec %d -- /bin/sh -c %s", c.vmID, proxmox.ShellQuote(cmd.Command)),
+		Stdin:   cmd.Stdin,
+		Stdout:  cmd.Stdout,
+		Stderr:  cmd.Stderr,
+	}
+	log.Printf("[DEBUG] running on node: %s", nodeCmd.Command)
+	if err := c.node.Start(ctx, nodeCmd); err != nil {
+		return err
+	}
+	go func() {
+		cmd.SetExited(nodeCmd.Wait())
+	}()
+	return nil
+}
+
+func (c *pctCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	tmp := nodeTempFile()
+	if err := c.node.Upload(tmp, r, fi); err != nil {
+		return err
+	}
+	defer c.removeNodeFile(tmp)
+
+	perms := ""
+	if fi != nil {
+		perms = fmt.Sprintf(" --perms %04o", (*fi).Mode().Perm())
+	}
+	return c.runOnNode(fmt.Sprintf("pct push %d %s %s%s", c.vmID, proxmox.ShellQuote(tmp), proxmox.ShellQuote(dst), perms))
+}
+
+func (c *pctCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	// Mimic the rsync-like semantics of the other communicators: without
+	// a trailing slash, the source directory itself is created in dst.
+	if !strings.HasSuffix(src, "/") {
+		dst = path.Join(dst, filepath.Base(src))
+	}
+
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range exclude {
+			if ok, _ := filepath.Match(pattern, rel); ok {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		target := path.Join(dst, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return c.runInContainer(fmt.Sprintf("mkdir -p %s", proxmox.ShellQuote(target)))
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.Upload(target, f, &info)
+	})
+}
+
+func (c *pctCommunicator) Download(src string, w io.Writer) error {
+	tmp := nodeTempFile()
+	if err := c.runOnNode(fmt.Sprintf("pct pull %d %s %s", c.vmID, proxmox.ShellQuote(src), proxmox.ShellQuote(tmp))); err != nil {
+		return err
+	}
+	defer c.removeNodeFile(tmp)
+
+	return c.node.Download(tmp, w)
+}
+
+func (c *pctCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	return fmt.Errorf("DownloadDir is not supported by the pct communicator")
+}
+
+// runOnNode runs a command on the Proxmox node, returning an error including
+// its output if the command fails.
+func (c *pctCommunicator) runOnNode(command string) error {
+	var out bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &out,
+		Stderr:  &out,
+	}
+	if err := c.node.Start(context.TODO(), cmd); err != nil {
+		return err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return fmt.Errorf("%q exited with status %d: %s", command, status, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+func (c *pctCommunicator) runInContainer(command string) error {
+	return c.runOnNode(fmt.Sprintf("pct exec %d -- /bin/sh -c %s", c.vmID, proxmox.ShellQuote(command)))
+}
+
+func (c *pctCommunicator) removeNodeFile(p string) {
+	if err := c.runOnNode(fmt.Sprintf("rm -f %s", proxmox.ShellQuote(p))); err != nil {
+		log.Printf("failed to remove temporary file %s from node: %s", p, err)
+	}
+}
+
+func nodeTempFile() string {
+	return fmt.Sprintf("/tmp/packer-pct-%s", uuid.TimeOrderedUUID())
+}
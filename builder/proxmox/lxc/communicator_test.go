@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxlxc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type nodeCommMock struct {
+	commands   []string
+	uploads    []string
+	downloads  []string
+	exitStatus int
+}
+
+func (m *nodeCommMock) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	m.commands = append(m.commands, cmd.Command)
+	go cmd.SetExited(m.exitStatus)
+	return nil
+}
+func (m *nodeCommMock) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	m.uploads = append(m.uploads, dst)
+	return nil
+}
+func (m *nodeCommMock) UploadDir(dst string, src string, exclude []string) error {
+	return nil
+}
+func (m *nodeCommMock) Download(src string, w io.Writer) error {
+	m.downloads = append(m.downloads, src)
+	return nil
+}
+func (m *nodeCommMock) DownloadDir(src string, dst string, exclude []string) error {
+	return nil
+}
+
+var _ packersdk.Communicator = &nodeCommMock{}
+
+func TestPctCommunicatorStart(t *testing.T) {
+	cs := []struct {
+		name            string
+		command         string
+		exitStatus      int
+		expectedCommand string
+	}{
+		{
+			name:            "simple command",
+			command:         "echo hello",
+			expectedCommand: "pct exec 100 -- /bin/sh -c 'echo hello'",
+		},
+		{
+			name:            "command with quotes",
+			command:         "echo 'it works'",
+			expectedCommand: `pct exec 100 -- /bin/sh -c 'echo '"'"'it works'"'"''`,
+		},
+		{
+			name:            "exit status is passed on",
+			command:         "false",
+			exitStatus:      1,
+			expectedCommand: "pct exec 100 -- /bin/sh -c 'false'",
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			node := &nodeCommMock{exitStatus: c.exitStatus}
+			comm := &pctCommunicator{node: node, vmID: 100}
+
+			cmd := &packersdk.RemoteCmd{Command: c.command}
+			if err := comm.Start(context.TODO(), cmd); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if status := cmd.Wait(); status != c.exitStatus {
+				t.Errorf("Expected exit status %d, got %d", c.exitStatus, status)
+			}
+			if cmd.Command != c.command {
+				t.Errorf("Expected original command to be unchanged, got %q", cmd.Command)
+			}
+			if len(node.commands) != 1 || node.commands[0] != c.expectedCommand {
+				t.Errorf("Expected node command %q, got %q", c.expectedCommand, node.commands)
+			}
+		})
+	}
+}
+
+func TestPctCommunicatorUpload(t *testing.T) {
+	node := &nodeCommMock{}
+	comm := &pctCommunicator{node: node, vmID: 100}
+
+	if err := comm.Upload("/etc/motd", bytes.NewBufferString("hello"), nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(node.uploads) != 1 {
+		t.Fatalf("Expected one upload to the node, got %d", len(node.uploads))
+	}
+	tmp := node.uploads[0]
+	expected := []string{
+		"pct push 100 '" + tmp + "' '/etc/motd'",
+		"rm -f '" + tmp + "'",
+	}
+	if strings.Join(node.commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected node commands %q, got %q", expected, node.commands)
+	}
+}
+
+func TestPctCommunicatorDownload(t *testing.T) {
+	cs := []struct {
+		name        string
+		exitStatus  int
+		expectError bool
+	}{
+		{
+			name: "successful pull",
+		},
+		{
+			name:        "failing pull returns an error",
+			exitStatus:  1,
+			expectError: true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			node := &nodeCommMock{exitStatus: c.exitStatus}
+			comm := &pctCommunicator{node: node, vmID: 100}
+
+			err := comm.Download("/var/log/syslog", io.Discard)
+			if err != nil && !c.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err == nil && c.expectError {
+				t.Fatal("expected an error, got none")
+			}
+			if c.expectError {
+				if len(node.downloads) != 0 {
+					t.Errorf("Did not expect a download from the node")
+				}
+				return
+			}
+			if len(node.downloads) != 1 {
+				t.Fatalf("Expected one download from the node, got %d", len(node.downloads))
+			}
+			if !strings.HasPrefix(node.commands[0], "pct pull 100 '/var/log/syslog' '"+node.downloads[0]+"'") {
+				t.Errorf("Unexpected pull command %q", node.commands[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,lxcIpconfig
+
+package proxmoxlxc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+
+	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type Config struct {
+	proxmoxcommon.Config `mapstructure:",squash"`
+
+	// The container template archive to create the container from, in the
+	// form `<storage>:vztmpl/<file>`, for example
+	// `local:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst`.
+	TemplateFile string `mapstructure:"template_file" required:"true"`
+	// Create an unprivileged container. Defaults to `false`.
+	Unprivileged bool `mapstructure:"unprivileged" required:"false"`
+	// Allow nesting inside the container, which is required by some
+	// systemd versions. Defaults to `false`.
+	Nesting bool `mapstructure:"nesting" required:"false"`
+	// The password to set for the root user of the container. When using
+	// the `ssh` communicator with `ssh_password`, this defaults to that password.
+	RootPassword string `mapstructure:"root_password" required:"false"`
+	// Amount of swap memory in megabytes. Defaults to `512`.
+	Swap int `mapstructure:"swap" required:"false"`
+	// The OS type of the container, used by Proxmox to set up the container.
+	// If not given, Proxmox detects it from the template.
+	OSType string `mapstructure:"os_type" required:"false"`
+
+	// Set nameserver IP address(es) of the container.
+	// If not given, the same setting as on the host is used.
+	Nameserver string `mapstructure:"nameserver" required:"false"`
+	// Set the DNS searchdomain of the container.
+	// If not given, the same setting as on the host is used.
+	Searchdomain string `mapstructure:"searchdomain" required:"false"`
+	// Set IP address and gateway of the container network interfaces.
+	// See the [IP Configuration](#ip-configuration) documentation for fields.
+	Ipconfigs []lxcIpconfig `mapstructure:"ipconfig" required:"false"`
+}
+
+// If you have configured more than one network interface, make sure to match the order of
+// `network_adapters` and `ipconfig`. Interfaces without an `ipconfig` block use DHCP.
+//
+// Usage example (JSON):
+//
+// ```json
+// [
+//
+//	{
+//	  "ip": "192.168.1.55/24",
+//	  "gateway": "192.168.1.1",
+//	  "ip6": "fda8:a260:6eda:20::4da/128",
+//	  "gateway6": "fda8:a260:6eda:20::1"
+//	}
+//
+// ]
+// ```
+type lxcIpconfig struct {
+	// Either an IPv4 address (CIDR notation), `dhcp` or `manual`. Defaults to `dhcp`.
+	Ip string `mapstructure:"ip" required:"false"`
+	// IPv4 gateway.
+	Gateway string `mapstructure:"gateway" required:"false"`
+	// Can be an IPv6 address (CIDR notation), `auto` (enables SLAAC), `dhcp` or `manual`.
+	Ip6 string `mapstructure:"ip6" required:"false"`
+	// IPv6 gateway.
+	Gateway6 string `mapstructure:"gateway6" required:"false"`
+}
+
+func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
+	var errs *packersdk.MultiError
+	_, warnings, merrs := c.Config.Prepare(c, raws...)
+	if merrs != nil {
+		errs = packersdk.MultiErrorAppend(errs, merrs)
+	}
+
+	if c.TemplateFile == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("template_file must be specified"))
+	} else if !strings.Contains(c.TemplateFile, ":vztmpl/") {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("template_file %q must be of the form <storage>:vztmpl/<file>", c.TemplateFile))
+	}
+
+	// Containers have a single root filesystem, which is configured through
+	// the first (and only) disk block.
+	if len(c.Disks) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("a disks block for the container root filesystem must be specified"))
+	}
+	if len(c.Disks) > 1 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("only a single disks block is supported for containers"))
+	}
+	if len(c.BootCommand) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("boot_command is not supported for containers"))
+	}
+	if len(c.ISOs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("additional_iso_files is not supported for containers"))
+	}
+	if c.CloudInit {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("cloud_init is not supported for containers"))
+	}
+
+	if c.Swap < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("swap must not be negative"))
+	}
+	if c.Swap == 0 {
+		log.Printf("Swap not set, using default: 512")
+		c.Swap = 512
+	}
+
+	if c.Comm.Type == "pct" {
+		// ssh_host defaults to the address of the node running the container,
+		// read from the cluster status once connected to the API
+		if c.Comm.SSHPassword == "" && c.Comm.SSHPrivateKeyFile == "" && !c.Comm.SSHAgentAuth {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("the pct communicator requires one of ssh_password, ssh_private_key_file or ssh_agent_auth to connect to the Proxmox node"))
+		}
+	}
+	if c.RootPassword == "" && c.Comm.Type == "ssh" {
+		c.RootPassword = c.Comm.SSHPassword
+	}
+	packersdk.LogSecretFilter.Set(c.RootPassword)
+
+	// Check validity of given IP addresses
+	if c.Nameserver != "" {
+		for _, nameserver := range strings.Split(c.Nameserver, " ") {
+			_, err := netip.ParseAddr(nameserver)
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("could not parse nameserver: %s", err))
+			}
+		}
+	}
+	for _, i := range c.Ipconfigs {
+		if i.Ip != "" && i.Ip != "dhcp" && i.Ip != "manual" {
+			_, _, err := net.ParseCIDR(i.Ip)
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("could not parse ipconfig.ip: %s", err))
+			}
+		}
+		if i.Gateway != "" {
+			_, err := netip.ParseAddr(i.Gateway)
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("could not parse ipconfig.gateway: %s", err))
+			}
+		}
+		if i.Ip6 != "" && i.Ip6 != "auto" && i.Ip6 != "dhcp" && i.Ip6 != "manual" {
+			_, _, err := net.ParseCIDR(i.Ip6)
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("could not parse ipconfig.ip6: %s", err))
+			}
+		}
+		if i.Gateway6 != "" {
+			_, err := netip.ParseAddr(i.Gateway6)
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("could not parse ipconfig.gateway6: %s", err))
+			}
+		}
+	}
+	if len(c.NICs) < len(c.Ipconfigs) {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%d ipconfig blocks given, but only %d network interfaces defined", len(c.Ipconfigs), len(c.NICs)))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+	return nil, warnings, nil
+}
+
+// Convert the ipconfig attributes into the address options of a
+// Proxmox-API compatible container network device
+func (c lxcIpconfig) options() map[string]string {
+	options := map[string]string{
+		"ip": "dhcp",
+	}
+	if c.Ip != "" {
+		options["ip"] = c.Ip
+	}
+	if c.Gateway != "" {
+		options["gw"] = c.Gateway
+	}
+	if c.Ip6 != "" {
+		options["ip6"] = c.Ip6
+	}
+	if c.Gateway6 != "" {
+		options["gw6"] = c.Gateway6
+	}
+	return options
+}
@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxlxc
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":         "https://my-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"token":               "xxxx-xxxx-xxxx-xxxx",
+		"node":                "my-proxmox",
+		"ssh_username":        "root",
+		"template_file":       "local:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst",
+		"packer_builder_type": "proxmox-lxc",
+		"disks": []map[string]interface{}{
+			{
+				"storage_pool": "local-lvm",
+				"disk_size":    "8G",
+			},
+		},
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	var c Config
+	_, _, err := c.Prepare(&c, make(map[string]interface{}))
+	if err == nil {
+		t.Fatal("Expected empty configuration to fail")
+	}
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatal("Expected errors to be packersdk.MultiError")
+	}
+
+	required := []string{"username", "token", "proxmox_url", "node", "ssh_username", "template_file", "disks"}
+	for _, param := range required {
+		found := false
+		for _, err := range errs.Errors {
+			if strings.Contains(err.Error(), param) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error about missing parameters %q", param)
+		}
+	}
+}
+
+func TestBasicConfig(t *testing.T) {
+	cfg := mandatoryConfig(t)
+
+	var c Config
+	_, warnings, err := c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if c.Swap != 512 {
+		t.Errorf("Expected swap to default to 512, got %d", c.Swap)
+	}
+}
+
+func TestUnsupportedOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		key           string
+		value         interface{}
+		expectFailure bool
+	}{
+		{
+			name:          "template_file without vztmpl, fail",
+			key:           "template_file",
+			value:         "local:iso/debian.iso",
+			expectFailure: true,
+		},
+		{
+			name: "more than one disk, fail",
+			key:  "disks",
+			value: []map[string]interface{}{
+				{"storage_pool": "local-lvm", "disk_size": "8G"},
+				{"storage_pool": "local-lvm", "disk_size": "8G"},
+			},
+			expectFailure: true,
+		},
+		{
+			name:          "boot_command given, fail",
+			key:           "boot_command",
+			value:         []string{"<enter>"},
+			expectFailure: true,
+		},
+		{
+			name:          "cloud_init given, fail",
+			key:           "cloud_init",
+			value:         true,
+			expectFailure: true,
+		},
+		{
+			name:          "negative swap, fail",
+			key:           "swap",
+			value:         -1,
+			expectFailure: true,
+		},
+		{
+			name:          "custom swap, no error",
+			key:           "swap",
+			value:         1,
+			expectFailure: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg[tt.key] = tt.value
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Errorf("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
+
+func TestPctCommunicator(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrides       map[string]interface{}
+		expectFailure   bool
+		expectedSSHHost string
+	}{
+		{
+			name: "password given, host left to the node address",
+			overrides: map[string]interface{}{
+				"ssh_password": "secret",
+			},
+			expectFailure:   false,
+			expectedSSHHost: "",
+		},
+		{
+			name: "explicit ssh_host is kept",
+			overrides: map[string]interface{}{
+				"ssh_password": "secret",
+				"ssh_host":     "pve1.my-domain",
+			},
+			expectFailure:   false,
+			expectedSSHHost: "pve1.my-domain",
+		},
+		{
+			name:          "no credentials for the node, fail",
+			overrides:     map[string]interface{}{},
+			expectFailure: true,
+		},
+		{
+			name: "other builder type, fail",
+			overrides: map[string]interface{}{
+				"ssh_password":        "secret",
+				"packer_builder_type": "proxmox-iso",
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["communicator"] = "pct"
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatalf("expected failure, but prepare succeeded")
+			}
+			if err != nil {
+				return
+			}
+			if c.Comm.Type != "pct" {
+				t.Errorf("Expected communicator to stay %q, got %q", "pct", c.Comm.Type)
+			}
+			if c.Comm.SSHHost != tt.expectedSSHHost {
+				t.Errorf("Expected ssh_host %q, got %q", tt.expectedSSHHost, c.Comm.SSHHost)
+			}
+		})
+	}
+}
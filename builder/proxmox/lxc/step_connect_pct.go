@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxlxc
+
+import (
+	"context"
+	"fmt"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepConnectPct connects to the Proxmox node over SSH and sets up a
+// communicator that executes everything inside the container with pct.
+type stepConnectPct struct {
+	substep multistep.Step
+}
+
+type nodeAddressLister interface {
+	GetItemListInterfaceArray(url string) ([]interface{}, error)
+}
+
+var _ nodeAddressLister = &proxmox.Client{}
+
+func (s *stepConnectPct) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*proxmox.Config)
+	vmRef := state.Get("vmRef").(*proxmoxapi.VmRef)
+
+	host := c.Comm.SSHHost
+	if host == "" {
+		// pct only reaches the containers of the node it runs on
+		var err error
+		host, err = nodeAddress(state.Get("proxmoxClient").(nodeAddressLister), vmRef.Node())
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	s.substep = &communicator.StepConnectSSH{
+		Config: &c.Comm,
+		Host: func(multistep.StateBag) (string, error) {
+			return host, nil
+		},
+		SSHConfig: c.Comm.SSHConfigFunc(),
+	}
+	if action := s.substep.Run(ctx, state); action != multistep.ActionContinue {
+		return action
+	}
+
+	state.Put("communicator", &pctCommunicator{
+		node: state.Get("communicator").(packersdk.Communicator),
+		vmID: vmRef.VmId(),
+	})
+	return multistep.ActionContinue
+}
+
+// nodeAddress returns the address of the node in the cluster status.
+func nodeAddress(client nodeAddressLister, node string) (string, error) {
+	members, err := client.GetItemListInterfaceArray("/cluster/status")
+	if err != nil {
+		return "", fmt.Errorf("error reading the address of node %s, set ssh_host: %s", node, err)
+	}
+	for _, m := range members {
+		member, _ := m.(map[string]interface{})
+		if member["type"] != "node" || member["name"] != node {
+			continue
+		}
+		if ip, _ := member["ip"].(string); ip != "" {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("could not find the address of node %s in the cluster status, set ssh_host", node)
+}
+
+func (s *stepConnectPct) Cleanup(state multistep.StateBag) {
+	if s.substep != nil {
+		s.substep.Cleanup(state)
+	}
+}
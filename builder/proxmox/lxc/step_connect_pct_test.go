@@ -0,0 +1,33 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxlxc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type nodeAddressListerMock struct {
+	members []interface{}
+}
+
+func (m nodeAddressListerMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return m.members, nil
+}
+
+func TestNodeAddress(t *testing.T) {
+	client := nodeAddressListerMock{members: []interface{}{
+		map[string]interface{}{"type": "cluster", "name": "homelab"},
+		map[string]interface{}{"type": "node", "name": "pve1", "ip": "192.168.1.11"},
+		map[string]interface{}{"type": "node", "name": "pve2", "ip": "192.168.1.12"},
+	}}
+
+	address, err := nodeAddress(client, "pve2")
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.12", address)
+
+	_, err = nodeAddress(client, "pve3")
+	require.ErrorContains(t, err, "set ssh_host")
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id for the builder
+const BuilderID = "proxmox.ova"
+
+type Builder struct {
+	config Config
+}
+
+// Builder implements packersdk.Builder
+var _ packersdk.Builder = &Builder{}
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return b.config.Prepare(raws...)
+}
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ova-config", &b.config)
+
+	preSteps := []multistep.Step{
+		&stepUploadPackage{},
+	}
+	postSteps := []multistep.Step{}
+
+	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &ovaVMCreator{})
+	sb.RequiredStorage = []proxmox.StorageRequirement{
+		{Option: "import_storage_pool", StoragePool: b.config.ImportStoragePool, Content: "import", Upload: true},
+	}
+	if b.config.DiskStoragePool != "" {
+		sb.RequiredStorage = append(sb.RequiredStorage, proxmox.StorageRequirement{Option: "disk_storage_pool", StoragePool: b.config.DiskStoragePool, Content: "images"})
+	}
+	return sb.Run(ctx, ui, hook, state)
+}
+
+type ovaVMCreator struct{}
+
+func (*ovaVMCreator) Create(ctx context.Context, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmox.Client)
+	c := state.Get("ova-config").(*Config)
+	// Disk slots are assigned on the shared builder's copy of the config
+	pc := state.Get("config").(*proxmox.Config)
+
+	// The VM is created without the disks the appliance's disk images are
+	// imported into, they are added with the import afterwards.
+	slots, err := detachImportedDisks(config.Disks, pc, len(c.diskVolumes))
+	if err != nil {
+		return err
+	}
+
+	err = config.Create(vmRef, client.Client)
+	if err != nil {
+		return proxmox.ClassifyError(err, "")
+	}
+	return importDisks(ctx, state.Get("ui").(packersdk.Ui), client, vmRef, pc, slots, c.diskVolumes)
+}
+
+var _ proxmox.TaskClient = &proxmox.Client{}
+
+// detachImportedDisks removes the first count disks from the storage
+// configuration of the VM, and returns the slots they were assigned.
+func detachImportedDisks(storages *proxmoxapi.QemuStorages, c *proxmox.Config, count int) ([]string, error) {
+	slots := make([]string, 0, count)
+	for idx := 0; idx < count; idx++ {
+		slot := c.Disks[idx].AssignedDeviceIndex
+		bus := strings.TrimRight(slot, "0123456789")
+
+		// The storage objects are not exposed as a slice, but as a series of
+		// named fields, see generateProxmoxDisks.
+		var disks interface{}
+		switch bus {
+		case "ide":
+			disks = storages.Ide
+		case "sata":
+			disks = storages.Sata
+		case "scsi":
+			disks = storages.Scsi
+		case "virtio":
+			disks = storages.VirtIO
+		}
+		if disks == nil || reflect.ValueOf(disks).IsNil() {
+			return nil, fmt.Errorf("disk %d was not assigned a slot", idx)
+		}
+		field := reflect.ValueOf(disks).Elem().FieldByName("Disk_" + strings.TrimPrefix(slot, bus))
+		if !field.IsValid() {
+			return nil, fmt.Errorf("disk %d was assigned the invalid slot %q", idx, slot)
+		}
+		field.Set(reflect.Zero(field.Type()))
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// importDisks imports the disk images into the given slots, using Proxmox's
+// import-from disk option, and boots from the first one.
+func importDisks(ctx context.Context, ui packersdk.Ui, client proxmox.TaskClient, vmRef *proxmoxapi.VmRef, c *proxmox.Config, slots []string, volumes []string) error {
+	changes := make(map[string]interface{})
+	for idx, slot := range slots {
+		// A size of 0 lets Proxmox allocate the disk with the size of the image
+		options := []string{
+			c.Disks[idx].StoragePool + ":0",
+			"import-from=" + volumes[idx],
+		}
+		if c.Disks[idx].DiskFormat != "" {
+			options = append(options, "format="+c.Disks[idx].DiskFormat)
+		}
+		if c.Disks[idx].CacheMode != "" && c.Disks[idx].CacheMode != "none" {
+			options = append(options, "cache="+c.Disks[idx].CacheMode)
+		}
+		if c.Disks[idx].AsyncIO != "" {
+			options = append(options, "aio="+c.Disks[idx].AsyncIO)
+		}
+		if c.Disks[idx].IOThread {
+			options = append(options, "iothread=1")
+		}
+		if c.Disks[idx].Discard {
+			options = append(options, "discard=on")
+		}
+		if c.Disks[idx].SSD {
+			options = append(options, "ssd=1")
+		}
+		if c.Disks[idx].ExcludeFromBackup {
+			options = append(options, "backup=0")
+		}
+		changes[slot] = strings.Join(options, ",")
+		log.Printf("importing disk image %s as %s", volumes[idx], slot)
+	}
+	// The boot order Proxmox derives when creating the VM does not include
+	// the imported disks
+	if c.Boot == "" && len(slots) > 0 {
+		changes["boot"] = "order=" + slots[0]
+	}
+
+	ui.Say("Importing disk images")
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+	_, err := tracker.Run(ctx, changes, fmt.Sprintf("/nodes/%s/qemu/%d/config", vmRef.Node(), vmRef.VmId()))
+	if err != nil {
+		return fmt.Errorf("error importing disk images: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type diskImporterMock struct {
+	setVmConfig func(url string, changes map[string]interface{}) error
+}
+
+func (m diskImporterMock) CreateItemReturnStatus(changes map[string]interface{}, url string) (string, error) {
+	if err := m.setVmConfig(url, changes); err != nil {
+		return "", err
+	}
+	return `{"data":"UPID:pve:00001234:00005678:65A0B1C2:qmconfig:100:root@pam:"}`, nil
+}
+func (m diskImporterMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": "OK"}}, nil
+}
+func (m diskImporterMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+func (m diskImporterMock) Delete(url string) error {
+	return nil
+}
+
+var _ proxmox.TaskClient = diskImporterMock{}
+
+func TestImportDisks(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["disks"] = []map[string]interface{}{
+		{"type": "scsi", "storage_pool": "local-lvm", "cache_mode": "writeback", "discard": true, "ssd": true},
+		{"type": "sata", "storage_pool": "local-zfs", "format": "raw", "exclude_from_backup": true},
+		{"type": "scsi", "storage_pool": "local-lvm", "disk_size": "10G"},
+	}
+	var c Config
+	_, _, err := c.Prepare(cfg)
+	require.NoError(t, err)
+
+	// Disk slots as assigned when generating the VM's storage configuration
+	c.Disks[0].AssignedDeviceIndex = "scsi0"
+	c.Disks[1].AssignedDeviceIndex = "sata0"
+	c.Disks[2].AssignedDeviceIndex = "scsi1"
+	storages := &proxmoxapi.QemuStorages{
+		Ide:    &proxmoxapi.QemuIdeDisks{},
+		Sata:   &proxmoxapi.QemuSataDisks{Disk_0: &proxmoxapi.QemuSataStorage{}},
+		Scsi:   &proxmoxapi.QemuScsiDisks{Disk_0: &proxmoxapi.QemuScsiStorage{}, Disk_1: &proxmoxapi.QemuScsiStorage{}},
+		VirtIO: &proxmoxapi.QemuVirtIODisks{},
+	}
+
+	slots, err := detachImportedDisks(storages, &c.Config, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"scsi0", "sata0"}, slots)
+	assert.Nil(t, storages.Scsi.Disk_0, "imported disk should be detached")
+	assert.Nil(t, storages.Sata.Disk_0, "imported disk should be detached")
+	assert.NotNil(t, storages.Scsi.Disk_1, "additional disk should be kept")
+
+	volumes := []string{"local:import/appliance.ova/appliance-disk1.vmdk", "local:import/appliance.ova/appliance-disk2.vmdk"}
+	client := diskImporterMock{
+		setVmConfig: func(url string, changes map[string]interface{}) error {
+			assert.Equal(t, "/nodes/pve/qemu/100/config", url)
+			assert.Equal(t, map[string]interface{}{
+				"scsi0": "local-lvm:0,import-from=local:import/appliance.ova/appliance-disk1.vmdk,cache=writeback,discard=on,ssd=1",
+				"sata0": "local-zfs:0,import-from=local:import/appliance.ova/appliance-disk2.vmdk,format=raw,backup=0",
+				"boot":  "order=scsi0",
+			}, changes)
+			return nil
+		},
+	}
+	vmRef := proxmoxapi.NewVmRef(100)
+	vmRef.SetNode("pve")
+	err = importDisks(context.TODO(), packersdk.TestUi(t), client, vmRef, &c.Config, slots, volumes)
+	require.NoError(t, err)
+}
+
+func TestImportDisksFailure(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["boot"] = "order=sata0"
+	var c Config
+	_, _, err := c.Prepare(cfg)
+	require.NoError(t, err)
+
+	client := diskImporterMock{
+		setVmConfig: func(url string, changes map[string]interface{}) error {
+			assert.NotContains(t, changes, "boot", "configured boot order should be kept")
+			return fmt.Errorf("storage local-lvm does not exist")
+		},
+	}
+	err = importDisks(context.TODO(), packersdk.TestUi(t), client, proxmoxapi.NewVmRef(100), &c.Config, []string{"scsi0"}, []string{"local:import/appliance-disk1.vmdk"})
+	assert.ErrorContains(t, err, "storage local-lvm does not exist")
+}
+
+func TestDetachUnassignedDisk(t *testing.T) {
+	var c Config
+	_, _, err := c.Prepare(mandatoryConfig(t))
+	require.NoError(t, err)
+
+	_, err = detachImportedDisks(&proxmoxapi.QemuStorages{}, &c.Config, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package proxmoxova
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	common "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type Config struct {
+	common.Config `mapstructure:",squash"`
+
+	// Path to the appliance to import: either an `.ova` archive, or an `.ovf`
+	// descriptor with the disk images it references next to it.
+	//
+	// The number of CPUs, the memory, the firmware, the network adapters
+	// and the disks of the virtual machine are taken from the virtual
+	// hardware section of the OVF descriptor, unless they are set in the
+	// configuration.
+	SourcePath string `mapstructure:"source_path" required:"true"`
+	// Proxmox storage pool onto which to upload the appliance's disk images
+	// before they are imported. The storage must allow the `import` content
+	// type (Proxmox VE 8.2 and later). The uploaded files are removed at the
+	// end of the build.
+	ImportStoragePool string `mapstructure:"import_storage_pool" required:"true"`
+	// Name of the Proxmox storage pool to import the appliance's disks into,
+	// and to store the EFI disk on when the appliance uses EFI firmware.
+	// Required unless `disks` is set.
+	DiskStoragePool string `mapstructure:"disk_storage_pool"`
+	// Bridge the network adapters of the appliance are attached to, unless
+	// `network_adapters` is set. Defaults to `vmbr0`.
+	NetworkBridge string `mapstructure:"network_bridge"`
+
+	pkg         *ovfPackage
+	diskVolumes []string
+}
+
+func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
+	var errs *packersdk.MultiError
+
+	// Decode the user configuration on its own first, to find the appliance
+	// and the options its virtual hardware should not override.
+	var user Config
+	err := config.Decode(&user, &config.DecodeOpts{
+		Interpolate: true,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"boot_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.NetworkBridge == "" {
+		user.NetworkBridge = "vmbr0"
+	}
+
+	var pkg *ovfPackage
+	if user.SourcePath != "" {
+		pkg, err = readOVFPackage(user.SourcePath)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_path: %s", err))
+		} else {
+			raws = append(raws[:len(raws):len(raws)], hardwareConfig(pkg.VM, &user))
+		}
+	}
+
+	_, warnings, merrs := c.Config.Prepare(c, raws...)
+	if merrs != nil {
+		errs = packersdk.MultiErrorAppend(errs, merrs)
+	}
+	c.pkg = pkg
+
+	if c.NetworkBridge == "" {
+		c.NetworkBridge = "vmbr0"
+	}
+	if c.SourcePath == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("source_path must be specified"))
+	}
+	if c.ImportStoragePool == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("import_storage_pool must be specified"))
+	}
+	if c.DiskStoragePool == "" && len(user.Disks) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("disk_storage_pool must be specified when disks is not set"))
+	}
+	if pkg != nil {
+		log.Printf("importing appliance %q with %d disk(s)", pkg.VM.Name, len(pkg.VM.Disks))
+		if len(pkg.VM.Disks) == 0 {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("source_path: the appliance has no disks to import"))
+		}
+		// Disks set in the configuration replace the ones mapped from
+		// the appliance, and the disk images are imported into them in order.
+		if len(c.Disks) < len(pkg.VM.Disks) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("the appliance has %d disks, but only %d are defined in disks", len(pkg.VM.Disks), len(c.Disks)))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+	return nil, warnings, nil
+}
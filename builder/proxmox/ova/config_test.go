@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":         "https://my-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"token":               "xxxx-xxxx-xxxx-xxxx",
+		"node":                "my-proxmox",
+		"ssh_username":        "root",
+		"source_path":         "testdata/appliance.ovf",
+		"import_storage_pool": "local",
+		"disk_storage_pool":   "local-lvm",
+		"packer_builder_type": "proxmox-ova",
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	var c Config
+	_, _, err := c.Prepare(make(map[string]interface{}))
+	if err == nil {
+		t.Fatal("Expected empty configuration to fail")
+	}
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatal("Expected errors to be packersdk.MultiError")
+	}
+
+	required := []string{"username", "token", "proxmox_url", "node", "ssh_username", "source_path", "import_storage_pool", "disk_storage_pool"}
+	for _, param := range required {
+		found := false
+		for _, err := range errs.Errors {
+			if strings.Contains(err.Error(), param) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error about missing parameters %q", param)
+		}
+	}
+}
+
+func TestHardwareMapping(t *testing.T) {
+	cfg := mandatoryConfig(t)
+
+	var c Config
+	_, warnings, err := c.Prepare(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	if c.Cores != 2 || c.Sockets != 2 {
+		t.Errorf("Expected 2 sockets with 2 cores, got %d sockets with %d cores", c.Sockets, c.Cores)
+	}
+	if c.Memory != 4096 {
+		t.Errorf("Expected memory 4096, got %d", c.Memory)
+	}
+	if c.OS != "l26" {
+		t.Errorf("Expected os l26, got %q", c.OS)
+	}
+	if c.SCSIController != "lsi" {
+		t.Errorf("Expected scsi_controller lsi, got %q", c.SCSIController)
+	}
+	if c.BIOS != "" {
+		t.Errorf("Expected default bios, got %q", c.BIOS)
+	}
+
+	if len(c.NICs) != 2 {
+		t.Fatalf("Expected 2 network adapters, got %d", len(c.NICs))
+	}
+	for idx, model := range []string{"vmxnet3", "e1000"} {
+		if c.NICs[idx].Model != model || c.NICs[idx].Bridge != "vmbr0" {
+			t.Errorf("Expected network adapter %d to be a %s on vmbr0, got a %s on %s", idx, model, c.NICs[idx].Model, c.NICs[idx].Bridge)
+		}
+	}
+
+	if len(c.Disks) != 2 {
+		t.Fatalf("Expected 2 disks, got %d", len(c.Disks))
+	}
+	for idx, disk := range []struct{ Type, Size string }{{"scsi", "16G"}, {"sata", "1G"}} {
+		if c.Disks[idx].Type != disk.Type || c.Disks[idx].Size != disk.Size || c.Disks[idx].StoragePool != "local-lvm" {
+			t.Errorf("Expected disk %d to be a %s %s disk on local-lvm, got a %s %s disk on %s", idx, disk.Size, disk.Type, c.Disks[idx].Size, c.Disks[idx].Type, c.Disks[idx].StoragePool)
+		}
+	}
+}
+
+func TestEFIHardwareMapping(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["source_path"] = "testdata/efi-appliance.ovf"
+
+	var c Config
+	_, _, err := c.Prepare(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cores != 2 || c.Sockets != 1 {
+		t.Errorf("Expected 1 socket with 2 cores, got %d sockets with %d cores", c.Sockets, c.Cores)
+	}
+	if c.Memory != 8192 {
+		t.Errorf("Expected memory 8192, got %d", c.Memory)
+	}
+	if c.OS != "win11" {
+		t.Errorf("Expected os win11, got %q", c.OS)
+	}
+	if c.SCSIController != "pvscsi" {
+		t.Errorf("Expected scsi_controller pvscsi, got %q", c.SCSIController)
+	}
+	if c.BIOS != "ovmf" {
+		t.Errorf("Expected bios ovmf, got %q", c.BIOS)
+	}
+	if c.EFIConfig.EFIStoragePool != "local-lvm" || c.EFIConfig.EFIType != "4m" || !c.EFIConfig.PreEnrolledKeys {
+		t.Errorf("Expected a 4m EFI disk with pre-enrolled keys on local-lvm, got %+v", c.EFIConfig)
+	}
+	if len(c.NICs) != 1 || c.NICs[0].Model != "e1000e" {
+		t.Errorf("Expected one e1000e network adapter, got %+v", c.NICs)
+	}
+	if len(c.Disks) != 1 || c.Disks[0].Size != "40G" {
+		t.Errorf("Expected one 40G disk, got %+v", c.Disks)
+	}
+}
+
+func TestHardwareOverrides(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["source_path"] = "testdata/efi-appliance.ovf"
+	cfg["cores"] = 8
+	cfg["memory"] = 2048
+	cfg["os"] = "win10"
+	cfg["bios"] = "seabios"
+	cfg["network_bridge"] = "vmbr1"
+	cfg["disks"] = []map[string]interface{}{
+		{"type": "virtio", "storage_pool": "ceph", "disk_size": "100G"},
+		{"type": "scsi", "storage_pool": "ceph", "disk_size": "10G"},
+	}
+
+	var c Config
+	_, _, err := c.Prepare(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cores != 8 || c.Sockets != 1 {
+		t.Errorf("Expected 1 socket with 8 cores, got %d sockets with %d cores", c.Sockets, c.Cores)
+	}
+	if c.Memory != 2048 {
+		t.Errorf("Expected memory 2048, got %d", c.Memory)
+	}
+	if c.OS != "win10" {
+		t.Errorf("Expected os win10, got %q", c.OS)
+	}
+	if c.BIOS != "seabios" || c.EFIConfig.EFIStoragePool != "" {
+		t.Errorf("Expected seabios without EFI disk, got %q with %+v", c.BIOS, c.EFIConfig)
+	}
+	if len(c.NICs) != 1 || c.NICs[0].Bridge != "vmbr1" {
+		t.Errorf("Expected one network adapter on vmbr1, got %+v", c.NICs)
+	}
+	if len(c.Disks) != 2 || c.Disks[0].Type != "virtio" || c.Disks[0].StoragePool != "ceph" {
+		t.Errorf("Expected the configured disks, got %+v", c.Disks)
+	}
+}
+
+func TestDiskValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name: "disks given, no disk_storage_pool needed",
+			overrides: map[string]interface{}{
+				"disk_storage_pool": "",
+				"disks": []map[string]interface{}{
+					{"storage_pool": "local-lvm"},
+					{"storage_pool": "local-lvm"},
+				},
+			},
+			expectFailure: false,
+		},
+		{
+			name: "fewer disks than the appliance, fail",
+			overrides: map[string]interface{}{
+				"disks": []map[string]interface{}{
+					{"storage_pool": "local-lvm"},
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "invalid source_path, fail",
+			overrides: map[string]interface{}{
+				"source_path": "testdata/appliance-disk1.vmdk",
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Errorf("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
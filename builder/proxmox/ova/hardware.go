@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// hardwareConfig maps the virtual hardware of an appliance to builder
+// configuration. Only the options the user configuration leaves unset are
+// returned, so they can be decoded on top of it without overriding anything.
+func hardwareConfig(vm *virtualMachine, user *Config) map[string]interface{} {
+	hw := make(map[string]interface{})
+
+	cores, sockets := vm.CPUs, 1
+	if vm.CoresPerSocket > 0 && vm.CPUs%vm.CoresPerSocket == 0 {
+		cores, sockets = vm.CoresPerSocket, vm.CPUs/vm.CoresPerSocket
+	}
+	if user.Cores == 0 {
+		hw["cores"] = cores
+	}
+	if user.Sockets == 0 {
+		hw["sockets"] = sockets
+	}
+	if user.Memory == 0 {
+		hw["memory"] = vm.MemoryMB
+	}
+	if os := guestOS(vm); user.OS == "" && os != "" {
+		hw["os"] = os
+	}
+	if ctrl := scsiController(vm.SCSIController); user.SCSIController == "" && ctrl != "" {
+		hw["scsi_controller"] = ctrl
+	}
+
+	if vm.Firmware == "efi" && user.BIOS == "" {
+		hw["bios"] = "ovmf"
+		if user.EFIConfig.EFIStoragePool == "" && user.EFIDisk == "" && user.DiskStoragePool != "" {
+			hw["efi_config"] = map[string]interface{}{
+				"efi_storage_pool":  user.DiskStoragePool,
+				"efi_type":          "4m",
+				"pre_enrolled_keys": vm.SecureBoot,
+			}
+		}
+	}
+
+	if len(user.NICs) == 0 && len(vm.NICs) > 0 {
+		nics := make([]map[string]interface{}, 0, len(vm.NICs))
+		for _, nic := range vm.NICs {
+			nics = append(nics, map[string]interface{}{
+				"model":  nicModel(nic.Type),
+				"bridge": user.NetworkBridge,
+			})
+		}
+		hw["network_adapters"] = nics
+	}
+
+	if len(user.Disks) == 0 && len(vm.Disks) > 0 {
+		disks := make([]map[string]interface{}, 0, len(vm.Disks))
+		for _, disk := range vm.Disks {
+			d := map[string]interface{}{
+				"type":         disk.Bus,
+				"storage_pool": user.DiskStoragePool,
+			}
+			if disk.CapacityBytes > 0 {
+				d["disk_size"] = diskSize(disk.CapacityBytes)
+			}
+			disks = append(disks, d)
+		}
+		hw["disks"] = disks
+	}
+
+	log.Printf("configuration derived from the OVF virtual hardware: %v", hw)
+	return hw
+}
+
+// nicModel returns the Proxmox network adapter model matching an OVF
+// ethernet adapter type.
+func nicModel(adapterType string) string {
+	switch strings.ToLower(adapterType) {
+	case "e1000e":
+		return "e1000e"
+	case "vmxnet3":
+		return "vmxnet3"
+	case "pcnet32":
+		return "rtl8139"
+	case "virtio":
+		return "virtio"
+	default:
+		return "e1000"
+	}
+}
+
+// scsiController returns the Proxmox SCSI controller emulating an OVF SCSI
+// controller type, or an empty string for the default controller.
+func scsiController(controllerType string) string {
+	switch strings.ToLower(controllerType) {
+	case "":
+		return ""
+	case "virtualscsi":
+		return "pvscsi"
+	case "virtio-scsi":
+		return "virtio-scsi-pci"
+	default:
+		// lsilogic, lsilogicsas and buslogic
+		return "lsi"
+	}
+}
+
+var linuxGuests = []string{
+	"linux", "ubuntu", "debian", "centos", "rhel", "red hat", "sles", "suse",
+	"oracle", "fedora", "rocky", "alma", "photon", "amazonlinux", "coreos",
+	"other26x", "other3x", "other4x", "other5x", "other6x",
+}
+
+// guestOS returns the Proxmox OS type for the guest of the appliance, or an
+// empty string if it cannot be determined.
+func guestOS(vm *virtualMachine) string {
+	osType := strings.ToLower(vm.OSType)
+	switch {
+	case strings.HasPrefix(osType, "windows11"),
+		strings.HasPrefix(osType, "windows2019srvnext"),
+		strings.HasPrefix(osType, "windows2022"):
+		return "win11"
+	case strings.HasPrefix(osType, "windows9"),
+		strings.HasPrefix(osType, "windows2019"):
+		return "win10"
+	case strings.HasPrefix(osType, "windows8"):
+		return "win8"
+	case strings.HasPrefix(osType, "windows7"):
+		return "win7"
+	case strings.HasPrefix(osType, "solaris"):
+		return "solaris"
+	}
+
+	for _, s := range []string{osType, strings.ToLower(vm.OSDescription)} {
+		for _, linux := range linuxGuests {
+			if strings.Contains(s, linux) {
+				return "l26"
+			}
+		}
+	}
+	return ""
+}
+
+// diskSize formats a capacity in bytes as a disk size, rounded up to whole
+// gigabytes.
+func diskSize(bytes int64) string {
+	return fmt.Sprintf("%dG", (bytes+(1<<30)-1)/(1<<30))
+}
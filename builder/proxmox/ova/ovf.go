@@ -0,0 +1,342 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CIM resource types used in the virtual hardware section of an OVF descriptor
+const (
+	resourceProcessor      = 3
+	resourceMemory         = 4
+	resourceIDEController  = 5
+	resourceSCSIController = 6
+	resourceEthernet       = 10
+	resourceDisk           = 17
+	resourceOtherStorage   = 20
+)
+
+type ovfEnvelope struct {
+	XMLName       xml.Name         `xml:"Envelope"`
+	Files         []ovfFile        `xml:"References>File"`
+	Disks         []ovfDisk        `xml:"DiskSection>Disk"`
+	VirtualSystem ovfVirtualSystem `xml:"VirtualSystem"`
+}
+
+type ovfFile struct {
+	ID          string `xml:"id,attr"`
+	Href        string `xml:"href,attr"`
+	Compression string `xml:"compression,attr"`
+}
+
+type ovfDisk struct {
+	DiskID                  string `xml:"diskId,attr"`
+	FileRef                 string `xml:"fileRef,attr"`
+	Capacity                string `xml:"capacity,attr"`
+	CapacityAllocationUnits string `xml:"capacityAllocationUnits,attr"`
+}
+
+type ovfVirtualSystem struct {
+	Name            string             `xml:"Name"`
+	OperatingSystem ovfOperatingSystem `xml:"OperatingSystemSection"`
+	Hardware        ovfVirtualHardware `xml:"VirtualHardwareSection"`
+}
+
+type ovfOperatingSystem struct {
+	OSType      string `xml:"osType,attr"`
+	Description string `xml:"Description"`
+}
+
+type ovfVirtualHardware struct {
+	Items []ovfItem `xml:"Item"`
+	// OVF 2.0 descriptors list disks and network adapters separately
+	StorageItems  []ovfItem `xml:"StorageItem"`
+	EthernetItems []ovfItem `xml:"EthernetPortItem"`
+	// VMware specific settings, such as the firmware
+	Configs []ovfConfig `xml:"Config"`
+}
+
+type ovfItem struct {
+	InstanceID      string   `xml:"InstanceID"`
+	ResourceType    int      `xml:"ResourceType"`
+	ResourceSubType string   `xml:"ResourceSubType"`
+	VirtualQuantity int64    `xml:"VirtualQuantity"`
+	AllocationUnits string   `xml:"AllocationUnits"`
+	Parent          string   `xml:"Parent"`
+	HostResource    []string `xml:"HostResource"`
+	CoresPerSocket  int      `xml:"CoresPerSocket"`
+}
+
+type ovfConfig struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// virtualMachine is the virtual hardware of the appliance described by an
+// OVF descriptor.
+type virtualMachine struct {
+	Name string
+	// VMware guest OS identifier, e.g. `ubuntu64Guest`
+	OSType string
+	// Free-form description of the guest OS
+	OSDescription  string
+	CPUs           int
+	CoresPerSocket int
+	MemoryMB       int64
+	// Either `bios` or `efi`
+	Firmware   string
+	SecureBoot bool
+	// Type of the first SCSI controller, e.g. `lsilogic` or `VirtualSCSI`
+	SCSIController string
+	NICs           []virtualNIC
+	Disks          []virtualDisk
+}
+
+type virtualNIC struct {
+	// Adapter type, e.g. `E1000` or `VmxNet3`
+	Type string
+}
+
+type virtualDisk struct {
+	// Bus of the controller the disk is attached to: `ide`, `sata` or `scsi`
+	Bus           string
+	CapacityBytes int64
+	// Path of the disk image, relative to the OVF descriptor
+	File string
+}
+
+// ovfPackage is an OVA archive or an OVF descriptor with its disk images.
+type ovfPackage struct {
+	// Path to the .ova archive or the .ovf descriptor
+	Path string
+	// Whether the package is a single OVA archive
+	Archive bool
+	VM      *virtualMachine
+}
+
+// readOVFPackage reads the virtual hardware from the OVA archive or OVF
+// descriptor at the given path, and verifies that all disk images it
+// references are present.
+func readOVFPackage(p string) (*ovfPackage, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pkg := &ovfPackage{Path: p}
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".ovf":
+		pkg.VM, err = parseOVF(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, disk := range pkg.VM.Disks {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(p), filepath.FromSlash(disk.File))); err != nil {
+				return nil, fmt.Errorf("disk image referenced by the OVF descriptor: %s", err)
+			}
+		}
+	case ".ova":
+		pkg.Archive = true
+		files := map[string]bool{}
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading OVA archive: %s", err)
+			}
+			files[hdr.Name] = true
+			if pkg.VM == nil && strings.EqualFold(path.Ext(hdr.Name), ".ovf") {
+				pkg.VM, err = parseOVF(tr)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if pkg.VM == nil {
+			return nil, errors.New("OVA archive does not contain an OVF descriptor")
+		}
+		for _, disk := range pkg.VM.Disks {
+			if !files[disk.File] {
+				return nil, fmt.Errorf("disk image %s referenced by the OVF descriptor is missing from the OVA archive", disk.File)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s is neither an .ova nor an .ovf file", p)
+	}
+	return pkg, nil
+}
+
+// parseOVF reads the virtual hardware of the first virtual system in an OVF
+// descriptor.
+func parseOVF(r io.Reader) (*virtualMachine, error) {
+	var env ovfEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, fmt.Errorf("error parsing OVF descriptor: %s", err)
+	}
+
+	vs := env.VirtualSystem
+	vm := &virtualMachine{
+		Name:          strings.TrimSpace(vs.Name),
+		OSType:        vs.OperatingSystem.OSType,
+		OSDescription: strings.TrimSpace(vs.OperatingSystem.Description),
+		Firmware:      "bios",
+	}
+
+	for _, cfg := range vs.Hardware.Configs {
+		switch cfg.Key {
+		case "firmware":
+			vm.Firmware = cfg.Value
+		case "uefi.secureBoot.enabled":
+			vm.SecureBoot = cfg.Value == "true"
+		}
+	}
+
+	items := append(append(append([]ovfItem{}, vs.Hardware.Items...), vs.Hardware.StorageItems...), vs.Hardware.EthernetItems...)
+	controllers := map[string]ovfItem{}
+	for _, item := range items {
+		switch item.ResourceType {
+		case resourceIDEController, resourceSCSIController, resourceOtherStorage:
+			controllers[item.InstanceID] = item
+		}
+	}
+
+	for _, item := range items {
+		switch item.ResourceType {
+		case resourceProcessor:
+			vm.CPUs = int(item.VirtualQuantity)
+			vm.CoresPerSocket = item.CoresPerSocket
+		case resourceMemory:
+			unit, err := allocationUnits(item.AllocationUnits)
+			if err != nil {
+				return nil, fmt.Errorf("memory: %s", err)
+			}
+			vm.MemoryMB = item.VirtualQuantity * unit / (1 << 20)
+		case resourceSCSIController:
+			if vm.SCSIController == "" {
+				vm.SCSIController = item.ResourceSubType
+			}
+		case resourceEthernet:
+			vm.NICs = append(vm.NICs, virtualNIC{Type: item.ResourceSubType})
+		case resourceDisk:
+			disk, err := env.disk(item)
+			if err != nil {
+				return nil, err
+			}
+			disk.Bus = controllerBus(controllers[item.Parent])
+			vm.Disks = append(vm.Disks, disk)
+		}
+	}
+
+	if vm.CPUs == 0 {
+		return nil, errors.New("OVF descriptor does not define the number of CPUs")
+	}
+	if vm.MemoryMB == 0 {
+		return nil, errors.New("OVF descriptor does not define the amount of memory")
+	}
+	return vm, nil
+}
+
+// disk resolves the disk image and capacity of a disk drive item.
+func (env *ovfEnvelope) disk(item ovfItem) (virtualDisk, error) {
+	if len(item.HostResource) == 0 {
+		return virtualDisk{}, fmt.Errorf("disk %s has no host resource", item.InstanceID)
+	}
+	ref := strings.TrimPrefix(item.HostResource[0], "ovf:")
+
+	fileRef := ""
+	var capacity int64
+	switch {
+	case strings.HasPrefix(ref, "/disk/"):
+		id := strings.TrimPrefix(ref, "/disk/")
+		found := false
+		for _, d := range env.Disks {
+			if d.DiskID != id {
+				continue
+			}
+			found = true
+			fileRef = d.FileRef
+			unit, err := allocationUnits(d.CapacityAllocationUnits)
+			if err != nil {
+				return virtualDisk{}, fmt.Errorf("disk %s: %s", id, err)
+			}
+			size, err := strconv.ParseInt(d.Capacity, 10, 64)
+			if err != nil {
+				return virtualDisk{}, fmt.Errorf("disk %s: invalid capacity %q", id, d.Capacity)
+			}
+			capacity = size * unit
+		}
+		if !found {
+			return virtualDisk{}, fmt.Errorf("disk %s not found in the disk section", id)
+		}
+	case strings.HasPrefix(ref, "/file/"):
+		fileRef = strings.TrimPrefix(ref, "/file/")
+	default:
+		return virtualDisk{}, fmt.Errorf("unsupported host resource %q for disk %s", item.HostResource[0], item.InstanceID)
+	}
+
+	if fileRef == "" {
+		return virtualDisk{}, fmt.Errorf("blank disks are not supported, disk %s has no disk image", item.InstanceID)
+	}
+	for _, f := range env.Files {
+		if f.ID != fileRef {
+			continue
+		}
+		if f.Compression != "" {
+			return virtualDisk{}, fmt.Errorf("disk image %s is %s compressed, which is not supported", f.Href, f.Compression)
+		}
+		return virtualDisk{File: f.Href, CapacityBytes: capacity}, nil
+	}
+	return virtualDisk{}, fmt.Errorf("file %s not found in the references section", fileRef)
+}
+
+// controllerBus returns the Proxmox disk type matching a storage controller.
+func controllerBus(controller ovfItem) string {
+	switch controller.ResourceType {
+	case resourceIDEController:
+		return "ide"
+	case resourceOtherStorage:
+		if strings.Contains(strings.ToLower(controller.ResourceSubType), "sata") ||
+			strings.Contains(strings.ToLower(controller.ResourceSubType), "ahci") {
+			return "sata"
+		}
+	}
+	return "scsi"
+}
+
+// allocationUnits returns the number of bytes in one unit of an OVF
+// allocation unit such as `byte * 2^20` or `MegaBytes`.
+func allocationUnits(units string) (int64, error) {
+	u := strings.ToLower(strings.ReplaceAll(units, " ", ""))
+	switch u {
+	case "", "byte", "bytes":
+		return 1, nil
+	case "kilobytes", "kb":
+		return 1 << 10, nil
+	case "megabytes", "mb":
+		return 1 << 20, nil
+	case "gigabytes", "gb":
+		return 1 << 30, nil
+	}
+	if exp, ok := strings.CutPrefix(u, "byte*2^"); ok {
+		n, err := strconv.Atoi(exp)
+		if err == nil && n >= 0 && n < 63 {
+			return 1 << n, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported allocation units %q", units)
+}
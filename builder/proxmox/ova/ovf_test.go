@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeOVA packs the given files into an OVA archive in a temporary directory.
+func writeOVA(t *testing.T, files map[string]string) string {
+	p := filepath.Join(t.TempDir(), "appliance.ova")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	// The OVF descriptor must be the first file of the archive
+	names := []string{}
+	for name := range files {
+		if strings.HasSuffix(name, ".ovf") {
+			names = append([]string{name}, names...)
+		} else {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		content, err := os.ReadFile(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestParseOVF(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected *virtualMachine
+	}{
+		{
+			name: "OVF 1.0 descriptor",
+			path: "testdata/appliance.ovf",
+			expected: &virtualMachine{
+				Name:           "appliance",
+				OSType:         "ubuntu64Guest",
+				OSDescription:  "Ubuntu Linux (64-bit)",
+				CPUs:           4,
+				CoresPerSocket: 2,
+				MemoryMB:       4096,
+				Firmware:       "bios",
+				SCSIController: "lsilogic",
+				NICs:           []virtualNIC{{Type: "VmxNet3"}, {Type: "E1000"}},
+				Disks: []virtualDisk{
+					{Bus: "scsi", CapacityBytes: 16 << 30, File: "appliance-disk1.vmdk"},
+					{Bus: "sata", CapacityBytes: 512 << 20, File: "appliance-disk2.vmdk"},
+				},
+			},
+		},
+		{
+			name: "OVF 2.0 descriptor with EFI firmware",
+			path: "testdata/efi-appliance.ovf",
+			expected: &virtualMachine{
+				Name:           "efi-appliance",
+				OSType:         "windows2019srvNext_64Guest",
+				CPUs:           2,
+				MemoryMB:       8192,
+				Firmware:       "efi",
+				SecureBoot:     true,
+				SCSIController: "VirtualSCSI",
+				NICs:           []virtualNIC{{Type: "E1000e"}},
+				Disks: []virtualDisk{
+					{Bus: "scsi", CapacityBytes: 40 << 30, File: "disks/efi-appliance-disk1.vmdk"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			vm, err := parseOVF(f)
+			if err != nil {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if !reflect.DeepEqual(vm, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, vm)
+			}
+		})
+	}
+}
+
+func TestParseOVFErrors(t *testing.T) {
+	appliance, err := os.ReadFile("testdata/appliance.ovf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		old         string
+		new         string
+		expectedErr string
+	}{
+		{
+			name:        "not XML",
+			old:         "<?xml",
+			new:         "<<<",
+			expectedErr: "error parsing OVF descriptor",
+		},
+		{
+			name:        "compressed disk image",
+			old:         `ovf:id="file1"`,
+			new:         `ovf:id="file1" ovf:compression="gzip"`,
+			expectedErr: "gzip compressed",
+		},
+		{
+			name:        "unknown disk",
+			old:         "ovf:/disk/vmdisk2",
+			new:         "ovf:/disk/vmdisk3",
+			expectedErr: "disk vmdisk3 not found",
+		},
+		{
+			name:        "unsupported capacity units",
+			old:         "byte * 2^30",
+			new:         "sectors",
+			expectedErr: "unsupported allocation units",
+		},
+		{
+			name:        "no memory",
+			old:         "<rasd:ResourceType>4</rasd:ResourceType>",
+			new:         "<rasd:ResourceType>1</rasd:ResourceType>",
+			expectedErr: "amount of memory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ovf := strings.Replace(string(appliance), tt.old, tt.new, 1)
+			_, err := parseOVF(strings.NewReader(ovf))
+			if err == nil {
+				t.Fatal("expected failure, but parsing succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %q", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestReadOVFPackage(t *testing.T) {
+	ova := writeOVA(t, map[string]string{
+		"appliance.ovf":        "testdata/appliance.ovf",
+		"appliance-disk1.vmdk": "testdata/appliance-disk1.vmdk",
+		"appliance-disk2.vmdk": "testdata/appliance-disk2.vmdk",
+	})
+	incompleteOVA := writeOVA(t, map[string]string{
+		"appliance.ovf":        "testdata/appliance.ovf",
+		"appliance-disk1.vmdk": "testdata/appliance-disk1.vmdk",
+	})
+	noOVF := writeOVA(t, map[string]string{
+		"appliance-disk1.vmdk": "testdata/appliance-disk1.vmdk",
+	})
+
+	tests := []struct {
+		name            string
+		path            string
+		expectFailure   bool
+		expectedArchive bool
+	}{
+		{
+			name:            "OVF descriptor",
+			path:            "testdata/appliance.ovf",
+			expectedArchive: false,
+		},
+		{
+			name:            "OVF descriptor with disk images in a subdirectory",
+			path:            "testdata/efi-appliance.ovf",
+			expectedArchive: false,
+		},
+		{
+			name:            "OVA archive",
+			path:            ova,
+			expectedArchive: true,
+		},
+		{
+			name:          "OVA archive missing a disk image, fail",
+			path:          incompleteOVA,
+			expectFailure: true,
+		},
+		{
+			name:          "OVA archive without descriptor, fail",
+			path:          noOVF,
+			expectFailure: true,
+		},
+		{
+			name:          "unsupported file type, fail",
+			path:          "testdata/appliance-disk1.vmdk",
+			expectFailure: true,
+		},
+		{
+			name:          "missing file, fail",
+			path:          "testdata/missing.ova",
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, err := readOVFPackage(tt.path)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but reading succeeded")
+			}
+			if err != nil {
+				return
+			}
+			if pkg.Archive != tt.expectedArchive {
+				t.Errorf("Expected archive to be %t, got %t", tt.expectedArchive, pkg.Archive)
+			}
+			if len(pkg.VM.Disks) == 0 {
+				t.Error("Expected disks to be read from the package")
+			}
+		})
+	}
+}
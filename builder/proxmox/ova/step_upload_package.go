@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepUploadPackage uploads the appliance to the import storage of the node:
+// an OVA archive as a whole, or the disk images referenced by an OVF
+// descriptor. It records the volumes the disks are imported from.
+type stepUploadPackage struct {
+	uploaded []string
+}
+
+type packageUploader interface {
+	proxmox.TaskClient
+	Upload(ctx context.Context, node string, storage string, contentType string, filename string, file io.Reader) (upid string, err error)
+	DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+}
+
+var _ packageUploader = &proxmox.Client{}
+
+func (s *stepUploadPackage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(packageUploader)
+	c := state.Get("ova-config").(*Config)
+
+	c.diskVolumes = nil
+	if c.pkg.Archive {
+		// Proxmox can import disks straight from an OVA archive on an
+		// import storage, addressed as <archive>/<disk image>
+		name := filepath.Base(c.pkg.Path)
+		if err := s.upload(ctx, ui, client, c, c.pkg.Path, name); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		for _, disk := range c.pkg.VM.Disks {
+			c.diskVolumes = append(c.diskVolumes, fmt.Sprintf("%s:import/%s/%s", c.ImportStoragePool, name, disk.File))
+		}
+		return multistep.ActionContinue
+	}
+
+	for _, disk := range c.pkg.VM.Disks {
+		name := path.Base(disk.File)
+		src := filepath.Join(filepath.Dir(c.pkg.Path), filepath.FromSlash(disk.File))
+		if err := s.upload(ctx, ui, client, c, src, name); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		c.diskVolumes = append(c.diskVolumes, fmt.Sprintf("%s:import/%s", c.ImportStoragePool, name))
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadPackage) upload(ctx context.Context, ui packersdk.Ui, client packageUploader, c *Config, src string, name string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ui.Say(fmt.Sprintf("Uploading %s to %s", name, c.ImportStoragePool))
+	upid, err := client.Upload(ctx, c.Node, c.ImportStoragePool, "import", name, r)
+	if err == nil {
+		tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+		_, err = tracker.Wait(ctx, upid)
+	}
+	if err != nil {
+		return fmt.Errorf("error uploading %s: %s", name, err)
+	}
+	s.uploaded = append(s.uploaded, fmt.Sprintf("%s:import/%s", c.ImportStoragePool, name))
+	return nil
+}
+
+func (s *stepUploadPackage) Cleanup(state multistep.StateBag) {
+	if len(s.uploaded) == 0 {
+		return
+	}
+	c := state.Get("ova-config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(packageUploader)
+
+	// Fake a VM reference, DeleteVolume just needs the node to be valid
+	vmRef := &proxmoxapi.VmRef{}
+	vmRef.SetNode(c.Node)
+	vmRef.SetVmType("qemu")
+
+	for _, volume := range s.uploaded {
+		_, err := client.DeleteVolume(vmRef, c.ImportStoragePool, volume)
+		if err != nil {
+			ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+			continue
+		}
+		ui.Message(fmt.Sprintf("Deleted uploaded %s", volume))
+	}
+	s.uploaded = nil
+}
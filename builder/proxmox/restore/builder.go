@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxrestore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id for the builder
+const BuilderID = "proxmox.restore"
+
+// State key of the locally downloaded backup archive
+const downloadPathKey = "downloaded_backup_path"
+
+type Builder struct {
+	config Config
+}
+
+// Builder implements packersdk.Builder
+var _ packersdk.Builder = &Builder{}
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return b.config.Prepare(raws...)
+}
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("restore-config", &b.config)
+
+	preSteps := []multistep.Step{}
+	if b.config.BackupURL != "" {
+		preSteps = append(preSteps,
+			&commonsteps.StepDownload{
+				Checksum:    b.config.BackupChecksum,
+				Description: "backup archive",
+				Extension:   archiveExtension(backupFileName(b.config.BackupURL)),
+				ResultKey:   downloadPathKey,
+				TargetPath:  b.config.BackupTargetPath,
+				Url:         []string{b.config.BackupURL},
+			},
+			&stepUploadBackup{
+				connect: (*proxmox.NodeSSHConfig).Connect,
+			},
+		)
+	}
+	postSteps := []multistep.Step{}
+
+	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &restoreVMCreator{})
+	if storage, _, ok := strings.Cut(b.config.BackupFile, ":"); ok {
+		sb.RequiredStorage = append(sb.RequiredStorage, proxmox.StorageRequirement{Option: "backup_file", StoragePool: storage, Content: "backup"})
+	} else if b.config.BackupStoragePool != "" {
+		sb.RequiredStorage = append(sb.RequiredStorage, proxmox.StorageRequirement{Option: "backup_storage_pool", StoragePool: b.config.BackupStoragePool, Content: "backup"})
+	}
+	if b.config.RestoreStoragePool != "" {
+		sb.RequiredStorage = append(sb.RequiredStorage, proxmox.StorageRequirement{Option: "restore_storage_pool", StoragePool: b.config.RestoreStoragePool, Content: "images"})
+	}
+	return sb.Run(ctx, ui, hook, state)
+}
+
+type restoreVMCreator struct{}
+
+func (*restoreVMCreator) Create(ctx context.Context, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(vmRestorer)
+	c := state.Get("restore-config").(*Config)
+	return restoreVM(ctx, ui, client, vmRef, config, c)
+}
+
+type vmRestorer interface {
+	proxmox.TaskClient
+	GetNextID(currentID int) (int, error)
+	SetVmConfig(*proxmoxapi.VmRef, map[string]interface{}) (interface{}, error)
+	DeleteVm(*proxmoxapi.VmRef) (string, error)
+}
+
+var _ vmRestorer = &proxmox.Client{}
+
+// templateRe matches the template setting of the guest configuration, before
+// the snapshot sections
+var templateRe = regexp.MustCompile(`(?m)^template:\s*1\s*$`)
+
+// restoreVM creates the VM by restoring the backup archive. The hardware of
+// the VM is taken from the backup, only its name, description and tags are
+// set from the build configuration.
+//
+// Backups of templates restore as templates, which can not be started. They
+// are restored under a temporary ID instead, and the VM is a full clone of the
+// restored template.
+func restoreVM(ctx context.Context, ui packersdk.Ui, client vmRestorer, vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, c *Config) error {
+	template, err := isTemplateBackup(client, vmRef.Node(), c.BackupFile)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]interface{}{
+		"vmid":    vmRef.VmId(),
+		"archive": c.BackupFile,
+		// Generate new MAC addresses, the backed up VM may still be running
+		"unique": 1,
+	}
+	if c.RestoreStoragePool != "" {
+		params["storage"] = c.RestoreStoragePool
+	}
+	if c.RestoreBandwidthLimit > 0 {
+		params["bwlimit"] = c.RestoreBandwidthLimit
+	}
+	if c.Pool != "" && !template {
+		params["pool"] = c.Pool
+	}
+
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+	if template {
+		templateID, err := client.GetNextID(0)
+		if err != nil {
+			return fmt.Errorf("error getting an ID for the restored template: %s", err)
+		}
+		templateRef := proxmoxapi.NewVmRef(templateID)
+		templateRef.SetNode(vmRef.Node())
+		templateRef.SetVmType("qemu")
+		params["vmid"] = templateID
+
+		ui.Say(fmt.Sprintf("Restoring template backup %s as template %d", c.BackupFile, templateID))
+		if _, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/qemu", vmRef.Node())); err != nil {
+			return fmt.Errorf("error restoring backup %s: %w", c.BackupFile, err)
+		}
+		defer func() {
+			log.Printf("deleting restored template %d", templateID)
+			if _, err := client.DeleteVm(templateRef); err != nil {
+				ui.Error(fmt.Sprintf("Error deleting restored template %d: %s", templateID, err))
+			}
+		}()
+
+		cloneParams := map[string]interface{}{
+			"newid": vmRef.VmId(),
+			"name":  config.Name,
+			"full":  1,
+		}
+		for _, key := range []string{"storage", "bwlimit"} {
+			if v, ok := params[key]; ok {
+				cloneParams[key] = v
+			}
+		}
+		if c.Pool != "" {
+			cloneParams["pool"] = c.Pool
+		}
+		ui.Say(fmt.Sprintf("Cloning restored template %d to %d", templateID, vmRef.VmId()))
+		if _, err := tracker.Run(ctx, cloneParams, fmt.Sprintf("/nodes/%s/qemu/%d/clone", vmRef.Node(), templateID)); err != nil {
+			return fmt.Errorf("error cloning restored template %d: %w", templateID, err)
+		}
+	} else {
+		ui.Say(fmt.Sprintf("Restoring %s as VM %d", c.BackupFile, vmRef.VmId()))
+		if _, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/qemu", vmRef.Node())); err != nil {
+			return fmt.Errorf("error restoring backup %s: %w", c.BackupFile, err)
+		}
+	}
+
+	changes := map[string]interface{}{
+		"name":        config.Name,
+		"description": config.Description,
+	}
+	if c.Tags != "" {
+		changes["tags"] = c.Tags
+	}
+	_, err = client.SetVmConfig(vmRef, changes)
+	if err != nil {
+		return fmt.Errorf("error configuring restored VM: %s", err)
+	}
+	return nil
+}
+
+// isTemplateBackup reports whether the backup archive is the backup of a
+// template, from the guest configuration stored in the archive.
+func isTemplateBackup(client vmRestorer, node string, archive string) (bool, error) {
+	resp, err := client.GetItemList(fmt.Sprintf("/nodes/%s/vzdump/extractconfig?volume=%s", node, url.QueryEscape(archive)))
+	if err != nil {
+		return false, fmt.Errorf("error reading the configuration of backup %s: %s", archive, err)
+	}
+	config, _ := resp["data"].(string)
+	config, _, _ = strings.Cut(config, "\n[")
+	return templateRe.MatchString(config), nil
+}
@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxrestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUPID = "UPID:my-proxmox:00001234:00005678:65A0B1C2:qmrestore:100:root@pam:"
+
+type vmRestorerMock struct {
+	backupConfig string
+	postErr      error
+	exitStatus   string
+
+	posted  map[string]map[string]interface{}
+	changes map[string]interface{}
+	deleted []int
+}
+
+func (m *vmRestorerMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	if m.postErr != nil {
+		return "", m.postErr
+	}
+	if m.posted == nil {
+		m.posted = map[string]map[string]interface{}{}
+	}
+	m.posted[url] = params
+	return fmt.Sprintf(`{"data":%q}`, testUPID), nil
+}
+func (m *vmRestorerMock) GetItemList(url string) (map[string]interface{}, error) {
+	if strings.Contains(url, "/vzdump/extractconfig") {
+		return map[string]interface{}{"data": m.backupConfig}, nil
+	}
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": m.exitStatus}}, nil
+}
+func (m *vmRestorerMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+func (m *vmRestorerMock) Delete(url string) error {
+	return nil
+}
+func (m *vmRestorerMock) GetNextID(int) (int, error) {
+	return 9999, nil
+}
+func (m *vmRestorerMock) SetVmConfig(vmRef *proxmoxapi.VmRef, params map[string]interface{}) (interface{}, error) {
+	m.changes = params
+	return nil, nil
+}
+func (m *vmRestorerMock) DeleteVm(vmRef *proxmoxapi.VmRef) (string, error) {
+	m.deleted = append(m.deleted, vmRef.VmId())
+	return "", nil
+}
+
+var _ vmRestorer = &vmRestorerMock{}
+
+func TestRestoreVM(t *testing.T) {
+	tests := []struct {
+		name             string
+		overrides        map[string]interface{}
+		backupConfig     string
+		restoreStatus    string
+		restoreErr       error
+		expectedPosts    map[string]map[string]interface{}
+		expectedChanges  map[string]interface{}
+		expectedDeleted  []int
+		expectedErrorMsg string
+	}{
+		{
+			name:          "restore with defaults",
+			restoreStatus: "OK",
+			expectedPosts: map[string]map[string]interface{}{
+				"/nodes/my-proxmox/qemu": {
+					"vmid":    100,
+					"archive": "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+					"unique":  1,
+				},
+			},
+			expectedChanges: map[string]interface{}{
+				"name":        "packer-build",
+				"description": "Packer ephemeral build VM",
+			},
+		},
+		{
+			name: "restore to storage and pool",
+			overrides: map[string]interface{}{
+				"restore_storage_pool": "local-zfs",
+				"restore_bwlimit":      10240,
+				"pool":                 "packer",
+				"tags":                 "golden;linux",
+			},
+			restoreStatus: "WARNINGS: 1",
+			expectedPosts: map[string]map[string]interface{}{
+				"/nodes/my-proxmox/qemu": {
+					"vmid":    100,
+					"archive": "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+					"unique":  1,
+					"storage": "local-zfs",
+					"bwlimit": 10240,
+					"pool":    "packer",
+				},
+			},
+			expectedChanges: map[string]interface{}{
+				"name":        "packer-build",
+				"description": "Packer ephemeral build VM",
+				"tags":        "golden;linux",
+			},
+		},
+		{
+			name: "template backups are restored under a temporary ID and cloned",
+			overrides: map[string]interface{}{
+				"restore_storage_pool": "local-zfs",
+				"pool":                 "packer",
+			},
+			backupConfig:  "boot: order=scsi0\ntemplate: 1\nscsi0: local-lvm:base-100-disk-0,size=8G\n",
+			restoreStatus: "OK",
+			expectedPosts: map[string]map[string]interface{}{
+				"/nodes/my-proxmox/qemu": {
+					"vmid":    9999,
+					"archive": "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+					"unique":  1,
+					"storage": "local-zfs",
+				},
+				"/nodes/my-proxmox/qemu/9999/clone": {
+					"newid":   100,
+					"name":    "packer-build",
+					"full":    1,
+					"storage": "local-zfs",
+					"pool":    "packer",
+				},
+			},
+			expectedChanges: map[string]interface{}{
+				"name":        "packer-build",
+				"description": "Packer ephemeral build VM",
+			},
+			expectedDeleted: []int{9999},
+		},
+		{
+			name:          "templates in snapshot sections are ignored",
+			backupConfig:  "boot: order=scsi0\nparent: base\n\n[base]\ntemplate: 1\n",
+			restoreStatus: "OK",
+			expectedPosts: map[string]map[string]interface{}{
+				"/nodes/my-proxmox/qemu": {
+					"vmid":    100,
+					"archive": "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+					"unique":  1,
+				},
+			},
+			expectedChanges: map[string]interface{}{
+				"name":        "packer-build",
+				"description": "Packer ephemeral build VM",
+			},
+		},
+		{
+			name:             "restore task fails",
+			restoreStatus:    "command 'vma extract' failed: exit code 133",
+			expectedErrorMsg: "error restoring backup",
+		},
+		{
+			name:             "duplicate ID is passed through",
+			restoreErr:       fmt.Errorf("500 VM 100 already exists on node 'pve'"),
+			expectedErrorMsg: "already exists on node",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			require.NoError(t, err)
+
+			client := &vmRestorerMock{
+				backupConfig: tt.backupConfig,
+				postErr:      tt.restoreErr,
+				exitStatus:   tt.restoreStatus,
+			}
+
+			vmRef := proxmoxapi.NewVmRef(100)
+			vmRef.SetNode("my-proxmox")
+			config := proxmoxapi.ConfigQemu{
+				Name:        "packer-build",
+				Description: "Packer ephemeral build VM",
+			}
+			err = restoreVM(context.TODO(), packersdk.TestUi(t), client, vmRef, config, &c)
+			if tt.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, tt.expectedErrorMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPosts, client.posted)
+			assert.Equal(t, tt.expectedChanges, client.changes)
+			assert.Equal(t, tt.expectedDeleted, client.deleted)
+		})
+	}
+}
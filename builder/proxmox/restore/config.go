@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package proxmoxrestore
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	common "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type Config struct {
+	common.Config `mapstructure:",squash"`
+
+	// Backup archive to restore, expressed as a proxmox datastore path of the
+	// `backup` content type, for example
+	// `local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst`.
+	// Either `backup_file` OR `backup_url` must be specifed.
+	BackupFile string `mapstructure:"backup_file"`
+	// URL or local path to a vzdump archive of a virtual machine to restore,
+	// such as `vzdump-qemu-100-2024_05_01-12_00_00.vma.zst`. Packer downloads
+	// the archive and copies it to `backup_storage_pool` over SSH, see
+	// `node_ssh_username`. The archive is removed again at the end of the build.
+	BackupURL string `mapstructure:"backup_url"`
+	// The checksum of the archive, in the same format as `iso_checksum`.
+	// Required when `backup_url` is set; `none` disables the check.
+	BackupChecksum string `mapstructure:"backup_checksum"`
+	// The path where the downloaded archive is stored locally before being
+	// uploaded. Defaults to the Packer cache directory.
+	BackupTargetPath string `mapstructure:"backup_target_path"`
+	// Proxmox storage pool onto which to upload the archive. Must be a
+	// directory based storage allowing the `backup` content type.
+	BackupStoragePool string `mapstructure:"backup_storage_pool"`
+
+	common.NodeSSHConfig `mapstructure:",squash"`
+
+	// Storage pool to restore the disks of the virtual machine to.
+	// Defaults to the storage pools recorded in the backup.
+	RestoreStoragePool string `mapstructure:"restore_storage_pool"`
+	// Limit the I/O bandwidth of the restore, in KiB/s.
+	// Defaults to the limit configured for the cluster.
+	RestoreBandwidthLimit int `mapstructure:"restore_bwlimit"`
+}
+
+// Archives of virtual machines, as created by vzdump
+var backupArchiveRe = regexp.MustCompile(`^vzdump-qemu-.+\.vma(\.(zst|gz|lzo))?$`)
+
+func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
+	var errs *packersdk.MultiError
+	_, warnings, merrs := c.Config.Prepare(c, raws...)
+	if merrs != nil {
+		errs = packersdk.MultiErrorAppend(errs, merrs)
+	}
+
+	// The hardware of the virtual machine is restored from the backup
+	if len(c.Disks) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("disks can not be set, the disks are restored from the backup"))
+	}
+	if len(c.NICs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("network_adapters can not be set, the network adapters are restored from the backup"))
+	}
+	if len(c.ISOs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("additional_iso_files is not supported when restoring a backup"))
+	}
+
+	if c.BackupFile != "" && c.BackupURL != "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("only one of backup_file or backup_url can be specified"))
+	}
+	if c.BackupFile == "" && c.BackupURL == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("one of backup_file or backup_url must be specified"))
+	}
+	if c.BackupFile != "" && !strings.Contains(c.BackupFile, ":backup/") {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("backup_file %q must be a proxmox datastore path, such as local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst", c.BackupFile))
+	}
+
+	if c.BackupURL != "" {
+		name := backupFileName(c.BackupURL)
+		if !backupArchiveRe.MatchString(name) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("backup_url must point to a vzdump archive of a virtual machine, named like vzdump-qemu-<vmid>-<date>.vma.zst, got %q", name))
+		}
+		if c.BackupStoragePool == "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("backup_storage_pool must be specified when using backup_url"))
+		}
+
+		switch c.BackupChecksum {
+		case "":
+			errs = packersdk.MultiErrorAppend(errs, errors.New("backup_checksum must be specified"))
+		case "none":
+			warnings = append(warnings, "A backup_checksum of 'none' was specified. A checksum is highly recommended.")
+		default:
+			// Resolves checksum files and validates the checksum type
+			isoConfig := commonsteps.ISOConfig{
+				ISOChecksum:     c.BackupChecksum,
+				ISOUrls:         []string{c.BackupURL},
+				TargetExtension: archiveExtension(name),
+			}
+			_, isoErrs := isoConfig.Prepare(&c.Ctx)
+			for _, err := range isoErrs {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("backup_checksum: %s", err))
+			}
+			c.BackupChecksum = isoConfig.ISOChecksum
+		}
+
+		errs = packersdk.MultiErrorAppend(errs, c.NodeSSHConfig.Prepare(c.ProxmoxURLRaw)...)
+	}
+
+	if c.RestoreBandwidthLimit < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("restore_bwlimit must not be negative"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+	return nil, warnings, nil
+}
+
+// backupFileName returns the file name of the archive at the given URL or
+// path, without any query string.
+func backupFileName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawURL)
+}
+
+// archiveExtension returns the extension of a vzdump archive, such as
+// `vma.zst`.
+func archiveExtension(name string) string {
+	if idx := strings.LastIndex(name, ".vma"); idx >= 0 {
+		return name[idx+1:]
+	}
+	return strings.TrimPrefix(path.Ext(name), ".")
+}
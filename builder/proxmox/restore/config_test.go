@@ -0,0 +1,192 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxrestore
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":         "https://my-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"token":               "xxxx-xxxx-xxxx-xxxx",
+		"node":                "my-proxmox",
+		"ssh_username":        "root",
+		"backup_file":         "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+		"packer_builder_type": "proxmox-restore",
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	var c Config
+	_, _, err := c.Prepare(&c, make(map[string]interface{}))
+	if err == nil {
+		t.Fatal("Expected empty configuration to fail")
+	}
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatal("Expected errors to be packersdk.MultiError")
+	}
+
+	required := []string{"username", "token", "proxmox_url", "node", "ssh_username", "backup_file"}
+	for _, param := range required {
+		found := false
+		for _, err := range errs.Errors {
+			if strings.Contains(err.Error(), param) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error about missing parameters %q", param)
+		}
+	}
+}
+
+func TestBackupSource(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name:          "backup_file on storage, no error",
+			overrides:     map[string]interface{}{},
+			expectFailure: false,
+		},
+		{
+			name: "backup_file on proxmox backup server, no error",
+			overrides: map[string]interface{}{
+				"backup_file": "pbs:backup/vm/100/2024-05-01T12:00:00Z",
+			},
+			expectFailure: false,
+		},
+		{
+			name: "backup_file without backup content, fail",
+			overrides: map[string]interface{}{
+				"backup_file": "local:iso/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "backup_url with password, no error",
+			overrides: map[string]interface{}{
+				"backup_file":         "",
+				"backup_url":          "https://backups.my-domain/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+				"backup_checksum":     "none",
+				"backup_storage_pool": "local",
+				"node_ssh_password":   "secret",
+			},
+			expectFailure: false,
+		},
+		{
+			name: "backup_url and backup_file, fail",
+			overrides: map[string]interface{}{
+				"backup_url":          "https://backups.my-domain/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+				"backup_checksum":     "none",
+				"backup_storage_pool": "local",
+				"node_ssh_password":   "secret",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "backup_url of a container backup, fail",
+			overrides: map[string]interface{}{
+				"backup_file":         "",
+				"backup_url":          "https://backups.my-domain/vzdump-lxc-100-2024_05_01-12_00_00.tar.zst",
+				"backup_checksum":     "none",
+				"backup_storage_pool": "local",
+				"node_ssh_password":   "secret",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "backup_url without node credentials, fail",
+			overrides: map[string]interface{}{
+				"backup_file":         "",
+				"backup_url":          "https://backups.my-domain/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+				"backup_checksum":     "none",
+				"backup_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "backup_url without backup_storage_pool, fail",
+			overrides: map[string]interface{}{
+				"backup_file":       "",
+				"backup_url":        "https://backups.my-domain/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+				"backup_checksum":   "none",
+				"node_ssh_password": "secret",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "disks given, fail",
+			overrides: map[string]interface{}{
+				"disks": []map[string]interface{}{
+					{"storage_pool": "local-lvm", "disk_size": "8G"},
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "network_adapters given, fail",
+			overrides: map[string]interface{}{
+				"network_adapters": []map[string]interface{}{
+					{"bridge": "vmbr0"},
+				},
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Errorf("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
+
+func TestNodeSSHDefaults(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["backup_file"] = ""
+	cfg["backup_url"] = "/backups/vzdump-qemu-100-2024_05_01-12_00_00.vma.gz"
+	cfg["backup_checksum"] = "none"
+	cfg["backup_storage_pool"] = "local"
+	cfg["node_ssh_password"] = "secret"
+
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeSSHHost != "my-proxmox.my-domain" {
+		t.Errorf("Expected node_ssh_host to default to the proxmox_url host, got %q", c.NodeSSHHost)
+	}
+	if c.NodeSSHPort != 22 {
+		t.Errorf("Expected node_ssh_port to default to 22, got %d", c.NodeSSHPort)
+	}
+	if c.NodeSSHUsername != "root" {
+		t.Errorf("Expected node_ssh_username to default to root, got %q", c.NodeSSHUsername)
+	}
+	if ext := archiveExtension(backupFileName(c.BackupURL)); ext != "vma.gz" {
+		t.Errorf("Expected archive extension vma.gz, got %q", ext)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxrestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	common "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepUploadBackup copies a downloaded backup archive to the dump directory
+// of the backup storage, and points backup_file at it.
+//
+// The Proxmox API does not accept uploads of backup archives, so the archive
+// is copied to the node over SSH.
+type stepUploadBackup struct {
+	// connect opens an SSH connection to the Proxmox node
+	connect func(c *common.NodeSSHConfig) (packersdk.Communicator, error)
+	// Volume of the uploaded archive, removed during cleanup
+	uploaded string
+}
+
+type backupUploader interface {
+	GetStorageConfig(id string) (config map[string]interface{}, err error)
+	DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+}
+
+var _ backupUploader = &common.Client{}
+
+func (s *stepUploadBackup) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(backupUploader)
+	c := state.Get("restore-config").(*Config)
+
+	p := state.Get(downloadPathKey).(string)
+	if p == "" {
+		err := fmt.Errorf("path to downloaded backup archive was empty")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	storage, err := client.GetStorageConfig(c.BackupStoragePool)
+	if err != nil {
+		err := fmt.Errorf("error fetching configuration of storage %s: %s", c.BackupStoragePool, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	dumpDir, err := common.BackupDir(c.BackupStoragePool, storage)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	name := backupFileName(c.BackupURL)
+	volume := fmt.Sprintf("%s:backup/%s", c.BackupStoragePool, name)
+	dst := path.Join(dumpDir, name)
+
+	comm, err := s.connect(&c.NodeSSHConfig)
+	if err != nil {
+		err := fmt.Errorf("error connecting to %s over SSH: %s", c.NodeSSHHost, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	archivePath, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	r, err := os.Open(archivePath)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer r.Close()
+	fi, err := r.Stat()
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Never overwrite, nor later remove, an archive that is already there.
+	// It is only restored when it is the downloaded archive.
+	existing, err := remoteChecksum(ctx, comm, dst)
+	if err != nil {
+		err := fmt.Errorf("error looking for an existing archive %s: %s", volume, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if existing != "" {
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if existing != hex.EncodeToString(h.Sum(nil)) {
+			err := fmt.Errorf("backup archive %s already exists and differs from the one downloaded from backup_url, remove it to upload the archive", volume)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		ui.Say(fmt.Sprintf("Backup archive %s already present, skipping upload", volume))
+		c.BackupFile = volume
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf("Uploading backup archive %s to %s", name, c.BackupStoragePool))
+	err = comm.Upload(dst, r, &fi)
+	if err != nil {
+		err := fmt.Errorf("error uploading backup archive: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.uploaded = volume
+
+	c.BackupFile = volume
+	ui.Message(fmt.Sprintf("Uploaded backup archive to %s", c.BackupFile))
+
+	return multistep.ActionContinue
+}
+
+// remoteChecksum returns the SHA-256 checksum of the file at path on the
+// node, or an empty string when there is no such file.
+func remoteChecksum(ctx context.Context, comm packersdk.Communicator, path string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	quoted := common.ShellQuote(path)
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("test ! -e %s || sha256sum %s", quoted, quoted),
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return "", err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return "", fmt.Errorf("exit status %d: %s", status, strings.TrimSpace(stderr.String()))
+	}
+	// <checksum>  <path>
+	checksum, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), " ")
+	return strings.ToLower(checksum), nil
+}
+
+func (s *stepUploadBackup) Cleanup(state multistep.StateBag) {
+	if s.uploaded == "" {
+		return
+	}
+	c := state.Get("restore-config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(backupUploader)
+
+	// Fake a VM reference, DeleteVolume just needs the node to be valid
+	vmRef := &proxmoxapi.VmRef{}
+	vmRef.SetNode(c.Node)
+	vmRef.SetVmType("qemu")
+
+	_, err := client.DeleteVolume(vmRef, c.BackupStoragePool, s.uploaded)
+	if err != nil {
+		ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+		return
+	}
+	ui.Message(fmt.Sprintf("Deleted uploaded backup archive %s", s.uploaded))
+	s.uploaded = ""
+}
@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxrestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	common "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type backupUploaderMock struct {
+	storage       map[string]interface{}
+	deletedVolume string
+}
+
+func (m *backupUploaderMock) GetStorageConfig(id string) (map[string]interface{}, error) {
+	return m.storage, nil
+}
+
+func (m *backupUploaderMock) DeleteVolume(_ *proxmoxapi.VmRef, _ string, volumeName string) (interface{}, error) {
+	m.deletedVolume = volumeName
+	return nil, nil
+}
+
+var _ backupUploader = &backupUploaderMock{}
+
+func TestUploadBackup(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "archive.vma.zst")
+	if err := os.WriteFile(archive, []byte("vma"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archiveChecksum := fmt.Sprintf("%x", sha256.Sum256([]byte("vma")))
+
+	tests := []struct {
+		name               string
+		storage            map[string]interface{}
+		remoteStdout       string
+		remoteExitStatus   int
+		expectedAction     multistep.StepAction
+		expectUpload       bool
+		expectedUploadPath string
+		expectedDeleted    string
+	}{
+		{
+			name:               "upload to directory storage",
+			storage:            map[string]interface{}{"type": "dir", "path": "/var/lib/vz", "content": "iso,backup,vztmpl"},
+			expectedAction:     multistep.ActionContinue,
+			expectUpload:       true,
+			expectedUploadPath: "/var/lib/vz/dump/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+			expectedDeleted:    "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+		},
+		{
+			name:           "same archive already present, skip upload",
+			storage:        map[string]interface{}{"type": "dir", "path": "/var/lib/vz", "content": "backup"},
+			remoteStdout:   archiveChecksum + "  /var/lib/vz/dump/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst\n",
+			expectedAction: multistep.ActionContinue,
+			expectUpload:   false,
+		},
+		{
+			name:           "other archive already present, fail",
+			storage:        map[string]interface{}{"type": "dir", "path": "/var/lib/vz", "content": "backup"},
+			remoteStdout:   strings.Repeat("0", 64) + "  /var/lib/vz/dump/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst\n",
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name:             "existing archive can not be read, fail",
+			storage:          map[string]interface{}{"type": "dir", "path": "/var/lib/vz", "content": "backup"},
+			remoteExitStatus: 1,
+			expectedAction:   multistep.ActionHalt,
+		},
+		{
+			name:           "storage without backup content, fail",
+			storage:        map[string]interface{}{"type": "dir", "path": "/var/lib/vz", "content": "iso"},
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name:           "storage without path, fail",
+			storage:        map[string]interface{}{"type": "pbs", "content": "backup"},
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				BackupURL:         "https://backups.my-domain/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+				BackupStoragePool: "local",
+			}
+			client := &backupUploaderMock{storage: tt.storage}
+			comm := &packersdk.MockCommunicator{StartStdout: tt.remoteStdout, StartExitStatus: tt.remoteExitStatus}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("restore-config", c)
+			state.Put("proxmoxClient", client)
+			state.Put(downloadPathKey, archive)
+
+			step := &stepUploadBackup{
+				connect: func(*common.NodeSSHConfig) (packersdk.Communicator, error) { return comm, nil },
+			}
+			action := step.Run(context.TODO(), state)
+			if action != tt.expectedAction {
+				t.Fatalf("Expected action %v, got %v", tt.expectedAction, action)
+			}
+			if action == multistep.ActionHalt {
+				return
+			}
+
+			if c.BackupFile != "local:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst" {
+				t.Errorf("Expected backup_file to point at the archive on the storage, got %q", c.BackupFile)
+			}
+			if comm.UploadCalled != tt.expectUpload {
+				t.Errorf("Expected upload to be called: %t, got %t", tt.expectUpload, comm.UploadCalled)
+			}
+			if tt.expectUpload && comm.UploadPath != tt.expectedUploadPath {
+				t.Errorf("Expected upload to %s, got %s", tt.expectedUploadPath, comm.UploadPath)
+			}
+
+			step.Cleanup(state)
+			if client.deletedVolume != tt.expectedDeleted {
+				t.Errorf("Expected %q to be deleted, got %q", tt.expectedDeleted, client.deletedVolume)
+			}
+		})
+	}
+}
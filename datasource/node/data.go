@@ -0,0 +1,263 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Only online nodes that are not in HA maintenance mode are considered, the
+// filters below narrow them down further. The node with the most free memory,
+// then the most free CPU, is returned.
+type Config struct {
+	common.PackerConfig  `mapstructure:",squash"`
+	proxmox.ClientConfig `mapstructure:",squash"`
+
+	// Names of the nodes to choose from. Defaults to every node of the
+	// cluster.
+	Nodes []string `mapstructure:"nodes"`
+	// Storage pool that must be enabled and available on the node, such as
+	// the one the VM disks are created on.
+	StoragePool string `mapstructure:"storage_pool"`
+	// Network bridge or SDN VNet that must exist on the node, such as `vmbr0`.
+	Bridge string `mapstructure:"bridge"`
+	// Minimum free memory of the node, in megabytes.
+	MinFreeMemory int `mapstructure:"min_free_memory"`
+	// Minimum number of idle CPUs of the node, computed from its current CPU
+	// usage. For example `2.5`.
+	MinFreeCPU float64 `mapstructure:"min_free_cpu"`
+}
+
+type DatasourceOutput struct {
+	// The name of the best node, to be used as `node`.
+	Node string `mapstructure:"node"`
+	// The free memory of the node, in megabytes.
+	FreeMemory int `mapstructure:"free_memory"`
+	// The number of idle CPUs of the node.
+	FreeCPU float64 `mapstructure:"free_cpu"`
+	// Every node matching the filters, best first.
+	Nodes []string `mapstructure:"nodes"`
+}
+
+type Datasource struct {
+	config Config
+}
+
+// Datasource implements packersdk.Datasource
+var _ packersdk.Datasource = &Datasource{}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	errs = packersdk.MultiErrorAppend(errs, d.config.ClientConfig.Prepare()...)
+
+	if d.config.MinFreeMemory < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("min_free_memory must not be negative"))
+	}
+	if d.config.MinFreeCPU < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("min_free_cpu must not be negative"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	client, err := d.config.NewClient(d.config.PackerDebug)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	output, err := selectNode(client, &d.config)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+type nodeLister interface {
+	GetResourceList(resourceType string) (list []interface{}, err error)
+	GetItemListInterfaceArray(url string) ([]interface{}, error)
+}
+
+var _ nodeLister = &proxmox.Client{}
+
+// selectNode returns the node matching the filters with the most free
+// resources. The reason every other node was rejected is logged, and
+// reported when no node matches.
+func selectNode(client nodeLister, c *Config) (*DatasourceOutput, error) {
+	nodes, err := client.GetResourceList("node")
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes: %s", err)
+	}
+	maintenance := maintenanceNodes(client)
+
+	var storages map[string]bool
+	if c.StoragePool != "" {
+		storages, err = availableStorage(client, c.StoragePool)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// candidate is a node matching the filters
+	type candidate struct {
+		name       string
+		freeMemory int
+		freeCPU    float64
+	}
+	var candidates []candidate
+	var rejected []string
+	reject := func(name, reason string) {
+		log.Printf("node %s rejected: %s", name, reason)
+		rejected = append(rejected, fmt.Sprintf("%s: %s", name, reason))
+	}
+	for _, r := range nodes {
+		node, _ := r.(map[string]interface{})
+		name, _ := node["node"].(string)
+		if len(c.Nodes) > 0 && !slices.Contains(c.Nodes, name) {
+			continue
+		}
+		if status, _ := node["status"].(string); status != "online" {
+			reject(name, "not online")
+			continue
+		}
+		if maintenance[name] {
+			reject(name, "in maintenance mode")
+			continue
+		}
+
+		maxmem, _ := node["maxmem"].(float64)
+		mem, _ := node["mem"].(float64)
+		maxcpu, _ := node["maxcpu"].(float64)
+		cpu, _ := node["cpu"].(float64)
+		match := candidate{
+			name:       name,
+			freeMemory: int((maxmem - mem) / (1024 * 1024)),
+			freeCPU:    maxcpu * (1 - cpu),
+		}
+		if match.freeMemory < c.MinFreeMemory {
+			reject(name, fmt.Sprintf("%d MB of free memory", match.freeMemory))
+			continue
+		}
+		if match.freeCPU < c.MinFreeCPU {
+			reject(name, fmt.Sprintf("%.2f idle CPUs", match.freeCPU))
+			continue
+		}
+		if c.StoragePool != "" && !storages[name] {
+			reject(name, fmt.Sprintf("storage pool %s not available", c.StoragePool))
+			continue
+		}
+		if c.Bridge != "" {
+			ok, err := proxmox.HasBridge(client, name, c.Bridge)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				reject(name, fmt.Sprintf("bridge %s not found", c.Bridge))
+				continue
+			}
+		}
+		candidates = append(candidates, match)
+	}
+
+	if len(candidates) == 0 {
+		if len(rejected) == 0 {
+			return nil, errors.New("no node matches the filters")
+		}
+		return nil, fmt.Errorf("no node matches the filters: %s", strings.Join(rejected, ", "))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.freeMemory != b.freeMemory {
+			return a.freeMemory > b.freeMemory
+		}
+		if a.freeCPU != b.freeCPU {
+			return a.freeCPU > b.freeCPU
+		}
+		return a.name < b.name
+	})
+
+	output := &DatasourceOutput{
+		Node:       candidates[0].name,
+		FreeMemory: candidates[0].freeMemory,
+		FreeCPU:    candidates[0].freeCPU,
+	}
+	for _, match := range candidates {
+		output.Nodes = append(output.Nodes, match.name)
+	}
+	log.Printf("%d nodes match, using %s", len(candidates), output.Node)
+	return output, nil
+}
+
+// availableStorage returns the nodes the storage pool is available on.
+func availableStorage(client nodeLister, storagePool string) (map[string]bool, error) {
+	storages, err := client.GetResourceList("storage")
+	if err != nil {
+		return nil, fmt.Errorf("error listing storage pools: %s", err)
+	}
+	nodes := map[string]bool{}
+	for _, r := range storages {
+		storage, _ := r.(map[string]interface{})
+		if storage["storage"] != storagePool || storage["status"] != "available" {
+			continue
+		}
+		node, _ := storage["node"].(string)
+		nodes[node] = true
+	}
+	return nodes, nil
+}
+
+// maintenanceNodes returns the nodes whose HA resource manager is in
+// maintenance mode. Clusters without HA have none.
+func maintenanceNodes(client nodeLister) map[string]bool {
+	nodes := map[string]bool{}
+	status, err := client.GetItemListInterfaceArray("/cluster/ha/status/current")
+	if err != nil {
+		log.Printf("error fetching HA status, ignoring maintenance mode: %s", err)
+		return nodes
+	}
+	for _, r := range status {
+		item, _ := r.(map[string]interface{})
+		if item["type"] != "lrm" {
+			continue
+		}
+		mode, _ := item["mode"].(string)
+		if mode == "maintenance" {
+			node, _ := item["node"].(string)
+			nodes[node] = true
+		}
+	}
+	return nodes
+}
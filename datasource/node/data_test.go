@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package node
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const gib = 1024 * 1024 * 1024
+
+type nodeListerMock struct {
+	nodes       []interface{}
+	storages    []interface{}
+	bridges     map[string][]interface{}
+	vnets       []interface{}
+	haStatus    []interface{}
+	haStatusErr error
+}
+
+func (m *nodeListerMock) GetResourceList(resourceType string) ([]interface{}, error) {
+	switch resourceType {
+	case "node":
+		return m.nodes, nil
+	case "storage":
+		return m.storages, nil
+	}
+	return nil, errors.New("unexpected resource type " + resourceType)
+}
+
+func (m *nodeListerMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	if url == "/cluster/ha/status/current" {
+		return m.haStatus, m.haStatusErr
+	}
+	if url == "/cluster/sdn/vnets" && m.vnets != nil {
+		return m.vnets, nil
+	}
+	for node, bridges := range m.bridges {
+		if url == "/nodes/"+node+"/network?type=any_bridge" {
+			return bridges, nil
+		}
+	}
+	return nil, errors.New("unexpected url " + url)
+}
+
+func node(name, status string, maxmem, mem, maxcpu, cpu float64) map[string]interface{} {
+	return map[string]interface{}{
+		"node":   name,
+		"status": status,
+		"maxmem": maxmem,
+		"mem":    mem,
+		"maxcpu": maxcpu,
+		"cpu":    cpu,
+	}
+}
+
+func newMock() *nodeListerMock {
+	return &nodeListerMock{
+		nodes: []interface{}{
+			node("pve1", "online", 64*gib, 48*gib, 16, 0.5),
+			node("pve2", "online", 64*gib, 32*gib, 8, 0.75),
+			node("pve3", "offline", 0, 0, 0, 0),
+			node("pve4", "online", 128*gib, 16*gib, 32, 0.1),
+		},
+		storages: []interface{}{
+			map[string]interface{}{"storage": "local-zfs", "node": "pve1", "status": "available"},
+			map[string]interface{}{"storage": "local-zfs", "node": "pve2", "status": "available"},
+			map[string]interface{}{"storage": "local-zfs", "node": "pve4", "status": "unknown"},
+			map[string]interface{}{"storage": "local", "node": "pve4", "status": "available"},
+		},
+		bridges: map[string][]interface{}{
+			"pve1": {map[string]interface{}{"iface": "vmbr0"}, map[string]interface{}{"iface": "vmbr1"}},
+			"pve2": {map[string]interface{}{"iface": "vmbr0"}},
+			"pve4": {map[string]interface{}{"iface": "vmbr0"}},
+		},
+		haStatus: []interface{}{
+			map[string]interface{}{"type": "quorum", "status": "OK"},
+			map[string]interface{}{"type": "lrm", "node": "pve1", "mode": "active"},
+			map[string]interface{}{"type": "lrm", "node": "pve4", "mode": "active"},
+		},
+	}
+}
+
+func TestSelectNode(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		mock          func(m *nodeListerMock)
+		expectedNode  string
+		expectedNodes []string
+		expectedError string
+	}{
+		{
+			name:          "most free memory",
+			config:        Config{},
+			expectedNode:  "pve4",
+			expectedNodes: []string{"pve4", "pve2", "pve1"},
+		},
+		{
+			name:          "node list",
+			config:        Config{Nodes: []string{"pve1", "pve3"}},
+			expectedNode:  "pve1",
+			expectedNodes: []string{"pve1"},
+		},
+		{
+			name:          "storage pool",
+			config:        Config{StoragePool: "local-zfs"},
+			expectedNode:  "pve2",
+			expectedNodes: []string{"pve2", "pve1"},
+		},
+		{
+			name:          "bridge",
+			config:        Config{Bridge: "vmbr1"},
+			expectedNode:  "pve1",
+			expectedNodes: []string{"pve1"},
+		},
+		{
+			name:   "SDN VNet bridge",
+			config: Config{Bridge: "vnet10"},
+			mock: func(m *nodeListerMock) {
+				m.vnets = []interface{}{map[string]interface{}{"vnet": "vnet10", "zone": "simple"}}
+			},
+			expectedNode:  "pve4",
+			expectedNodes: []string{"pve4", "pve2", "pve1"},
+		},
+		{
+			name:          "minimum free CPU",
+			config:        Config{MinFreeCPU: 4},
+			expectedNode:  "pve4",
+			expectedNodes: []string{"pve4", "pve1"},
+		},
+		{
+			name:   "maintenance mode",
+			config: Config{},
+			mock: func(m *nodeListerMock) {
+				m.haStatus[2] = map[string]interface{}{"type": "lrm", "node": "pve4", "mode": "maintenance"}
+			},
+			expectedNode:  "pve2",
+			expectedNodes: []string{"pve2", "pve1"},
+		},
+		{
+			name:   "HA status unavailable",
+			config: Config{},
+			mock: func(m *nodeListerMock) {
+				m.haStatusErr = errors.New("permission denied")
+			},
+			expectedNode:  "pve4",
+			expectedNodes: []string{"pve4", "pve2", "pve1"},
+		},
+		{
+			name:          "no match reports the reasons",
+			config:        Config{Nodes: []string{"pve3", "pve4"}, MinFreeMemory: 200 * 1024},
+			expectedError: "pve3: not online, pve4: 114688 MB of free memory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMock()
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			output, err := selectNode(m, &tt.config)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected a node, got %s", err)
+			}
+			if output.Node != tt.expectedNode {
+				t.Errorf("Expected node %s, got %s", tt.expectedNode, output.Node)
+			}
+			if strings.Join(output.Nodes, ",") != strings.Join(tt.expectedNodes, ",") {
+				t.Errorf("Expected nodes %v, got %v", tt.expectedNodes, output.Nodes)
+			}
+		})
+	}
+}
+
+func TestSelectNodeOutput(t *testing.T) {
+	output, err := selectNode(newMock(), &Config{})
+	if err != nil {
+		t.Fatalf("Expected a node, got %s", err)
+	}
+	if output.FreeMemory != 112*1024 {
+		t.Errorf("Expected 114688 MB of free memory, got %d", output.FreeMemory)
+	}
+	if output.FreeCPU < 28.79 || output.FreeCPU > 28.81 {
+		t.Errorf("Expected 28.8 idle CPUs, got %f", output.FreeCPU)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package template
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// The filters below select the templates to consider, all of them must
+// match. The newest matching template is returned.
+type Config struct {
+	common.PackerConfig  `mapstructure:",squash"`
+	proxmox.ClientConfig `mapstructure:",squash"`
+
+	// Regular expression the name of the template must match, for example
+	// `^debian-12-`.
+	NameRegex string `mapstructure:"name_regex"`
+	// Tags the template must all have.
+	Tags []string `mapstructure:"tags"`
+	// Node the template must be on.
+	Node string `mapstructure:"node"`
+	// Resource pool the template must be in.
+	Pool string `mapstructure:"pool"`
+	// Prefix of the tag holding the version of the template, such as
+	// `version-` for a `version-1.2.3` tag. When set, only templates with
+	// such a tag are considered, and the one with the highest version is
+	// returned. Otherwise, the most recently created template is returned.
+	VersionTagPrefix string `mapstructure:"version_tag_prefix"`
+
+	nameRe *regexp.Regexp
+}
+
+type DatasourceOutput struct {
+	// The ID of the template, to be used as `clone_vm_id`.
+	VMID int `mapstructure:"vm_id"`
+	// The name of the template.
+	Name string `mapstructure:"name"`
+	// The node the template is on.
+	Node string `mapstructure:"node"`
+	// The resource pool of the template, if any.
+	Pool string `mapstructure:"pool"`
+	// The tags of the template.
+	Tags []string `mapstructure:"tags"`
+	// The version of the template, read from its version tag when
+	// `version_tag_prefix` is set.
+	Version string `mapstructure:"version"`
+	// The creation time of the template, in RFC3339 format, if Proxmox
+	// recorded it.
+	CreationTime string `mapstructure:"creation_time"`
+	// The configuration of the template, as returned by the Proxmox API.
+	Config map[string]string `mapstructure:"config"`
+}
+
+type Datasource struct {
+	config Config
+}
+
+// Datasource implements packersdk.Datasource
+var _ packersdk.Datasource = &Datasource{}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	errs = packersdk.MultiErrorAppend(errs, d.config.ClientConfig.Prepare()...)
+
+	if d.config.NameRegex != "" {
+		d.config.nameRe, err = regexp.Compile(d.config.NameRegex)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("name_regex is invalid: %s", err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	client, err := d.config.NewClient(d.config.PackerDebug)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	output, err := findTemplate(client, &d.config)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+type templateLister interface {
+	GetResourceList(resourceType string) (list []interface{}, err error)
+	GetVmConfig(vmr *proxmoxapi.VmRef) (vmConfig map[string]interface{}, err error)
+}
+
+var _ templateLister = &proxmox.Client{}
+
+// findTemplate returns the newest template matching the filters.
+func findTemplate(client templateLister, c *Config) (*DatasourceOutput, error) {
+	resources, err := client.GetResourceList("vm")
+	if err != nil {
+		return nil, fmt.Errorf("error listing virtual machines: %s", err)
+	}
+
+	// candidate is a template matching the filters
+	type candidate struct {
+		output  DatasourceOutput
+		ctime   int64
+		version *version.Version
+	}
+	var candidates []candidate
+	for _, r := range resources {
+		vm, _ := r.(map[string]interface{})
+		if isTemplate, _ := vm["template"].(float64); isTemplate != 1 {
+			continue
+		}
+		vmid, _ := vm["vmid"].(float64)
+		name, _ := vm["name"].(string)
+		node, _ := vm["node"].(string)
+		pool, _ := vm["pool"].(string)
+		tagList, _ := vm["tags"].(string)
+		tags := splitTags(tagList)
+
+		if c.nameRe != nil && !c.nameRe.MatchString(name) {
+			continue
+		}
+		if c.Node != "" && node != c.Node {
+			continue
+		}
+		if c.Pool != "" && pool != c.Pool {
+			continue
+		}
+		if !hasTags(tags, c.Tags) {
+			continue
+		}
+
+		match := candidate{
+			output: DatasourceOutput{
+				VMID: int(vmid),
+				Name: name,
+				Node: node,
+				Pool: pool,
+				Tags: tags,
+			},
+		}
+		if c.VersionTagPrefix != "" {
+			for _, tag := range tags {
+				v, ok := strings.CutPrefix(tag, c.VersionTagPrefix)
+				if !ok {
+					continue
+				}
+				if match.version, err = version.NewVersion(v); err == nil {
+					match.output.Version = v
+					break
+				}
+				log.Printf("ignoring version tag %q of template %d: %s", tag, match.output.VMID, err)
+			}
+			if match.version == nil {
+				continue
+			}
+		}
+		candidates = append(candidates, match)
+	}
+
+	// Only the configuration of the templates that match is fetched
+	for i := range candidates {
+		vmRef := proxmoxapi.NewVmRef(candidates[i].output.VMID)
+		vmRef.SetNode(candidates[i].output.Node)
+		vmType, _ := resourceType(resources, candidates[i].output.VMID)
+		vmRef.SetVmType(vmType)
+		vmConfig, err := client.GetVmConfig(vmRef)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching configuration of template %d: %s", candidates[i].output.VMID, err)
+		}
+		candidates[i].output.Config = map[string]string{}
+		for k, v := range vmConfig {
+			candidates[i].output.Config[k] = fmt.Sprint(v)
+		}
+		if meta, ok := vmConfig["meta"].(string); ok {
+			candidates[i].ctime = creationTime(meta)
+		}
+		if candidates[i].ctime > 0 {
+			candidates[i].output.CreationTime = time.Unix(candidates[i].ctime, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, errors.New("no template matches the filters")
+	}
+
+	// Newest first, by version or creation time, then by VMID
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.version != nil && b.version != nil && !a.version.Equal(b.version) {
+			return a.version.GreaterThan(b.version)
+		}
+		if a.ctime != b.ctime {
+			return a.ctime > b.ctime
+		}
+		return a.output.VMID > b.output.VMID
+	})
+	log.Printf("%d templates match, using %d (%s)", len(candidates), candidates[0].output.VMID, candidates[0].output.Name)
+	return &candidates[0].output, nil
+}
+
+// resourceType returns the type, qemu or lxc, of the guest with the given
+// VMID in a cluster resource list.
+func resourceType(resources []interface{}, vmid int) (string, bool) {
+	for _, r := range resources {
+		vm, _ := r.(map[string]interface{})
+		if id, _ := vm["vmid"].(float64); int(id) == vmid {
+			t, ok := vm["type"].(string)
+			return t, ok
+		}
+	}
+	return "", false
+}
+
+// splitTags splits a Proxmox tag list, which may be separated by semicolons,
+// commas or spaces.
+func splitTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
+
+// hasTags returns whether all the wanted tags are in tags.
+func hasTags(tags []string, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// creationTime returns the ctime recorded in the meta property of a VM
+// configuration, such as `creation-qemu=8.1.2,ctime=1714564800`, or 0.
+func creationTime(meta string) int64 {
+	for _, kv := range strings.Split(meta, ",") {
+		if v, ok := strings.CutPrefix(kv, "ctime="); ok {
+			ctime, err := strconv.ParseInt(v, 10, 64)
+			if err == nil {
+				return ctime
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package template
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+)
+
+type templateListerMock struct {
+	resources []interface{}
+	configs   map[int]map[string]interface{}
+}
+
+func (m *templateListerMock) GetResourceList(resourceType string) ([]interface{}, error) {
+	return m.resources, nil
+}
+
+func (m *templateListerMock) GetVmConfig(vmr *proxmoxapi.VmRef) (map[string]interface{}, error) {
+	config, ok := m.configs[vmr.VmId()]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return config, nil
+}
+
+func vm(vmid int, name, node, pool, tags string, template bool) map[string]interface{} {
+	r := map[string]interface{}{
+		"vmid": float64(vmid),
+		"name": name,
+		"node": node,
+		"type": "qemu",
+		"tags": tags,
+	}
+	if pool != "" {
+		r["pool"] = pool
+	}
+	if template {
+		r["template"] = float64(1)
+	}
+	return r
+}
+
+func newMock() *templateListerMock {
+	return &templateListerMock{
+		resources: []interface{}{
+			vm(100, "debian-12-20240101", "pve1", "templates", "base;version-1.2.0", true),
+			vm(101, "debian-12-20240201", "pve2", "templates", "base;version-1.10.0", true),
+			vm(102, "debian-12-20240301", "pve1", "", "version-1.9.0", true),
+			vm(103, "debian-12-build", "pve1", "", "base", false),
+			vm(104, "ubuntu-24.04", "pve1", "templates", "base;version-2.0.0", true),
+		},
+		configs: map[int]map[string]interface{}{
+			100: {"name": "debian-12-20240101", "meta": "creation-qemu=8.1.2,ctime=1704067200", "cores": float64(2)},
+			101: {"name": "debian-12-20240201", "meta": "creation-qemu=8.1.2,ctime=1706745600"},
+			102: {"name": "debian-12-20240301", "meta": "creation-qemu=8.1.2,ctime=1709251200"},
+			103: {"name": "debian-12-build"},
+			104: {"name": "ubuntu-24.04", "meta": "creation-qemu=8.1.2,ctime=1717200000"},
+		},
+	}
+}
+
+func TestFindTemplate(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		expectedVMID  int
+		expectedError bool
+	}{
+		{
+			name:         "newest template without filters",
+			config:       Config{},
+			expectedVMID: 104,
+		},
+		{
+			name:         "name regex",
+			config:       Config{NameRegex: "^debian-12-"},
+			expectedVMID: 102,
+		},
+		{
+			name:         "tags",
+			config:       Config{NameRegex: "^debian-12-", Tags: []string{"base"}},
+			expectedVMID: 101,
+		},
+		{
+			name:         "node and pool",
+			config:       Config{NameRegex: "^debian-12-", Node: "pve1", Pool: "templates"},
+			expectedVMID: 100,
+		},
+		{
+			name:         "version tag ordered numerically",
+			config:       Config{NameRegex: "^debian-12-", VersionTagPrefix: "version-"},
+			expectedVMID: 101,
+		},
+		{
+			name:          "no match",
+			config:        Config{NameRegex: "^rocky-"},
+			expectedError: true,
+		},
+		{
+			name:          "build VMs are not templates",
+			config:        Config{NameRegex: "-build$"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			if c.NameRegex != "" {
+				c.nameRe = regexp.MustCompile(c.NameRegex)
+			}
+			output, err := findTemplate(newMock(), &c)
+			if tt.expectedError {
+				if err == nil {
+					t.Fatalf("Expected an error, got template %d", output.VMID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected a template, got %s", err)
+			}
+			if output.VMID != tt.expectedVMID {
+				t.Errorf("Expected template %d, got %d", tt.expectedVMID, output.VMID)
+			}
+		})
+	}
+}
+
+func TestFindTemplateOutput(t *testing.T) {
+	c := &Config{Node: "pve1", Tags: []string{"base"}, VersionTagPrefix: "version-", NameRegex: "^debian-"}
+	c.nameRe = regexp.MustCompile(c.NameRegex)
+
+	output, err := findTemplate(newMock(), c)
+	if err != nil {
+		t.Fatalf("Expected a template, got %s", err)
+	}
+	if output.VMID != 100 || output.Name != "debian-12-20240101" || output.Node != "pve1" || output.Pool != "templates" {
+		t.Errorf("Unexpected template %+v", output)
+	}
+	if output.Version != "1.2.0" {
+		t.Errorf("Expected version 1.2.0, got %q", output.Version)
+	}
+	if output.CreationTime != "2024-01-01T00:00:00Z" {
+		t.Errorf("Expected creation time 2024-01-01T00:00:00Z, got %q", output.CreationTime)
+	}
+	if output.Config["cores"] != "2" {
+		t.Errorf("Expected the template configuration, got %v", output.Config)
+	}
+	if len(output.Tags) != 2 || output.Tags[0] != "base" || output.Tags[1] != "version-1.2.0" {
+		t.Errorf("Unexpected tags %v", output.Tags)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	d := &Datasource{}
+	err := d.Configure(map[string]interface{}{
+		"proxmox_url": "https://my-proxmox.my-domain:8006/api2/json",
+		"username":    "apiuser@pve",
+		"password":    "supersecret",
+		"name_regex":  "debian-(",
+	})
+	if err == nil {
+		t.Fatal("Expected an invalid name_regex to be rejected")
+	}
+
+	err = d.Configure(map[string]interface{}{
+		"proxmox_url": "https://my-proxmox.my-domain:8006/api2/json",
+		"username":    "apiuser@pve",
+		"password":    "supersecret",
+		"name_regex":  "^debian-12-",
+	})
+	if err != nil {
+		t.Fatalf("Expected a valid configuration, got %s", err)
+	}
+	if d.config.nameRe == nil {
+		t.Error("Expected name_regex to be compiled")
+	}
+}
@@ -10,7 +10,16 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/plugin"
 
 	proxmoxclone "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/clone"
+	proxmoximport "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/import"
 	proxmoxiso "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/iso"
+	proxmoxlxc "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/lxc"
+	proxmoxova "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/ova"
+	proxmoxrestore "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/restore"
+	"github.com/hashicorp/packer-plugin-proxmox/datasource/node"
+	"github.com/hashicorp/packer-plugin-proxmox/datasource/template"
+	"github.com/hashicorp/packer-plugin-proxmox/post-processor/distribute"
+	importpp "github.com/hashicorp/packer-plugin-proxmox/post-processor/import"
+	"github.com/hashicorp/packer-plugin-proxmox/post-processor/vzdump"
 	"github.com/hashicorp/packer-plugin-proxmox/version"
 )
 
@@ -21,6 +30,15 @@ func main() {
 	pps.RegisterBuilder(plugin.DEFAULT_NAME, new(proxmoxiso.Builder))
 	pps.RegisterBuilder("iso", new(proxmoxiso.Builder))
 	pps.RegisterBuilder("clone", new(proxmoxclone.Builder))
+	pps.RegisterBuilder("import", new(proxmoximport.Builder))
+	pps.RegisterBuilder("lxc", new(proxmoxlxc.Builder))
+	pps.RegisterBuilder("ova", new(proxmoxova.Builder))
+	pps.RegisterBuilder("restore", new(proxmoxrestore.Builder))
+	pps.RegisterDatasource("node", new(node.Datasource))
+	pps.RegisterDatasource("template", new(template.Datasource))
+	pps.RegisterPostProcessor("distribute", new(distribute.PostProcessor))
+	pps.RegisterPostProcessor("import", new(importpp.PostProcessor))
+	pps.RegisterPostProcessor("vzdump", new(vzdump.PostProcessor))
 	pps.SetVersion(version.PluginVersion)
 	err := pps.Run()
 	if err != nil {
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package distribute
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id of the artifacts of the post-processor
+const BuilderId = "proxmox.post-processor.distribute"
+
+// Copy is a copy of the template on one of the targets.
+type Copy struct {
+	VMID int
+	Node string
+	Name string
+}
+
+type templateDeleter interface {
+	DeleteVm(vmr *proxmoxapi.VmRef) (exitStatus string, err error)
+}
+
+// Artifact lists the copies of the template.
+type Artifact struct {
+	Copies        []Copy
+	proxmoxClient templateDeleter
+
+	// StateData should store data such as GeneratedData
+	// to be shared with post-processors
+	StateData map[string]interface{}
+}
+
+// Artifact implements packersdk.Artifact
+var _ packersdk.Artifact = &Artifact{}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*Artifact) Files() []string {
+	return nil
+}
+
+// Id returns the copies as a comma separated list of node:vmid pairs.
+func (a *Artifact) Id() string {
+	ids := make([]string, len(a.Copies))
+	for i, c := range a.Copies {
+		ids[i] = fmt.Sprintf("%s:%d", c.Node, c.VMID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (a *Artifact) String() string {
+	copies := make([]string, len(a.Copies))
+	for i, c := range a.Copies {
+		copies[i] = fmt.Sprintf("%s (%d) on node %s", c.Name, c.VMID, c.Node)
+	}
+	return fmt.Sprintf("The template was copied to %d nodes: %s", len(a.Copies), strings.Join(copies, ", "))
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return a.StateData[name]
+}
+
+func (a *Artifact) Destroy() error {
+	var errs []string
+	for _, c := range a.Copies {
+		log.Printf("Destroying template: %d", c.VMID)
+		vmRef := proxmoxapi.NewVmRef(c.VMID)
+		vmRef.SetNode(c.Node)
+		vmRef.SetVmType("qemu")
+		if _, err := a.proxmoxClient.DeleteVm(vmRef); err != nil {
+			errs = append(errs, fmt.Sprintf("%d: %s", c.VMID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error destroying templates: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,targetConfig
+
+package distribute
+
+import (
+	"errors"
+	"fmt"
+
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type Config struct {
+	common.PackerConfig  `mapstructure:",squash"`
+	proxmox.ClientConfig `mapstructure:",squash"`
+
+	// The nodes to copy the template to. See [Targets](#targets).
+	Targets []targetConfig `mapstructure:"targets" required:"true"`
+	// Name of the copies. This is a template engine, where `{{ .Name }}` is
+	// the name of the built template, `{{ .Node }}` the node of the copy,
+	// `{{ .VMID }}` its VMID and `{{ .Index }}` its index in `targets`.
+	// Defaults to `{{ .Name }}-{{ .Node }}`.
+	TemplateName string `mapstructure:"template_name"`
+	// VMID of the copy of the first target, the next targets get consecutive
+	// VMIDs. If not given, the next free ID on the cluster is used for each
+	// copy, unless set in the target.
+	VMIDStart int `mapstructure:"vm_id_start"`
+	// Name of the resource pool to add the copies to.
+	Pool string `mapstructure:"pool"`
+
+	ctx interpolate.Context
+}
+
+// A target is a node to copy the template to.
+//
+// Usage example (HCL):
+//
+// ```hcl
+//
+//	targets {
+//	  node         = "pve2"
+//	  storage_pool = "local-lvm"
+//	}
+//
+// ```
+type targetConfig struct {
+	// Node to copy the template to.
+	Node string `mapstructure:"node" required:"true"`
+	// Storage pool to store the disks of the copy on. Defaults to the
+	// storage pools of the built template, which must then exist on `node`.
+	StoragePool string `mapstructure:"storage_pool"`
+	// VMID of the copy, overriding `vm_id_start`.
+	VMID int `mapstructure:"vm_id"`
+}
+
+func (c *Config) Prepare(raws ...interface{}) error {
+	err := config.Decode(c, &config.DecodeOpts{
+		PluginType:         "proxmox-distribute",
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"template_name",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	errs = packersdk.MultiErrorAppend(errs, c.ClientConfig.Prepare()...)
+
+	if c.TemplateName == "" {
+		c.TemplateName = "{{ .Name }}-{{ .Node }}"
+	}
+	if _, err := c.copyName(nameData{Name: "template", Node: "node", VMID: 100}); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("template_name is invalid: %s", err))
+	}
+	if c.VMIDStart != 0 && (c.VMIDStart < 100 || c.VMIDStart+len(c.Targets)-1 > 999999999) {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("vm_id_start must be in range 100-999999999"))
+	}
+
+	if len(c.Targets) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("at least one target must be specified"))
+	}
+	vmids := map[int]bool{}
+	for idx, target := range c.Targets {
+		if target.Node == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("targets[%d].node must be specified", idx))
+		}
+		vmid := c.targetVMID(idx)
+		if vmid == 0 {
+			continue
+		}
+		if vmid < 100 || vmid > 999999999 {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("targets[%d].vm_id must be in range 100-999999999", idx))
+		}
+		if vmids[vmid] {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("targets[%d]: VMID %d is used by another target", idx, vmid))
+		}
+		vmids[vmid] = true
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// targetVMID returns the configured VMID of the copy for the target at idx,
+// or 0 to use the next free ID.
+func (c *Config) targetVMID(idx int) int {
+	if c.Targets[idx].VMID != 0 {
+		return c.Targets[idx].VMID
+	}
+	if c.VMIDStart != 0 {
+		return c.VMIDStart + idx
+	}
+	return 0
+}
+
+// copyName renders template_name for a copy.
+func (c *Config) copyName(data nameData) (string, error) {
+	ctx := c.ctx
+	ctx.Data = data
+	return interpolate.Render(c.TemplateName, &ctx)
+}
@@ -0,0 +1,119 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package distribute
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	PackerBuildName     *string                  `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType   *string                  `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion   *string                  `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug         *bool                    `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce         *bool                    `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError       *string                  `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars      map[string]string        `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars []string                 `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	ProxmoxURLRaw       *string                  `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
+	Profile             *string                  `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile     *string                  `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	SkipCertValidation  *bool                    `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
+	CAFile              *string                  `mapstructure:"ca_file" cty:"ca_file" hcl:"ca_file"`
+	CAPEM               *string                  `mapstructure:"ca_pem" cty:"ca_pem" hcl:"ca_pem"`
+	TLSFingerprint      *string                  `mapstructure:"tls_fingerprint" cty:"tls_fingerprint" hcl:"tls_fingerprint"`
+	ClientCertFile      *string                  `mapstructure:"client_cert_file" cty:"client_cert_file" hcl:"client_cert_file"`
+	ClientKeyFile       *string                  `mapstructure:"client_key_file" cty:"client_key_file" hcl:"client_key_file"`
+	APIProxyURL         *string                  `mapstructure:"api_proxy_url" cty:"api_proxy_url" hcl:"api_proxy_url"`
+	APIExtraHeaders     map[string]string        `mapstructure:"api_extra_headers" cty:"api_extra_headers" hcl:"api_extra_headers"`
+	Username            *string                  `mapstructure:"username" cty:"username" hcl:"username"`
+	Password            *string                  `mapstructure:"password" cty:"password" hcl:"password"`
+	Token               *string                  `mapstructure:"token" cty:"token" hcl:"token"`
+	OTP                 *string                  `mapstructure:"otp" cty:"otp" hcl:"otp"`
+	TOTPSecret          *string                  `mapstructure:"totp_secret" cty:"totp_secret" hcl:"totp_secret"`
+	TaskTimeout         *string                  `mapstructure:"task_timeout" cty:"task_timeout" hcl:"task_timeout"`
+	TransferTimeout     *string                  `mapstructure:"transfer_timeout" cty:"transfer_timeout" hcl:"transfer_timeout"`
+	APIRetry            *proxmox.FlatRetryConfig `mapstructure:"api_retry" cty:"api_retry" hcl:"api_retry"`
+	Targets             []FlattargetConfig       `mapstructure:"targets" required:"true" cty:"targets" hcl:"targets"`
+	TemplateName        *string                  `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
+	VMIDStart           *int                     `mapstructure:"vm_id_start" cty:"vm_id_start" hcl:"vm_id_start"`
+	Pool                *string                  `mapstructure:"pool" cty:"pool" hcl:"pool"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":        &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":               &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":               &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":            &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":      &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables": &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"proxmox_url":                &hcldec.AttrSpec{Name: "proxmox_url", Type: cty.String, Required: false},
+		"profile":                    &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file":           &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
+		"insecure_skip_tls_verify":   &hcldec.AttrSpec{Name: "insecure_skip_tls_verify", Type: cty.Bool, Required: false},
+		"ca_file":                    &hcldec.AttrSpec{Name: "ca_file", Type: cty.String, Required: false},
+		"ca_pem":                     &hcldec.AttrSpec{Name: "ca_pem", Type: cty.String, Required: false},
+		"tls_fingerprint":            &hcldec.AttrSpec{Name: "tls_fingerprint", Type: cty.String, Required: false},
+		"client_cert_file":           &hcldec.AttrSpec{Name: "client_cert_file", Type: cty.String, Required: false},
+		"client_key_file":            &hcldec.AttrSpec{Name: "client_key_file", Type: cty.String, Required: false},
+		"api_proxy_url":              &hcldec.AttrSpec{Name: "api_proxy_url", Type: cty.String, Required: false},
+		"api_extra_headers":          &hcldec.AttrSpec{Name: "api_extra_headers", Type: cty.Map(cty.String), Required: false},
+		"username":                   &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":                   &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"token":                      &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"otp":                        &hcldec.AttrSpec{Name: "otp", Type: cty.String, Required: false},
+		"totp_secret":                &hcldec.AttrSpec{Name: "totp_secret", Type: cty.String, Required: false},
+		"task_timeout":               &hcldec.AttrSpec{Name: "task_timeout", Type: cty.String, Required: false},
+		"transfer_timeout":           &hcldec.AttrSpec{Name: "transfer_timeout", Type: cty.String, Required: false},
+		"api_retry":                  &hcldec.BlockSpec{TypeName: "api_retry", Nested: hcldec.ObjectSpec((*proxmox.FlatRetryConfig)(nil).HCL2Spec())},
+		"targets":                    &hcldec.BlockListSpec{TypeName: "targets", Nested: hcldec.ObjectSpec((*FlattargetConfig)(nil).HCL2Spec())},
+		"template_name":              &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
+		"vm_id_start":                &hcldec.AttrSpec{Name: "vm_id_start", Type: cty.Number, Required: false},
+		"pool":                       &hcldec.AttrSpec{Name: "pool", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlattargetConfig is an auto-generated flat version of targetConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlattargetConfig struct {
+	Node        *string `mapstructure:"node" required:"true" cty:"node" hcl:"node"`
+	StoragePool *string `mapstructure:"storage_pool" cty:"storage_pool" hcl:"storage_pool"`
+	VMID        *int    `mapstructure:"vm_id" cty:"vm_id" hcl:"vm_id"`
+}
+
+// FlatMapstructure returns a new FlattargetConfig.
+// FlattargetConfig is an auto-generated flat version of targetConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*targetConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlattargetConfig)
+}
+
+// HCL2Spec returns the hcl spec of a targetConfig.
+// This spec is used by HCL to read the fields of targetConfig.
+// The decoded values from this spec will then be applied to a FlattargetConfig.
+func (*FlattargetConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"node":         &hcldec.AttrSpec{Name: "node", Type: cty.String, Required: false},
+		"storage_pool": &hcldec.AttrSpec{Name: "storage_pool", Type: cty.String, Required: false},
+		"vm_id":        &hcldec.AttrSpec{Name: "vm_id", Type: cty.Number, Required: false},
+	}
+	return s
+}
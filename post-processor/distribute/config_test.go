@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package distribute
+
+import (
+	"testing"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url": "https://my-proxmox.my-domain:8006/api2/json",
+		"username":    "apiuser@pve",
+		"token":       "xxxx-xxxx-xxxx-xxxx",
+		"targets": []map[string]interface{}{
+			{"node": "pve2", "storage_pool": "local-lvm"},
+			{"node": "pve3"},
+		},
+	}
+}
+
+func TestTargets(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectFailure bool
+		expectedVMIDs []int
+	}{
+		{
+			name:          "next free VMIDs, no error",
+			overrides:     map[string]interface{}{},
+			expectFailure: false,
+			expectedVMIDs: []int{0, 0},
+		},
+		{
+			name:          "consecutive VMIDs, no error",
+			overrides:     map[string]interface{}{"vm_id_start": 9001},
+			expectFailure: false,
+			expectedVMIDs: []int{9001, 9002},
+		},
+		{
+			name: "target VMID overrides vm_id_start, no error",
+			overrides: map[string]interface{}{
+				"vm_id_start": 9001,
+				"targets": []map[string]interface{}{
+					{"node": "pve2", "vm_id": 8000},
+					{"node": "pve3"},
+				},
+			},
+			expectFailure: false,
+			expectedVMIDs: []int{8000, 9002},
+		},
+		{
+			name: "duplicate VMIDs, error",
+			overrides: map[string]interface{}{
+				"vm_id_start": 9001,
+				"targets": []map[string]interface{}{
+					{"node": "pve2"},
+					{"node": "pve3", "vm_id": 9001},
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name:          "vm_id_start out of range, error",
+			overrides:     map[string]interface{}{"vm_id_start": 42},
+			expectFailure: true,
+		},
+		{
+			name: "target without node, error",
+			overrides: map[string]interface{}{
+				"targets": []map[string]interface{}{
+					{"storage_pool": "local-lvm"},
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name:          "no targets, error",
+			overrides:     map[string]interface{}{"targets": []map[string]interface{}{}},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid template_name, error",
+			overrides:     map[string]interface{}{"template_name": "{{ .Name"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			err := c.Prepare(cfg)
+			if tt.expectFailure {
+				if err == nil {
+					t.Error("Expected config to fail, but no errors were returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected config to succeed, but got %s", err)
+			}
+			for idx, expected := range tt.expectedVMIDs {
+				if vmid := c.targetVMID(idx); vmid != expected {
+					t.Errorf("Expected VMID %d for target %d, got %d", expected, idx, vmid)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateName(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateName string
+		expected     string
+	}{
+		{
+			name:         "default",
+			templateName: "",
+			expected:     "debian-12-pve2",
+		},
+		{
+			name:         "custom",
+			templateName: "{{ .Name }}-{{ .VMID }}-{{ .Index }}",
+			expected:     "debian-12-9002-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			if tt.templateName != "" {
+				cfg["template_name"] = tt.templateName
+			}
+
+			var c Config
+			if err := c.Prepare(cfg); err != nil {
+				t.Fatal(err)
+			}
+			name, err := c.copyName(nameData{Name: "debian-12", Node: "pve2", VMID: 9002, Index: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.expected {
+				t.Errorf("Expected name %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package distribute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmoxclone "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/clone"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	proxmoximport "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/import"
+	proxmoxiso "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/iso"
+	proxmoxova "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/ova"
+	proxmoxrestore "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/restore"
+	importpp "github.com/hashicorp/packer-plugin-proxmox/post-processor/import"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The artifacts whose virtual machine templates can be distributed
+var builderIDs = []string{
+	proxmoxclone.BuilderID,
+	proxmoximport.BuilderID,
+	proxmoxiso.BuilderID,
+	proxmoxova.BuilderID,
+	proxmoxrestore.BuilderID,
+	importpp.BuilderId,
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+// PostProcessor implements packersdk.PostProcessor
+var _ packersdk.PostProcessor = &PostProcessor{}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	return p.config.Prepare(raws...)
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	supported := false
+	for _, id := range builderIDs {
+		if artifact.BuilderId() == id {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, false, false, fmt.Errorf("unsupported artifact type %q: the proxmox-distribute post-processor only works with virtual machine templates of the proxmox builders", artifact.BuilderId())
+	}
+	vmid, err := strconv.Atoi(artifact.Id())
+	if err != nil {
+		return nil, false, false, fmt.Errorf("artifact ID %q is not a VMID: %s", artifact.Id(), err)
+	}
+
+	client, err := p.config.NewClient(p.config.PackerDebug)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	a, err := distribute(ctx, ui, client, &p.config, vmid)
+	if err != nil {
+		return nil, false, false, err
+	}
+	// The built template is left in place, unless keep_input_artifact says otherwise
+	return a, true, false, nil
+}
+
+type templateCopier interface {
+	proxmox.TaskClient
+	CheckVmRef(vmr *proxmoxapi.VmRef) (err error)
+	GetVmConfig(vmr *proxmoxapi.VmRef) (vmConfig map[string]interface{}, err error)
+	GetNextID(currentID int) (nextID int, err error)
+	DeleteVm(vmr *proxmoxapi.VmRef) (exitStatus string, err error)
+}
+
+var _ templateCopier = &proxmox.Client{}
+
+// distribute full clones the template to every target, converting each copy
+// to a template. When a copy fails, the copies made so far are removed.
+func distribute(ctx context.Context, ui packersdk.Ui, client templateCopier, c *Config, vmid int) (*Artifact, error) {
+	source := proxmoxapi.NewVmRef(vmid)
+	if err := client.CheckVmRef(source); err != nil {
+		return nil, fmt.Errorf("error looking up template %d: %s", vmid, err)
+	}
+	if source.GetVmType() != "qemu" {
+		return nil, fmt.Errorf("%d is not a virtual machine template, only virtual machine templates can be distributed", vmid)
+	}
+	sourceConfig, err := client.GetVmConfig(source)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching configuration of template %d: %s", vmid, err)
+	}
+	sourceName, _ := sourceConfig["name"].(string)
+
+	a := &Artifact{
+		proxmoxClient: client,
+		StateData:     map[string]interface{}{},
+	}
+	for idx, target := range c.Targets {
+		copyID := c.targetVMID(idx)
+		if copyID == 0 {
+			copyID, err = client.GetNextID(0)
+			if err != nil {
+				err = fmt.Errorf("error getting the next free VMID: %s", err)
+			}
+		}
+		var name string
+		if err == nil {
+			name, err = c.copyName(nameData{Name: sourceName, Node: target.Node, VMID: copyID, Index: idx})
+		}
+		if err == nil {
+			ui.Say(fmt.Sprintf("Copying template %d to node %s as %s (%d)", vmid, target.Node, name, copyID))
+			err = copyTemplate(ctx, ui, client, c, source, target, copyID, name)
+		}
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error copying template to node %s, removing the copies made so far", target.Node))
+			if derr := a.Destroy(); derr != nil {
+				ui.Error(derr.Error())
+			}
+			return nil, fmt.Errorf("error copying template %d to node %s: %s", vmid, target.Node, err)
+		}
+		a.Copies = append(a.Copies, Copy{VMID: copyID, Node: target.Node, Name: name})
+	}
+	return a, nil
+}
+
+// copyTemplate full clones the template on its node, migrates the clone to
+// the target node and converts it to a template. Templates can only be
+// cloned to another node when their disks are on shared storage, so the
+// clone is migrated, along with its disks, instead.
+func copyTemplate(ctx context.Context, ui packersdk.Ui, client templateCopier, c *Config, source *proxmoxapi.VmRef, target targetConfig, vmid int, name string) error {
+	// Clones and migrations copy the disks of the template
+	transfer := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TaskTimeout, Retry: c.APIRetry}
+
+	params := map[string]interface{}{
+		"newid": vmid,
+		"name":  name,
+		"full":  1,
+	}
+	if c.Pool != "" {
+		params["pool"] = c.Pool
+	}
+	sameNode := target.Node == source.Node()
+	if sameNode && target.StoragePool != "" {
+		params["storage"] = target.StoragePool
+	}
+
+	vmRef := proxmoxapi.NewVmRef(vmid)
+	vmRef.SetNode(source.Node())
+	vmRef.SetVmType("qemu")
+
+	log.Printf("cloning template %d to %d", source.VmId(), vmid)
+	_, err := transfer.Run(ctx, params, fmt.Sprintf("/nodes/%s/qemu/%d/clone", source.Node(), source.VmId()))
+	if err != nil {
+		// The ID may belong to another guest, created in the meantime
+		if !errors.Is(err, proxmox.ErrAlreadyExists) {
+			deleteCopy(ui, client, vmRef)
+		}
+		return fmt.Errorf("clone failed: %s", err)
+	}
+
+	if !sameNode {
+		params := map[string]interface{}{
+			"target":           target.Node,
+			"online":           0,
+			"with-local-disks": 1,
+		}
+		if target.StoragePool != "" {
+			params["targetstorage"] = target.StoragePool
+		}
+		log.Printf("migrating %d to node %s", vmid, target.Node)
+		_, err := transfer.Run(ctx, params, fmt.Sprintf("/nodes/%s/qemu/%d/migrate", source.Node(), vmid))
+		if err != nil {
+			deleteCopy(ui, client, vmRef)
+			return fmt.Errorf("migration failed: %s", err)
+		}
+		vmRef.SetNode(target.Node)
+	}
+
+	if _, err := tracker.Run(ctx, nil, fmt.Sprintf("/nodes/%s/qemu/%d/template", vmRef.Node(), vmid)); err != nil {
+		deleteCopy(ui, client, vmRef)
+		return fmt.Errorf("conversion to template failed: %s", err)
+	}
+	return nil
+}
+
+// deleteCopy deletes a copy that failed half-way, which may not exist.
+func deleteCopy(ui packersdk.Ui, client templateCopier, vmRef *proxmoxapi.VmRef) {
+	_, err := client.DeleteVm(vmRef)
+	if errors.Is(err, proxmox.ErrNotFound) {
+		log.Printf("copy %d is already gone", vmRef.VmId())
+		return
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error deleting copy %d. Please delete it manually: %s", vmRef.VmId(), err))
+	}
+}
+
+// nameData is the data available to template_name.
+type nameData struct {
+	Name  string
+	Node  string
+	VMID  int
+	Index int
+}
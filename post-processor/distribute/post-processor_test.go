@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package distribute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type templateCopierMock struct {
+	nextID       int
+	failClone    error
+	failTemplate int
+
+	clones     []map[string]interface{}
+	migrations map[string]map[string]interface{}
+	templates  []string
+	deleted    []int
+}
+
+func (m *templateCopierMock) CheckVmRef(vmr *proxmoxapi.VmRef) error {
+	vmr.SetNode("pve1")
+	vmr.SetVmType("qemu")
+	return nil
+}
+
+func (m *templateCopierMock) GetVmConfig(vmr *proxmoxapi.VmRef) (map[string]interface{}, error) {
+	return map[string]interface{}{"name": "debian-12"}, nil
+}
+
+func (m *templateCopierMock) GetNextID(currentID int) (int, error) {
+	m.nextID++
+	return m.nextID, nil
+}
+
+func (m *templateCopierMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	switch {
+	case strings.HasSuffix(url, "/clone"):
+		if m.failClone != nil {
+			return "", m.failClone
+		}
+		m.clones = append(m.clones, params)
+	case strings.HasSuffix(url, "/migrate"):
+		if m.migrations == nil {
+			m.migrations = map[string]map[string]interface{}{}
+		}
+		m.migrations[url] = params
+	case strings.HasSuffix(url, "/template"):
+		// /nodes/<node>/qemu/<vmid>/template
+		parts := strings.Split(url, "/")
+		if parts[4] == fmt.Sprint(m.failTemplate) {
+			return "", errors.New("locked")
+		}
+		m.templates = append(m.templates, parts[2]+":"+parts[4])
+	}
+	return `{"data":"UPID:pve1:00001234:00005678:65A0B1C2:qmclone:100:root@pam:"}`, nil
+}
+
+func (m *templateCopierMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": "OK"}}, nil
+}
+
+func (m *templateCopierMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (m *templateCopierMock) Delete(url string) error {
+	return nil
+}
+
+func (m *templateCopierMock) DeleteVm(vmr *proxmoxapi.VmRef) (string, error) {
+	m.deleted = append(m.deleted, vmr.VmId())
+	return "OK", nil
+}
+
+var _ templateCopier = &templateCopierMock{}
+
+func TestDistribute(t *testing.T) {
+	c := &Config{
+		TemplateName: "{{ .Name }}-{{ .Node }}",
+		Pool:         "templates",
+		Targets: []targetConfig{
+			{Node: "pve1", StoragePool: "local-zfs"},
+			{Node: "pve2", StoragePool: "local-lvm"},
+			{Node: "pve3"},
+		},
+	}
+	client := &templateCopierMock{nextID: 200}
+
+	a, err := distribute(context.TODO(), packersdk.TestUi(t), client, c, 100)
+	if err != nil {
+		t.Fatalf("Expected distribution to succeed, but got %s", err)
+	}
+
+	if a.Id() != "pve1:201,pve2:202,pve3:203" {
+		t.Errorf("Expected the artifact to list every copy, got %s", a.Id())
+	}
+	if len(client.clones) != 3 {
+		t.Fatalf("Expected 3 clones, got %d", len(client.clones))
+	}
+	// Only a clone on the node of the template can be placed on another storage directly
+	if client.clones[0]["storage"] != "local-zfs" || client.clones[1]["storage"] != nil {
+		t.Errorf("Unexpected clone storages %v and %v", client.clones[0]["storage"], client.clones[1]["storage"])
+	}
+	for _, clone := range client.clones {
+		if clone["full"] != 1 || clone["pool"] != "templates" {
+			t.Errorf("Expected a full clone into the pool, got %v", clone)
+		}
+	}
+	if client.clones[1]["name"] != "debian-12-pve2" {
+		t.Errorf("Expected copy name debian-12-pve2, got %v", client.clones[1]["name"])
+	}
+
+	if len(client.migrations) != 2 {
+		t.Errorf("Expected 2 migrations, got %v", client.migrations)
+	}
+	migration := client.migrations["/nodes/pve1/qemu/202/migrate"]
+	if migration["target"] != "pve2" || migration["targetstorage"] != "local-lvm" || migration["with-local-disks"] != 1 {
+		t.Errorf("Unexpected migration parameters %v", migration)
+	}
+	if _, ok := client.migrations["/nodes/pve1/qemu/203/migrate"]["targetstorage"]; ok {
+		t.Error("Expected no target storage when the target has no storage_pool")
+	}
+
+	expectedTemplates := []string{"pve1:201", "pve2:202", "pve3:203"}
+	for i, expected := range expectedTemplates {
+		if i >= len(client.templates) || client.templates[i] != expected {
+			t.Errorf("Expected templates %v, got %v", expectedTemplates, client.templates)
+			break
+		}
+	}
+}
+
+func TestDistributeRollback(t *testing.T) {
+	c := &Config{
+		TemplateName: "{{ .Name }}-{{ .Node }}",
+		VMIDStart:    9001,
+		Targets: []targetConfig{
+			{Node: "pve2"},
+			{Node: "pve3"},
+		},
+	}
+	client := &templateCopierMock{failTemplate: 9002}
+
+	_, err := distribute(context.TODO(), packersdk.TestUi(t), client, c, 100)
+	if err == nil {
+		t.Fatal("Expected distribution to fail")
+	}
+	if len(client.deleted) != 2 || client.deleted[0] != 9002 || client.deleted[1] != 9001 {
+		t.Errorf("Expected the failed and the previous copies to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestDistributeCloneFailure(t *testing.T) {
+	cs := []struct {
+		name            string
+		cloneErr        error
+		expectedDeleted []int
+	}{
+		{
+			name:            "half-created clone is deleted",
+			cloneErr:        &proxmox.APIError{Kind: proxmox.ErrTimeout, Err: errors.New("timeout waiting for task")},
+			expectedDeleted: []int{9001},
+		},
+		{
+			name:            "guest already holding the ID is left alone",
+			cloneErr:        proxmox.ClassifyError(errors.New("500 unable to create VM 9001 - VM 9001 already exists on node 'pve1'"), ""),
+			expectedDeleted: nil,
+		},
+	}
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{
+				TemplateName: "{{ .Name }}-{{ .Node }}",
+				VMIDStart:    9001,
+				Targets:      []targetConfig{{Node: "pve2"}},
+			}
+			client := &templateCopierMock{failClone: c.cloneErr}
+
+			_, err := distribute(context.TODO(), packersdk.TestUi(t), client, config, 100)
+			if err == nil {
+				t.Fatal("Expected distribution to fail")
+			}
+			if fmt.Sprint(client.deleted) != fmt.Sprint(c.expectedDeleted) {
+				t.Errorf("Expected deleted copies %v, got %v", c.expectedDeleted, client.deleted)
+			}
+		})
+	}
+}
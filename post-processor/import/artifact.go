@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id of the artifacts of the post-processor
+const BuilderId = "proxmox.post-processor.import"
+
+type templateDeleter interface {
+	DeleteVm(vmr *proxmoxapi.VmRef) (exitStatus string, err error)
+}
+
+// Artifact is a template imported into the target cluster.
+type Artifact struct {
+	templateID    int
+	node          string
+	proxmoxClient templateDeleter
+
+	// StateData should store data such as GeneratedData
+	// to be shared with post-processors
+	StateData map[string]interface{}
+}
+
+// Artifact implements packersdk.Artifact
+var _ packersdk.Artifact = &Artifact{}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return strconv.Itoa(a.templateID)
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("A template was imported: %d on node %s", a.templateID, a.node)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return a.StateData[name]
+}
+
+func (a *Artifact) Destroy() error {
+	log.Printf("Destroying template: %d", a.templateID)
+	_, err := a.proxmoxClient.DeleteVm(proxmoxapi.NewVmRef(a.templateID))
+	return err
+}
@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,sourceClusterConfig
+
+package proxmoximport
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// The options below configure the cluster the template is imported into.
+type Config struct {
+	common.PackerConfig   `mapstructure:",squash"`
+	proxmox.ClientConfig  `mapstructure:",squash"`
+	proxmox.NodeSSHConfig `mapstructure:",squash"`
+
+	// Node of the target cluster to restore the template on. Defaults to the
+	// node of the `profile`.
+	Node string `mapstructure:"node" required:"true"`
+	// Proxmox storage pool of the target cluster onto which to upload the
+	// archive. Must be a directory based storage allowing the `backup`
+	// content type, reachable from `node_ssh_host`.
+	BackupStoragePool string `mapstructure:"backup_storage_pool" required:"true"`
+	// Keep the uploaded archive on `backup_storage_pool` once the template
+	// is restored. Defaults to `false`, removing it.
+	KeepBackup bool `mapstructure:"keep_backup"`
+
+	// The ID of the imported template. If not given, the next free ID on
+	// the target cluster is used.
+	VMID int `mapstructure:"vm_id"`
+	// Name of the imported template. Defaults to the name recorded in the
+	// backup.
+	TemplateName string `mapstructure:"template_name"`
+	// Description of the imported template. Defaults to the description
+	// recorded in the backup.
+	TemplateDescription string `mapstructure:"template_description"`
+	// Name of the resource pool to add the imported template to.
+	Pool string `mapstructure:"pool"`
+	// Storage pool to restore the disks of the template to.
+	// Defaults to the storage pools recorded in the backup, which must then
+	// exist on the target cluster.
+	RestoreStoragePool string `mapstructure:"restore_storage_pool"`
+	// Limit the I/O bandwidth of the restore, in KiB/s.
+	// Defaults to the limit configured for the cluster.
+	RestoreBandwidthLimit int `mapstructure:"restore_bwlimit"`
+
+	// Settings of the cluster the template was built on. Required to import
+	// the artifact of a proxmox builder, which is first backed up and
+	// downloaded like the `proxmox-vzdump` post-processor does. See
+	// [Source Cluster](#source-cluster).
+	SourceCluster sourceClusterConfig `mapstructure:"source_cluster"`
+
+	ctx interpolate.Context
+}
+
+// The `source_cluster` block configures the cluster the template was built
+// on, when importing the artifact of a proxmox builder. The template is
+// backed up to `backup_storage_pool` and downloaded over SSH, before being
+// uploaded to the target cluster. Its connection settings are not read from
+// the `PROXMOX_*` environment variables, which apply to the target cluster.
+//
+// Usage example (HCL):
+//
+// ```hcl
+//
+//	source_cluster {
+//	  proxmox_url         = "https://dev-proxmox.my-domain:8006/api2/json"
+//	  username            = "apiuser@pve"
+//	  token               = "xxxx-xxxx-xxxx-xxxx"
+//	  node_ssh_password   = "supersecret"
+//	  backup_storage_pool = "local"
+//	}
+//
+// ```
+type sourceClusterConfig struct {
+	proxmox.ClientConfig  `mapstructure:",squash"`
+	proxmox.NodeSSHConfig `mapstructure:",squash"`
+
+	// Proxmox storage pool of the source cluster the template is backed up
+	// to. Must be a directory based storage allowing the `backup` content
+	// type, reachable from `node_ssh_host`.
+	BackupStoragePool string `mapstructure:"backup_storage_pool" required:"true"`
+}
+
+// Archives created by vzdump, of either virtual machines or containers
+var backupArchiveRe = regexp.MustCompile(`^vzdump-(qemu|lxc)-.+\.(vma|tar)(\.(zst|gz|lzo))?$`)
+
+func (c *Config) Prepare(raws ...interface{}) error {
+	err := config.Decode(c, &config.DecodeOpts{
+		PluginType:         "proxmox-import",
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	errs = packersdk.MultiErrorAppend(errs, c.ClientConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.NodeSSHConfig.Prepare(c.ProxmoxURLRaw)...)
+
+	if c.Node == "" {
+		c.Node = c.DefaultNode()
+	}
+	if c.Node == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("node must be specified"))
+	}
+	if c.BackupStoragePool == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("backup_storage_pool must be specified"))
+	}
+	if c.VMID != 0 && (c.VMID < 100 || c.VMID > 999999999) {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("vm_id must be in range 100-999999999"))
+	}
+	if c.RestoreBandwidthLimit < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("restore_bwlimit must not be negative"))
+	}
+
+	if c.SourceCluster.BackupStoragePool != "" {
+		src := &c.SourceCluster
+		for _, err := range src.ClientConfig.PrepareWithoutEnvironment() {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_cluster: %s", err))
+		}
+		for _, err := range src.NodeSSHConfig.Prepare(src.ProxmoxURLRaw) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_cluster: %s", err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
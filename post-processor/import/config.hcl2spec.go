@@ -0,0 +1,193 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package proxmoximport
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	PackerBuildName       *string                  `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType     *string                  `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion     *string                  `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug           *bool                    `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce           *bool                    `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError         *string                  `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars        map[string]string        `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars   []string                 `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	ProxmoxURLRaw         *string                  `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
+	Profile               *string                  `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile       *string                  `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	SkipCertValidation    *bool                    `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
+	CAFile                *string                  `mapstructure:"ca_file" cty:"ca_file" hcl:"ca_file"`
+	CAPEM                 *string                  `mapstructure:"ca_pem" cty:"ca_pem" hcl:"ca_pem"`
+	TLSFingerprint        *string                  `mapstructure:"tls_fingerprint" cty:"tls_fingerprint" hcl:"tls_fingerprint"`
+	ClientCertFile        *string                  `mapstructure:"client_cert_file" cty:"client_cert_file" hcl:"client_cert_file"`
+	ClientKeyFile         *string                  `mapstructure:"client_key_file" cty:"client_key_file" hcl:"client_key_file"`
+	APIProxyURL           *string                  `mapstructure:"api_proxy_url" cty:"api_proxy_url" hcl:"api_proxy_url"`
+	APIExtraHeaders       map[string]string        `mapstructure:"api_extra_headers" cty:"api_extra_headers" hcl:"api_extra_headers"`
+	Username              *string                  `mapstructure:"username" cty:"username" hcl:"username"`
+	Password              *string                  `mapstructure:"password" cty:"password" hcl:"password"`
+	Token                 *string                  `mapstructure:"token" cty:"token" hcl:"token"`
+	OTP                   *string                  `mapstructure:"otp" cty:"otp" hcl:"otp"`
+	TOTPSecret            *string                  `mapstructure:"totp_secret" cty:"totp_secret" hcl:"totp_secret"`
+	TaskTimeout           *string                  `mapstructure:"task_timeout" cty:"task_timeout" hcl:"task_timeout"`
+	TransferTimeout       *string                  `mapstructure:"transfer_timeout" cty:"transfer_timeout" hcl:"transfer_timeout"`
+	APIRetry              *proxmox.FlatRetryConfig `mapstructure:"api_retry" cty:"api_retry" hcl:"api_retry"`
+	NodeSSHHost           *string                  `mapstructure:"node_ssh_host" cty:"node_ssh_host" hcl:"node_ssh_host"`
+	NodeSSHPort           *int                     `mapstructure:"node_ssh_port" cty:"node_ssh_port" hcl:"node_ssh_port"`
+	NodeSSHUsername       *string                  `mapstructure:"node_ssh_username" cty:"node_ssh_username" hcl:"node_ssh_username"`
+	NodeSSHPassword       *string                  `mapstructure:"node_ssh_password" cty:"node_ssh_password" hcl:"node_ssh_password"`
+	NodeSSHPrivateKeyFile *string                  `mapstructure:"node_ssh_private_key_file" cty:"node_ssh_private_key_file" hcl:"node_ssh_private_key_file"`
+	NodeSSHHostKey        *string                  `mapstructure:"node_ssh_host_key" cty:"node_ssh_host_key" hcl:"node_ssh_host_key"`
+	NodeSSHKnownHostsFile *string                  `mapstructure:"node_ssh_known_hosts_file" cty:"node_ssh_known_hosts_file" hcl:"node_ssh_known_hosts_file"`
+	Node                  *string                  `mapstructure:"node" required:"true" cty:"node" hcl:"node"`
+	BackupStoragePool     *string                  `mapstructure:"backup_storage_pool" required:"true" cty:"backup_storage_pool" hcl:"backup_storage_pool"`
+	KeepBackup            *bool                    `mapstructure:"keep_backup" cty:"keep_backup" hcl:"keep_backup"`
+	VMID                  *int                     `mapstructure:"vm_id" cty:"vm_id" hcl:"vm_id"`
+	TemplateName          *string                  `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
+	TemplateDescription   *string                  `mapstructure:"template_description" cty:"template_description" hcl:"template_description"`
+	Pool                  *string                  `mapstructure:"pool" cty:"pool" hcl:"pool"`
+	RestoreStoragePool    *string                  `mapstructure:"restore_storage_pool" cty:"restore_storage_pool" hcl:"restore_storage_pool"`
+	RestoreBandwidthLimit *int                     `mapstructure:"restore_bwlimit" cty:"restore_bwlimit" hcl:"restore_bwlimit"`
+	SourceCluster         *FlatsourceClusterConfig `mapstructure:"source_cluster" cty:"source_cluster" hcl:"source_cluster"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":        &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":               &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":               &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":            &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":      &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables": &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"proxmox_url":                &hcldec.AttrSpec{Name: "proxmox_url", Type: cty.String, Required: false},
+		"profile":                    &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file":           &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
+		"insecure_skip_tls_verify":   &hcldec.AttrSpec{Name: "insecure_skip_tls_verify", Type: cty.Bool, Required: false},
+		"ca_file":                    &hcldec.AttrSpec{Name: "ca_file", Type: cty.String, Required: false},
+		"ca_pem":                     &hcldec.AttrSpec{Name: "ca_pem", Type: cty.String, Required: false},
+		"tls_fingerprint":            &hcldec.AttrSpec{Name: "tls_fingerprint", Type: cty.String, Required: false},
+		"client_cert_file":           &hcldec.AttrSpec{Name: "client_cert_file", Type: cty.String, Required: false},
+		"client_key_file":            &hcldec.AttrSpec{Name: "client_key_file", Type: cty.String, Required: false},
+		"api_proxy_url":              &hcldec.AttrSpec{Name: "api_proxy_url", Type: cty.String, Required: false},
+		"api_extra_headers":          &hcldec.AttrSpec{Name: "api_extra_headers", Type: cty.Map(cty.String), Required: false},
+		"username":                   &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":                   &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"token":                      &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"otp":                        &hcldec.AttrSpec{Name: "otp", Type: cty.String, Required: false},
+		"totp_secret":                &hcldec.AttrSpec{Name: "totp_secret", Type: cty.String, Required: false},
+		"task_timeout":               &hcldec.AttrSpec{Name: "task_timeout", Type: cty.String, Required: false},
+		"transfer_timeout":           &hcldec.AttrSpec{Name: "transfer_timeout", Type: cty.String, Required: false},
+		"api_retry":                  &hcldec.BlockSpec{TypeName: "api_retry", Nested: hcldec.ObjectSpec((*proxmox.FlatRetryConfig)(nil).HCL2Spec())},
+		"node_ssh_host":              &hcldec.AttrSpec{Name: "node_ssh_host", Type: cty.String, Required: false},
+		"node_ssh_port":              &hcldec.AttrSpec{Name: "node_ssh_port", Type: cty.Number, Required: false},
+		"node_ssh_username":          &hcldec.AttrSpec{Name: "node_ssh_username", Type: cty.String, Required: false},
+		"node_ssh_password":          &hcldec.AttrSpec{Name: "node_ssh_password", Type: cty.String, Required: false},
+		"node_ssh_private_key_file":  &hcldec.AttrSpec{Name: "node_ssh_private_key_file", Type: cty.String, Required: false},
+		"node_ssh_host_key":          &hcldec.AttrSpec{Name: "node_ssh_host_key", Type: cty.String, Required: false},
+		"node_ssh_known_hosts_file":  &hcldec.AttrSpec{Name: "node_ssh_known_hosts_file", Type: cty.String, Required: false},
+		"node":                       &hcldec.AttrSpec{Name: "node", Type: cty.String, Required: false},
+		"backup_storage_pool":        &hcldec.AttrSpec{Name: "backup_storage_pool", Type: cty.String, Required: false},
+		"keep_backup":                &hcldec.AttrSpec{Name: "keep_backup", Type: cty.Bool, Required: false},
+		"vm_id":                      &hcldec.AttrSpec{Name: "vm_id", Type: cty.Number, Required: false},
+		"template_name":              &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
+		"template_description":       &hcldec.AttrSpec{Name: "template_description", Type: cty.String, Required: false},
+		"pool":                       &hcldec.AttrSpec{Name: "pool", Type: cty.String, Required: false},
+		"restore_storage_pool":       &hcldec.AttrSpec{Name: "restore_storage_pool", Type: cty.String, Required: false},
+		"restore_bwlimit":            &hcldec.AttrSpec{Name: "restore_bwlimit", Type: cty.Number, Required: false},
+		"source_cluster":             &hcldec.BlockSpec{TypeName: "source_cluster", Nested: hcldec.ObjectSpec((*FlatsourceClusterConfig)(nil).HCL2Spec())},
+	}
+	return s
+}
+
+// FlatsourceClusterConfig is an auto-generated flat version of sourceClusterConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatsourceClusterConfig struct {
+	ProxmoxURLRaw         *string                  `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
+	Profile               *string                  `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile       *string                  `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	SkipCertValidation    *bool                    `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
+	CAFile                *string                  `mapstructure:"ca_file" cty:"ca_file" hcl:"ca_file"`
+	CAPEM                 *string                  `mapstructure:"ca_pem" cty:"ca_pem" hcl:"ca_pem"`
+	TLSFingerprint        *string                  `mapstructure:"tls_fingerprint" cty:"tls_fingerprint" hcl:"tls_fingerprint"`
+	ClientCertFile        *string                  `mapstructure:"client_cert_file" cty:"client_cert_file" hcl:"client_cert_file"`
+	ClientKeyFile         *string                  `mapstructure:"client_key_file" cty:"client_key_file" hcl:"client_key_file"`
+	APIProxyURL           *string                  `mapstructure:"api_proxy_url" cty:"api_proxy_url" hcl:"api_proxy_url"`
+	APIExtraHeaders       map[string]string        `mapstructure:"api_extra_headers" cty:"api_extra_headers" hcl:"api_extra_headers"`
+	Username              *string                  `mapstructure:"username" cty:"username" hcl:"username"`
+	Password              *string                  `mapstructure:"password" cty:"password" hcl:"password"`
+	Token                 *string                  `mapstructure:"token" cty:"token" hcl:"token"`
+	OTP                   *string                  `mapstructure:"otp" cty:"otp" hcl:"otp"`
+	TOTPSecret            *string                  `mapstructure:"totp_secret" cty:"totp_secret" hcl:"totp_secret"`
+	TaskTimeout           *string                  `mapstructure:"task_timeout" cty:"task_timeout" hcl:"task_timeout"`
+	TransferTimeout       *string                  `mapstructure:"transfer_timeout" cty:"transfer_timeout" hcl:"transfer_timeout"`
+	APIRetry              *proxmox.FlatRetryConfig `mapstructure:"api_retry" cty:"api_retry" hcl:"api_retry"`
+	NodeSSHHost           *string                  `mapstructure:"node_ssh_host" cty:"node_ssh_host" hcl:"node_ssh_host"`
+	NodeSSHPort           *int                     `mapstructure:"node_ssh_port" cty:"node_ssh_port" hcl:"node_ssh_port"`
+	NodeSSHUsername       *string                  `mapstructure:"node_ssh_username" cty:"node_ssh_username" hcl:"node_ssh_username"`
+	NodeSSHPassword       *string                  `mapstructure:"node_ssh_password" cty:"node_ssh_password" hcl:"node_ssh_password"`
+	NodeSSHPrivateKeyFile *string                  `mapstructure:"node_ssh_private_key_file" cty:"node_ssh_private_key_file" hcl:"node_ssh_private_key_file"`
+	NodeSSHHostKey        *string                  `mapstructure:"node_ssh_host_key" cty:"node_ssh_host_key" hcl:"node_ssh_host_key"`
+	NodeSSHKnownHostsFile *string                  `mapstructure:"node_ssh_known_hosts_file" cty:"node_ssh_known_hosts_file" hcl:"node_ssh_known_hosts_file"`
+	BackupStoragePool     *string                  `mapstructure:"backup_storage_pool" required:"true" cty:"backup_storage_pool" hcl:"backup_storage_pool"`
+}
+
+// FlatMapstructure returns a new FlatsourceClusterConfig.
+// FlatsourceClusterConfig is an auto-generated flat version of sourceClusterConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*sourceClusterConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatsourceClusterConfig)
+}
+
+// HCL2Spec returns the hcl spec of a sourceClusterConfig.
+// This spec is used by HCL to read the fields of sourceClusterConfig.
+// The decoded values from this spec will then be applied to a FlatsourceClusterConfig.
+func (*FlatsourceClusterConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"proxmox_url":               &hcldec.AttrSpec{Name: "proxmox_url", Type: cty.String, Required: false},
+		"profile":                   &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file":          &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
+		"insecure_skip_tls_verify":  &hcldec.AttrSpec{Name: "insecure_skip_tls_verify", Type: cty.Bool, Required: false},
+		"ca_file":                   &hcldec.AttrSpec{Name: "ca_file", Type: cty.String, Required: false},
+		"ca_pem":                    &hcldec.AttrSpec{Name: "ca_pem", Type: cty.String, Required: false},
+		"tls_fingerprint":           &hcldec.AttrSpec{Name: "tls_fingerprint", Type: cty.String, Required: false},
+		"client_cert_file":          &hcldec.AttrSpec{Name: "client_cert_file", Type: cty.String, Required: false},
+		"client_key_file":           &hcldec.AttrSpec{Name: "client_key_file", Type: cty.String, Required: false},
+		"api_proxy_url":             &hcldec.AttrSpec{Name: "api_proxy_url", Type: cty.String, Required: false},
+		"api_extra_headers":         &hcldec.AttrSpec{Name: "api_extra_headers", Type: cty.Map(cty.String), Required: false},
+		"username":                  &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":                  &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"token":                     &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"otp":                       &hcldec.AttrSpec{Name: "otp", Type: cty.String, Required: false},
+		"totp_secret":               &hcldec.AttrSpec{Name: "totp_secret", Type: cty.String, Required: false},
+		"task_timeout":              &hcldec.AttrSpec{Name: "task_timeout", Type: cty.String, Required: false},
+		"transfer_timeout":          &hcldec.AttrSpec{Name: "transfer_timeout", Type: cty.String, Required: false},
+		"api_retry":                 &hcldec.BlockSpec{TypeName: "api_retry", Nested: hcldec.ObjectSpec((*proxmox.FlatRetryConfig)(nil).HCL2Spec())},
+		"node_ssh_host":             &hcldec.AttrSpec{Name: "node_ssh_host", Type: cty.String, Required: false},
+		"node_ssh_port":             &hcldec.AttrSpec{Name: "node_ssh_port", Type: cty.Number, Required: false},
+		"node_ssh_username":         &hcldec.AttrSpec{Name: "node_ssh_username", Type: cty.String, Required: false},
+		"node_ssh_password":         &hcldec.AttrSpec{Name: "node_ssh_password", Type: cty.String, Required: false},
+		"node_ssh_private_key_file": &hcldec.AttrSpec{Name: "node_ssh_private_key_file", Type: cty.String, Required: false},
+		"node_ssh_host_key":         &hcldec.AttrSpec{Name: "node_ssh_host_key", Type: cty.String, Required: false},
+		"node_ssh_known_hosts_file": &hcldec.AttrSpec{Name: "node_ssh_known_hosts_file", Type: cty.String, Required: false},
+		"backup_storage_pool":       &hcldec.AttrSpec{Name: "backup_storage_pool", Type: cty.String, Required: false},
+	}
+	return s
+}
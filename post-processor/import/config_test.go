@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":         "https://prod-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"token":               "xxxx-xxxx-xxxx-xxxx",
+		"node":                "pve2",
+		"node_ssh_password":   "secret",
+		"backup_storage_pool": "local",
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	t.Setenv("PROXMOX_URL", "")
+	t.Setenv("PROXMOX_USERNAME", "")
+	t.Setenv("PROXMOX_PASSWORD", "")
+	t.Setenv("PROXMOX_TOKEN", "")
+
+	var c Config
+	err := c.Prepare(make(map[string]interface{}))
+	if err == nil {
+		t.Fatal("Expected empty configuration to fail")
+	}
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatal("Expected errors to be packersdk.MultiError")
+	}
+
+	required := []string{"username", "token", "proxmox_url", "node", "node_ssh_password", "backup_storage_pool"}
+	for _, param := range required {
+		found := false
+		for _, err := range errs.Errors {
+			if strings.Contains(err.Error(), param) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error about missing parameters %q", param)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name:          "target settings only, no error",
+			overrides:     map[string]interface{}{},
+			expectFailure: false,
+		},
+		{
+			name:          "vm_id in range, no error",
+			overrides:     map[string]interface{}{"vm_id": 9000},
+			expectFailure: false,
+		},
+		{
+			name:          "vm_id out of range, error",
+			overrides:     map[string]interface{}{"vm_id": 42},
+			expectFailure: true,
+		},
+		{
+			name:          "negative restore_bwlimit, error",
+			overrides:     map[string]interface{}{"restore_bwlimit": -1},
+			expectFailure: true,
+		},
+		{
+			name: "complete source_cluster, no error",
+			overrides: map[string]interface{}{
+				"source_cluster": map[string]interface{}{
+					"proxmox_url":         "https://dev-proxmox.my-domain:8006/api2/json",
+					"username":            "apiuser@pve",
+					"token":               "yyyy-yyyy-yyyy-yyyy",
+					"node_ssh_password":   "secret",
+					"backup_storage_pool": "local",
+				},
+			},
+			expectFailure: false,
+		},
+		{
+			name: "source_cluster without credentials, error",
+			overrides: map[string]interface{}{
+				"source_cluster": map[string]interface{}{
+					"proxmox_url":         "https://dev-proxmox.my-domain:8006/api2/json",
+					"backup_storage_pool": "local",
+				},
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROXMOX_USERNAME", "")
+			t.Setenv("PROXMOX_PASSWORD", "")
+			t.Setenv("PROXMOX_TOKEN", "")
+
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			err := c.Prepare(cfg)
+			if tt.expectFailure && err == nil {
+				t.Error("Expected config to fail, but no errors were returned")
+			}
+			if !tt.expectFailure && err != nil {
+				t.Errorf("Expected config to succeed, but got %s", err)
+			}
+		})
+	}
+}
+
+func TestSourceClusterDefaults(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["source_cluster"] = map[string]interface{}{
+		"proxmox_url":         "https://dev-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"token":               "yyyy-yyyy-yyyy-yyyy",
+		"node_ssh_password":   "secret",
+		"backup_storage_pool": "local",
+	}
+
+	var c Config
+	if err := c.Prepare(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeSSHHost != "prod-proxmox.my-domain" {
+		t.Errorf("Expected node_ssh_host to default to the proxmox_url host, got %q", c.NodeSSHHost)
+	}
+	if c.SourceCluster.NodeSSHHost != "dev-proxmox.my-domain" {
+		t.Errorf("Expected source_cluster node_ssh_host to default to its proxmox_url host, got %q", c.SourceCluster.NodeSSHHost)
+	}
+	if c.SourceCluster.TaskTimeout == 0 {
+		t.Error("Expected source_cluster task_timeout to have a default")
+	}
+}
+
+func TestSourceClusterIgnoresEnvironment(t *testing.T) {
+	t.Setenv("PROXMOX_USERNAME", "target@pve")
+	t.Setenv("PROXMOX_TOKEN", "xxxx-xxxx-xxxx-xxxx")
+
+	cfg := mandatoryConfig(t)
+	cfg["source_cluster"] = map[string]interface{}{
+		"proxmox_url":         "https://dev-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"password":            "supersecret",
+		"node_ssh_password":   "secret",
+		"backup_storage_pool": "local",
+	}
+
+	var c Config
+	if err := c.Prepare(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.SourceCluster.Token != "" {
+		t.Errorf("Expected source_cluster to keep password auth, got token %q", c.SourceCluster.Token)
+	}
+
+	delete(cfg["source_cluster"].(map[string]interface{}), "username")
+	c = Config{}
+	if err := c.Prepare(cfg); err == nil || !strings.Contains(err.Error(), "source_cluster: username must be specified") {
+		t.Errorf("Expected source_cluster username to be required, got %v", err)
+	}
+}
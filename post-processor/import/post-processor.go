@@ -0,0 +1,247 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-proxmox/post-processor/vzdump"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type PostProcessor struct {
+	config Config
+}
+
+// PostProcessor implements packersdk.PostProcessor
+var _ packersdk.PostProcessor = &PostProcessor{}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	return p.config.Prepare(raws...)
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	archive := ""
+	if artifact.BuilderId() == vzdump.BuilderId {
+		archive = artifact.Files()[0]
+	} else {
+		src := p.config.SourceCluster
+		if src.BackupStoragePool == "" {
+			return nil, false, false, fmt.Errorf("importing an artifact of type %q requires the source_cluster block, or a proxmox-vzdump artifact", artifact.BuilderId())
+		}
+
+		dir, err := os.MkdirTemp("", "packer-proxmox-import")
+		if err != nil {
+			return nil, false, false, err
+		}
+		defer os.RemoveAll(dir)
+
+		exported, err := vzdump.Export(ctx, ui, &vzdump.Config{
+			PackerConfig:      p.config.PackerConfig,
+			ClientConfig:      src.ClientConfig,
+			NodeSSHConfig:     src.NodeSSHConfig,
+			BackupStoragePool: src.BackupStoragePool,
+			Compress:          "zstd",
+			OutputDirectory:   dir,
+			ChecksumType:      "sha256",
+		}, artifact)
+		if err != nil {
+			return nil, false, false, err
+		}
+		archive = exported.Path
+	}
+
+	client, err := p.config.NewClient(p.config.PackerDebug)
+	if err != nil {
+		return nil, false, false, err
+	}
+	comm, err := p.config.NodeSSHConfig.Connect()
+	if err != nil {
+		return nil, false, false, fmt.Errorf("error connecting to %s over SSH: %s", p.config.NodeSSHHost, err)
+	}
+
+	a, err := importBackup(ctx, ui, client, comm, &p.config, archive)
+	if err != nil {
+		return nil, false, false, err
+	}
+	return a, true, false, nil
+}
+
+type backupImporter interface {
+	proxmox.TaskClient
+	GetStorageConfig(id string) (config map[string]interface{}, err error)
+	DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+	GetNextID(currentID int) (nextID int, err error)
+	SetVmConfig(vmr *proxmoxapi.VmRef, params map[string]interface{}) (exitStatus interface{}, err error)
+	SetLxcConfig(vmr *proxmoxapi.VmRef, vmParams map[string]interface{}) (exitStatus interface{}, err error)
+	CreateTemplate(vmr *proxmoxapi.VmRef) error
+	DeleteVm(vmr *proxmoxapi.VmRef) (exitStatus string, err error)
+}
+
+var _ backupImporter = &proxmox.Client{}
+
+// importBackup uploads the archive to the backup storage of the target
+// cluster over SSH, restores it and converts the result to a template.
+func importBackup(ctx context.Context, ui packersdk.Ui, client backupImporter, comm packersdk.Communicator, c *Config, archive string) (*Artifact, error) {
+	name := filepath.Base(archive)
+	match := backupArchiveRe.FindStringSubmatch(name)
+	if match == nil {
+		return nil, fmt.Errorf("%s is not a vzdump archive, named like vzdump-qemu-<vmid>-<date>.vma.zst", archive)
+	}
+	vmType := match[1]
+
+	storage, err := client.GetStorageConfig(c.BackupStoragePool)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching configuration of storage %s: %s", c.BackupStoragePool, err)
+	}
+	dumpDir, err := proxmox.BackupDir(c.BackupStoragePool, storage)
+	if err != nil {
+		return nil, err
+	}
+	volume := fmt.Sprintf("%s:backup/%s", c.BackupStoragePool, name)
+	dst := path.Join(dumpDir, name)
+
+	// Never overwrite, nor later remove, an archive that is already there
+	cmd := &packersdk.RemoteCmd{Command: "test -e " + proxmox.ShellQuote(dst)}
+	if err := cmd.RunWithUi(ctx, comm, ui); err == nil && cmd.ExitStatus() == 0 {
+		ui.Say(fmt.Sprintf("Backup archive %s already present, skipping upload", volume))
+	} else {
+		if err := upload(comm, archive, dst); err != nil {
+			return nil, fmt.Errorf("error uploading backup archive: %s", err)
+		}
+		ui.Message(fmt.Sprintf("Uploaded backup archive to %s", volume))
+
+		if !c.KeepBackup {
+			defer func() {
+				// Fake a VM reference, DeleteVolume just needs the node to be valid
+				nodeRef := &proxmoxapi.VmRef{}
+				nodeRef.SetNode(c.Node)
+				nodeRef.SetVmType("qemu")
+				if _, err := client.DeleteVolume(nodeRef, c.BackupStoragePool, volume); err != nil {
+					ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+					return
+				}
+				ui.Message(fmt.Sprintf("Deleted uploaded backup archive %s", volume))
+			}()
+		}
+	}
+
+	vmid := c.VMID
+	if vmid == 0 {
+		vmid, err = client.GetNextID(0)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the next free VMID: %s", err)
+		}
+	}
+	vmRef := proxmoxapi.NewVmRef(vmid)
+	vmRef.SetNode(c.Node)
+	vmRef.SetVmType(vmType)
+
+	ui.Say(fmt.Sprintf("Restoring %s as template %d on node %s", volume, vmid, c.Node))
+	if err := restore(ctx, ui, client, vmRef, c, volume); err != nil {
+		return nil, err
+	}
+
+	err = configure(client, vmRef, c)
+	if err == nil {
+		err = client.CreateTemplate(vmRef)
+	}
+	if err != nil {
+		if _, derr := client.DeleteVm(vmRef); derr != nil {
+			ui.Error(fmt.Sprintf("error deleting restored %s %d: %s", vmType, vmid, derr))
+		}
+		return nil, fmt.Errorf("error converting %s %d to template: %s", vmType, vmid, err)
+	}
+
+	return &Artifact{
+		templateID:    vmid,
+		node:          c.Node,
+		proxmoxClient: client,
+		StateData:     map[string]interface{}{},
+	}, nil
+}
+
+// restore restores the archive as the VM or container vmRef.
+func restore(ctx context.Context, ui packersdk.Ui, client backupImporter, vmRef *proxmoxapi.VmRef, c *Config, volume string) error {
+	params := map[string]interface{}{
+		"vmid": vmRef.VmId(),
+		// Generate new MAC addresses, the backed up guest is still around
+		"unique": 1,
+	}
+	if c.RestoreStoragePool != "" {
+		params["storage"] = c.RestoreStoragePool
+	}
+	if c.RestoreBandwidthLimit > 0 {
+		params["bwlimit"] = c.RestoreBandwidthLimit
+	}
+	if c.Pool != "" {
+		params["pool"] = c.Pool
+	}
+
+	log.Printf("restoring %s as %s %d", volume, vmRef.GetVmType(), vmRef.VmId())
+	if vmRef.GetVmType() == "lxc" {
+		params["ostemplate"] = volume
+		params["restore"] = 1
+	} else {
+		params["archive"] = volume
+	}
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+	if _, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/%s", vmRef.Node(), vmRef.GetVmType())); err != nil {
+		return fmt.Errorf("error restoring backup %s: %s", volume, err)
+	}
+	return nil
+}
+
+// configure sets the name and description of the restored guest, when they
+// are set in the configuration.
+func configure(client backupImporter, vmRef *proxmoxapi.VmRef, c *Config) error {
+	changes := map[string]interface{}{}
+	if c.TemplateDescription != "" {
+		changes["description"] = c.TemplateDescription
+	}
+	if vmRef.GetVmType() == "lxc" {
+		if c.TemplateName != "" {
+			changes["hostname"] = c.TemplateName
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		_, err := client.SetLxcConfig(vmRef, changes)
+		return err
+	}
+
+	if c.TemplateName != "" {
+		changes["name"] = c.TemplateName
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	_, err := client.SetVmConfig(vmRef, changes)
+	return err
+}
+
+// upload copies the local file src to dst on the node.
+func upload(comm packersdk.Communicator, src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	fi, err := r.Stat()
+	if err != nil {
+		return err
+	}
+	log.Printf("uploading %s to %s", src, dst)
+	return comm.Upload(dst, r, &fi)
+}
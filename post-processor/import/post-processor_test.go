@@ -0,0 +1,239 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type backupImporterMock struct {
+	templateErr error
+
+	createdType   string
+	createParams  map[string]interface{}
+	configChanges map[string]interface{}
+	template      *proxmoxapi.VmRef
+	deletedVolume string
+	deletedVM     int
+}
+
+func (m *backupImporterMock) GetStorageConfig(id string) (map[string]interface{}, error) {
+	return map[string]interface{}{"path": "/mnt/pve/backups", "content": "backup"}, nil
+}
+
+func (m *backupImporterMock) DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (interface{}, error) {
+	m.deletedVolume = volumeName
+	return nil, nil
+}
+
+func (m *backupImporterMock) GetNextID(currentID int) (int, error) {
+	return 142, nil
+}
+
+func (m *backupImporterMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	m.createdType = strings.TrimPrefix(url, "/nodes/pve2/")
+	m.createParams = params
+	return `{"data":"UPID:pve2:00001234:00005678:65A0B1C2:qmrestore:100:root@pam:"}`, nil
+}
+
+func (m *backupImporterMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": "OK"}}, nil
+}
+
+func (m *backupImporterMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (m *backupImporterMock) Delete(url string) error {
+	return nil
+}
+
+func (m *backupImporterMock) SetVmConfig(vmr *proxmoxapi.VmRef, params map[string]interface{}) (interface{}, error) {
+	m.configChanges = params
+	return nil, nil
+}
+
+func (m *backupImporterMock) SetLxcConfig(vmr *proxmoxapi.VmRef, params map[string]interface{}) (interface{}, error) {
+	m.configChanges = params
+	return nil, nil
+}
+
+func (m *backupImporterMock) CreateTemplate(vmr *proxmoxapi.VmRef) error {
+	m.template = vmr
+	return m.templateErr
+}
+
+func (m *backupImporterMock) DeleteVm(vmr *proxmoxapi.VmRef) (string, error) {
+	m.deletedVM = vmr.VmId()
+	return "OK", nil
+}
+
+func TestImportBackup(t *testing.T) {
+	tests := []struct {
+		name              string
+		archive           string
+		config            Config
+		archiveExitStatus int
+		templateErr       error
+
+		expectError       bool
+		expectUpload      bool
+		expectedType      string
+		expectedParams    map[string]interface{}
+		expectedChanges   map[string]interface{}
+		expectedVMID      int
+		expectedDeleted   string
+		expectedDeletedVM int
+	}{
+		{
+			name:    "virtual machine archive uploaded, restored and converted",
+			archive: "vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+			config: Config{
+				VMID:                9000,
+				TemplateName:        "debian-12",
+				TemplateDescription: "imported",
+				Pool:                "templates",
+				RestoreStoragePool:  "local-lvm",
+			},
+			archiveExitStatus: 1,
+			expectUpload:      true,
+			expectedType:      "qemu",
+			expectedParams: map[string]interface{}{
+				"vmid":    9000,
+				"unique":  1,
+				"archive": "backups:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+				"pool":    "templates",
+				"storage": "local-lvm",
+			},
+			expectedChanges: map[string]interface{}{"name": "debian-12", "description": "imported"},
+			expectedVMID:    9000,
+			expectedDeleted: "backups:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+		},
+		{
+			name:              "container archive restored with the next free VMID, archive kept",
+			archive:           "vzdump-lxc-101-2024_05_01-12_00_00.tar.zst",
+			config:            Config{TemplateName: "alpine", KeepBackup: true},
+			archiveExitStatus: 1,
+			expectUpload:      true,
+			expectedType:      "lxc",
+			expectedParams: map[string]interface{}{
+				"vmid":       142,
+				"unique":     1,
+				"ostemplate": "backups:backup/vzdump-lxc-101-2024_05_01-12_00_00.tar.zst",
+				"restore":    1,
+			},
+			expectedChanges: map[string]interface{}{"hostname": "alpine"},
+			expectedVMID:    142,
+		},
+		{
+			name:              "archive already present, not uploaded nor removed",
+			archive:           "vzdump-qemu-100-2024_05_01-12_00_00.vma",
+			archiveExitStatus: 0,
+			expectUpload:      false,
+			expectedType:      "qemu",
+			expectedParams: map[string]interface{}{
+				"vmid":    142,
+				"unique":  1,
+				"archive": "backups:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma",
+			},
+			expectedVMID: 142,
+		},
+		{
+			name:              "template conversion fails, restored VM removed",
+			archive:           "vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+			config:            Config{VMID: 9000},
+			archiveExitStatus: 1,
+			templateErr:       errors.New("locked"),
+			expectError:       true,
+			expectedDeleted:   "backups:backup/vzdump-qemu-100-2024_05_01-12_00_00.vma.zst",
+			expectedDeletedVM: 9000,
+		},
+		{
+			name:        "not a vzdump archive, error",
+			archive:     "debian-12.qcow2",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), tt.archive)
+			if err := os.WriteFile(archive, []byte("archive"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			c := tt.config
+			c.Node = "pve2"
+			c.BackupStoragePool = "backups"
+			client := &backupImporterMock{templateErr: tt.templateErr}
+			comm := &packersdk.MockCommunicator{StartExitStatus: tt.archiveExitStatus}
+
+			a, err := importBackup(context.TODO(), packersdk.TestUi(t), client, comm, &c, archive)
+			if client.deletedVolume != tt.expectedDeleted {
+				t.Errorf("Expected %q to be deleted, got %q", tt.expectedDeleted, client.deletedVolume)
+			}
+			if client.deletedVM != tt.expectedDeletedVM {
+				t.Errorf("Expected VM %d to be deleted, got %d", tt.expectedDeletedVM, client.deletedVM)
+			}
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected import to fail, but no errors were returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected import to succeed, but got %s", err)
+			}
+
+			if comm.UploadCalled != tt.expectUpload {
+				t.Errorf("Expected upload to be called: %t, got %t", tt.expectUpload, comm.UploadCalled)
+			}
+			if tt.expectUpload && comm.UploadPath != "/mnt/pve/backups/dump/"+tt.archive {
+				t.Errorf("Expected upload to the dump directory, got %s", comm.UploadPath)
+			}
+			if client.createdType != tt.expectedType {
+				t.Errorf("Expected a %s to be restored, got %q", tt.expectedType, client.createdType)
+			}
+			if len(client.createParams) != len(tt.expectedParams) {
+				t.Errorf("Expected restore parameters %v, got %v", tt.expectedParams, client.createParams)
+			}
+			for k, v := range tt.expectedParams {
+				if client.createParams[k] != v {
+					t.Errorf("Expected restore parameter %s to be %v, got %v", k, v, client.createParams[k])
+				}
+			}
+			if len(client.configChanges) != len(tt.expectedChanges) {
+				t.Errorf("Expected config changes %v, got %v", tt.expectedChanges, client.configChanges)
+			}
+			for k, v := range tt.expectedChanges {
+				if client.configChanges[k] != v {
+					t.Errorf("Expected %s to be set to %v, got %v", k, v, client.configChanges[k])
+				}
+			}
+			if client.template == nil || client.template.VmId() != tt.expectedVMID || client.template.GetVmType() != tt.expectedType {
+				t.Errorf("Expected %s %d to be converted to a template, got %v", tt.expectedType, tt.expectedVMID, client.template)
+			}
+			if a.templateID != tt.expectedVMID || a.node != "pve2" {
+				t.Errorf("Unexpected artifact %s", a)
+			}
+		})
+	}
+}
+
+func TestPostProcessRequiresSourceCluster(t *testing.T) {
+	p := &PostProcessor{}
+	artifact := &packersdk.MockArtifact{BuilderIdValue: "proxmox.iso", IdValue: "100"}
+	_, _, _, err := p.PostProcess(context.Background(), packersdk.TestUi(t), artifact)
+	if err == nil {
+		t.Error("Expected importing a builder artifact without source_cluster to fail")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vzdump
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id of the artifacts of the post-processor
+const BuilderId = "proxmox.post-processor.vzdump"
+
+// Artifact is a vzdump archive downloaded from Proxmox.
+type Artifact struct {
+	// Local path of the archive
+	Path string
+	// Local path of the file holding the checksum of the archive
+	ChecksumPath string
+	ChecksumType string
+	Checksum     string
+	// Volume of the backup on the Proxmox storage, when it was kept
+	Volume string
+
+	// StateData should store data such as GeneratedData
+	// to be shared with post-processors
+	StateData map[string]interface{}
+}
+
+// Artifact implements packersdk.Artifact
+var _ packersdk.Artifact = &Artifact{}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return []string{a.Path, a.ChecksumPath}
+}
+
+func (a *Artifact) Id() string {
+	return filepath.Base(a.Path)
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("A backup archive was downloaded: %s (%s:%s)", a.Path, a.ChecksumType, a.Checksum)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return a.StateData[name]
+}
+
+func (a *Artifact) Destroy() error {
+	log.Printf("Deleting backup archive: %s", a.Path)
+	for _, f := range a.Files() {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
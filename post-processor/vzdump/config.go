@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package vzdump
+
+import (
+	"errors"
+	"fmt"
+
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type Config struct {
+	common.PackerConfig   `mapstructure:",squash"`
+	proxmox.ClientConfig  `mapstructure:",squash"`
+	proxmox.NodeSSHConfig `mapstructure:",squash"`
+
+	// Proxmox storage pool the backup is written to before it is downloaded.
+	// Must be a directory based storage allowing the `backup` content type,
+	// reachable from `node_ssh_host`.
+	BackupStoragePool string `mapstructure:"backup_storage_pool" required:"true"`
+	// Compression of the archive: `zstd`, `gzip`, `lzo` or `none`.
+	// Defaults to `zstd`.
+	Compress string `mapstructure:"compress"`
+	// Limit the I/O bandwidth of the backup, in KiB/s.
+	// Defaults to the limit configured for the cluster.
+	BandwidthLimit int `mapstructure:"bwlimit"`
+	// Local directory the archive is downloaded to. It is created if it does
+	// not exist. Defaults to `output-<build name>`.
+	OutputDirectory string `mapstructure:"output_directory"`
+	// Algorithm of the checksum written next to the archive: `md5`, `sha1`,
+	// `sha256` or `sha512`. Defaults to `sha256`.
+	ChecksumType string `mapstructure:"checksum_type"`
+	// Keep the backup on `backup_storage_pool` once it is downloaded.
+	// Defaults to `false`, removing it.
+	KeepBackup bool `mapstructure:"keep_backup"`
+
+	ctx interpolate.Context
+}
+
+// Values of the compress parameter of vzdump, by compress option
+var compressions = map[string]string{
+	"zstd": "zstd",
+	"gzip": "gzip",
+	"lzo":  "lzo",
+	"none": "0",
+}
+
+func (c *Config) Prepare(raws ...interface{}) error {
+	err := config.Decode(c, &config.DecodeOpts{
+		PluginType:         "proxmox-vzdump",
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	errs = packersdk.MultiErrorAppend(errs, c.ClientConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.NodeSSHConfig.Prepare(c.ProxmoxURLRaw)...)
+
+	if c.BackupStoragePool == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("backup_storage_pool must be specified"))
+	}
+	if c.Compress == "" {
+		c.Compress = "zstd"
+	}
+	if _, ok := compressions[c.Compress]; !ok {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("compress must be one of zstd, gzip, lzo or none, got %q", c.Compress))
+	}
+	if c.BandwidthLimit < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("bwlimit must not be negative"))
+	}
+	if c.OutputDirectory == "" {
+		c.OutputDirectory = fmt.Sprintf("output-%s", c.PackerBuildName)
+	}
+	if c.ChecksumType == "" {
+		c.ChecksumType = "sha256"
+	}
+	if _, err := newHash(c.ChecksumType); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vzdump
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":         "https://my-proxmox.my-domain:8006/api2/json",
+		"username":            "apiuser@pve",
+		"token":               "xxxx-xxxx-xxxx-xxxx",
+		"node_ssh_password":   "secret",
+		"backup_storage_pool": "local",
+		"packer_build_name":   "debian",
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	t.Setenv("PROXMOX_URL", "")
+	t.Setenv("PROXMOX_USERNAME", "")
+	t.Setenv("PROXMOX_PASSWORD", "")
+	t.Setenv("PROXMOX_TOKEN", "")
+
+	var c Config
+	err := c.Prepare(make(map[string]interface{}))
+	if err == nil {
+		t.Fatal("Expected empty configuration to fail")
+	}
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatal("Expected errors to be packersdk.MultiError")
+	}
+
+	required := []string{"username", "token", "proxmox_url", "node_ssh_password", "backup_storage_pool"}
+	for _, param := range required {
+		found := false
+		for _, err := range errs.Errors {
+			if strings.Contains(err.Error(), param) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error about missing parameters %q", param)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var c Config
+	if err := c.Prepare(mandatoryConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Compress != "zstd" {
+		t.Errorf("Expected compress to default to zstd, got %q", c.Compress)
+	}
+	if c.ChecksumType != "sha256" {
+		t.Errorf("Expected checksum_type to default to sha256, got %q", c.ChecksumType)
+	}
+	if c.OutputDirectory != "output-debian" {
+		t.Errorf("Expected output_directory to default to output-debian, got %q", c.OutputDirectory)
+	}
+	if c.NodeSSHHost != "my-proxmox.my-domain" {
+		t.Errorf("Expected node_ssh_host to default to the proxmox_url host, got %q", c.NodeSSHHost)
+	}
+	if c.KeepBackup {
+		t.Error("Expected keep_backup to default to false")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name:          "gzip compression, no error",
+			overrides:     map[string]interface{}{"compress": "gzip"},
+			expectFailure: false,
+		},
+		{
+			name:          "no compression, no error",
+			overrides:     map[string]interface{}{"compress": "none"},
+			expectFailure: false,
+		},
+		{
+			name:          "unknown compression, error",
+			overrides:     map[string]interface{}{"compress": "bzip2"},
+			expectFailure: true,
+		},
+		{
+			name:          "sha512 checksum, no error",
+			overrides:     map[string]interface{}{"checksum_type": "sha512"},
+			expectFailure: false,
+		},
+		{
+			name:          "unknown checksum type, error",
+			overrides:     map[string]interface{}{"checksum_type": "crc32"},
+			expectFailure: true,
+		},
+		{
+			name:          "negative bwlimit, error",
+			overrides:     map[string]interface{}{"bwlimit": -1},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.overrides {
+				cfg[k] = v
+			}
+
+			var c Config
+			err := c.Prepare(cfg)
+			if tt.expectFailure && err == nil {
+				t.Error("Expected config to fail, but no errors were returned")
+			}
+			if !tt.expectFailure && err != nil {
+				t.Errorf("Expected config to succeed, but got %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vzdump
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmoxclone "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/clone"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	proxmoximport "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/import"
+	proxmoxiso "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/iso"
+	proxmoxlxc "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/lxc"
+	proxmoxova "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/ova"
+	proxmoxrestore "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/restore"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The builders whose artifacts can be backed up
+var builderIDs = []string{
+	proxmoxclone.BuilderID,
+	proxmoximport.BuilderID,
+	proxmoxiso.BuilderID,
+	proxmoxlxc.BuilderID,
+	proxmoxova.BuilderID,
+	proxmoxrestore.BuilderID,
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+// PostProcessor implements packersdk.PostProcessor
+var _ packersdk.PostProcessor = &PostProcessor{}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	return p.config.Prepare(raws...)
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	a, err := Export(ctx, ui, &p.config, artifact)
+	if err != nil {
+		return nil, false, false, err
+	}
+	// The template is left in place, unless keep_input_artifact says otherwise
+	return a, true, false, nil
+}
+
+// Export backs up the template of a proxmox builder artifact and downloads
+// the archive, as configured by c. Cancelling ctx stops the backup.
+func Export(ctx context.Context, ui packersdk.Ui, c *Config, artifact packersdk.Artifact) (*Artifact, error) {
+	supported := false
+	for _, id := range builderIDs {
+		if artifact.BuilderId() == id {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported artifact type %q: only artifacts of the proxmox builders can be backed up", artifact.BuilderId())
+	}
+	vmid, err := strconv.Atoi(artifact.Id())
+	if err != nil {
+		return nil, fmt.Errorf("artifact ID %q is not a VMID: %s", artifact.Id(), err)
+	}
+
+	client, err := c.NewClient(c.PackerDebug)
+	if err != nil {
+		return nil, err
+	}
+	comm, err := c.NodeSSHConfig.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s over SSH: %s", c.NodeSSHHost, err)
+	}
+
+	return exportBackup(ctx, ui, client, comm, c, vmid)
+}
+
+type backupExporter interface {
+	proxmox.TaskClient
+	CheckVmRef(vmr *proxmoxapi.VmRef) (err error)
+	GetStorageContent(vmr *proxmoxapi.VmRef, storageName string) (data map[string]interface{}, err error)
+	GetStorageConfig(id string) (config map[string]interface{}, err error)
+	DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+}
+
+var _ backupExporter = &proxmox.Client{}
+
+// exportBackup backs up the virtual machine or container to the backup
+// storage with vzdump, then downloads the archive over SSH while computing
+// its checksum.
+func exportBackup(ctx context.Context, ui packersdk.Ui, client backupExporter, comm packersdk.Communicator, c *Config, vmid int) (*Artifact, error) {
+	vmRef := proxmoxapi.NewVmRef(vmid)
+	if err := client.CheckVmRef(vmRef); err != nil {
+		return nil, fmt.Errorf("error looking up VM %d: %s", vmid, err)
+	}
+
+	storage, err := client.GetStorageConfig(c.BackupStoragePool)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching configuration of storage %s: %s", c.BackupStoragePool, err)
+	}
+	dumpDir, err := proxmox.BackupDir(c.BackupStoragePool, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := listBackups(client, vmRef, c.BackupStoragePool)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{
+		"vmid":     vmid,
+		"storage":  c.BackupStoragePool,
+		"compress": compressions[c.Compress],
+		// Never prune older backups of the VM
+		"remove": 0,
+	}
+	if c.BandwidthLimit > 0 {
+		params["bwlimit"] = c.BandwidthLimit
+	}
+	ui.Say(fmt.Sprintf("Backing up VM %d to %s", vmid, c.BackupStoragePool))
+	tracker := &proxmox.TaskTracker{Client: client, Ui: ui, Timeout: c.TransferTimeout, Retry: c.APIRetry}
+	if _, err := tracker.Run(ctx, params, fmt.Sprintf("/nodes/%s/vzdump", vmRef.Node())); err != nil {
+		return nil, fmt.Errorf("error backing up VM %d: %s", vmid, err)
+	}
+
+	backups, err := listBackups(client, vmRef, c.BackupStoragePool)
+	if err != nil {
+		return nil, err
+	}
+	volume := ""
+	for volid, ctime := range backups {
+		if _, ok := existing[volid]; ok {
+			continue
+		}
+		if volume == "" || ctime > backups[volume] {
+			volume = volid
+		}
+	}
+	if volume == "" {
+		return nil, fmt.Errorf("backup of VM %d not found on storage %s", vmid, c.BackupStoragePool)
+	}
+	ui.Message(fmt.Sprintf("Created backup %s", volume))
+
+	if !c.KeepBackup {
+		defer func() {
+			// Fake a VM reference, DeleteVolume just needs the node to be valid
+			nodeRef := &proxmoxapi.VmRef{}
+			nodeRef.SetNode(vmRef.Node())
+			nodeRef.SetVmType("qemu")
+			if _, err := client.DeleteVolume(nodeRef, c.BackupStoragePool, volume); err != nil {
+				ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+				return
+			}
+			ui.Message(fmt.Sprintf("Deleted backup %s", volume))
+		}()
+	}
+
+	name := path.Base(volume)
+	if err := os.MkdirAll(c.OutputDirectory, 0755); err != nil {
+		return nil, err
+	}
+	archivePath := filepath.Join(c.OutputDirectory, name)
+
+	ui.Say(fmt.Sprintf("Downloading backup to %s", archivePath))
+	sum, err := download(comm, path.Join(dumpDir, name), archivePath, c.ChecksumType)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading backup %s: %s", volume, err)
+	}
+
+	checksumPath := archivePath + "." + c.ChecksumType
+	err = os.WriteFile(checksumPath, []byte(fmt.Sprintf("%s  %s\n", sum, name)), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Artifact{
+		Path:         archivePath,
+		ChecksumPath: checksumPath,
+		ChecksumType: c.ChecksumType,
+		Checksum:     sum,
+		StateData: map[string]interface{}{
+			"checksum": c.ChecksumType + ":" + sum,
+		},
+	}
+	if c.KeepBackup {
+		a.Volume = volume
+	}
+	return a, nil
+}
+
+// listBackups returns the creation time of the backups of the VM on the
+// storage, by volume.
+func listBackups(client backupExporter, vmRef *proxmoxapi.VmRef, storage string) (map[string]float64, error) {
+	content, err := client.GetStorageContent(vmRef, storage)
+	if err != nil {
+		return nil, fmt.Errorf("error listing content of storage %s: %s", storage, err)
+	}
+	items, _ := content["data"].([]interface{})
+
+	backups := map[string]float64{}
+	for _, item := range items {
+		volume, _ := item.(map[string]interface{})
+		if volume["content"] != "backup" {
+			continue
+		}
+		if id, _ := volume["vmid"].(float64); int(id) != vmRef.VmId() {
+			continue
+		}
+		volid, _ := volume["volid"].(string)
+		ctime, _ := volume["ctime"].(float64)
+		backups[volid] = ctime
+	}
+	return backups, nil
+}
+
+// download copies the file at src on the node to dst, and returns its
+// checksum. A partially downloaded file is removed.
+func download(comm packersdk.Communicator, src, dst, checksumType string) (string, error) {
+	h, err := newHash(checksumType)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("downloading %s to %s", src, dst)
+	err = comm.Download(src, io.MultiWriter(f, h))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func newHash(checksumType string) (hash.Hash, error) {
+	switch checksumType {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("checksum_type must be one of md5, sha1, sha256 or sha512, got %q", checksumType)
+}
@@ -0,0 +1,193 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vzdump
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const testUPID = "UPID:pve1:00001234:00005678:65A0B1C2:vzdump:100:root@pam:"
+
+type backupExporterMock struct {
+	exitStatus    string
+	existing      []interface{}
+	created       []interface{}
+	dumped        bool
+	dumpParams    map[string]interface{}
+	deletedVolume string
+}
+
+func (m *backupExporterMock) CheckVmRef(vmr *proxmoxapi.VmRef) error {
+	vmr.SetNode("pve1")
+	vmr.SetVmType("qemu")
+	return nil
+}
+
+func (m *backupExporterMock) CreateItemReturnStatus(params map[string]interface{}, url string) (string, error) {
+	if url != "/nodes/pve1/vzdump" {
+		return "", fmt.Errorf("unexpected POST %s", url)
+	}
+	m.dumped = true
+	m.dumpParams = params
+	return fmt.Sprintf(`{"data":%q}`, testUPID), nil
+}
+
+func (m *backupExporterMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": map[string]interface{}{"status": "stopped", "exitstatus": m.exitStatus}}, nil
+}
+
+func (m *backupExporterMock) GetItemListInterfaceArray(url string) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (m *backupExporterMock) Delete(url string) error {
+	return nil
+}
+
+func (m *backupExporterMock) GetStorageContent(vmr *proxmoxapi.VmRef, storageName string) (map[string]interface{}, error) {
+	if m.dumped {
+		return map[string]interface{}{"data": append(append([]interface{}{}, m.existing...), m.created...)}, nil
+	}
+	return map[string]interface{}{"data": m.existing}, nil
+}
+
+func (m *backupExporterMock) GetStorageConfig(id string) (map[string]interface{}, error) {
+	return map[string]interface{}{"path": "/var/lib/vz", "content": "iso,backup"}, nil
+}
+
+func (m *backupExporterMock) DeleteVolume(vmr *proxmoxapi.VmRef, storageName string, volumeName string) (interface{}, error) {
+	m.deletedVolume = volumeName
+	return nil, nil
+}
+
+func backupVolume(vmid int, name string, ctime int) map[string]interface{} {
+	return map[string]interface{}{
+		"volid":   "local:backup/" + name,
+		"content": "backup",
+		"vmid":    float64(vmid),
+		"ctime":   float64(ctime),
+	}
+}
+
+func TestExportBackup(t *testing.T) {
+	older := backupVolume(100, "vzdump-qemu-100-2024_05_01-12_00_00.vma.zst", 1714564800)
+	other := backupVolume(101, "vzdump-qemu-101-2024_05_02-12_00_00.vma.zst", 1714651200)
+	created := backupVolume(100, "vzdump-qemu-100-2024_05_02-12_00_00.vma.zst", 1714651200)
+
+	tests := []struct {
+		name            string
+		keepBackup      bool
+		exitStatus      string
+		created         []interface{}
+		expectError     bool
+		expectedVolume  string
+		expectedDeleted string
+	}{
+		{
+			name:            "backup downloaded and removed",
+			exitStatus:      "OK",
+			created:         []interface{}{created},
+			expectedVolume:  "",
+			expectedDeleted: "local:backup/vzdump-qemu-100-2024_05_02-12_00_00.vma.zst",
+		},
+		{
+			name:           "backup downloaded and kept",
+			keepBackup:     true,
+			exitStatus:     "OK",
+			created:        []interface{}{created},
+			expectedVolume: "local:backup/vzdump-qemu-100-2024_05_02-12_00_00.vma.zst",
+		},
+		{
+			name:        "failed backup task, error",
+			exitStatus:  "job errors",
+			created:     []interface{}{created},
+			expectError: true,
+		},
+		{
+			name:        "no new backup of the VM, error",
+			exitStatus:  "OK",
+			created:     []interface{}{other},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &backupExporterMock{
+				exitStatus: tt.exitStatus,
+				existing:   []interface{}{older},
+				created:    tt.created,
+			}
+			comm := &packersdk.MockCommunicator{DownloadData: "archive"}
+			c := &Config{
+				BackupStoragePool: "local",
+				Compress:          "none",
+				OutputDirectory:   filepath.Join(t.TempDir(), "output"),
+				ChecksumType:      "sha256",
+				KeepBackup:        tt.keepBackup,
+			}
+
+			a, err := exportBackup(context.TODO(), packersdk.TestUi(t), client, comm, c, 100)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected export to fail, but no errors were returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected export to succeed, but got %s", err)
+			}
+
+			if client.dumpParams["vmid"] != 100 || client.dumpParams["compress"] != "0" || client.dumpParams["remove"] != 0 {
+				t.Errorf("Unexpected vzdump parameters %v", client.dumpParams)
+			}
+			if comm.DownloadPath != "/var/lib/vz/dump/vzdump-qemu-100-2024_05_02-12_00_00.vma.zst" {
+				t.Errorf("Expected the new backup to be downloaded, got %s", comm.DownloadPath)
+			}
+			if client.deletedVolume != tt.expectedDeleted {
+				t.Errorf("Expected deleted volume %q, got %q", tt.expectedDeleted, client.deletedVolume)
+			}
+			if a.Volume != tt.expectedVolume {
+				t.Errorf("Expected artifact volume %q, got %q", tt.expectedVolume, a.Volume)
+			}
+
+			data, err := os.ReadFile(a.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "archive" {
+				t.Errorf("Expected downloaded archive content %q, got %q", "archive", data)
+			}
+			sum := fmt.Sprintf("%x", sha256.Sum256([]byte("archive")))
+			if a.Checksum != sum {
+				t.Errorf("Expected checksum %s, got %s", sum, a.Checksum)
+			}
+			checksumFile, err := os.ReadFile(a.ChecksumPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(checksumFile), sum+"  vzdump-qemu-100-") {
+				t.Errorf("Unexpected checksum file %q", checksumFile)
+			}
+		})
+	}
+}
+
+func TestPostProcessUnsupportedArtifact(t *testing.T) {
+	p := &PostProcessor{}
+	artifact := &packersdk.MockArtifact{BuilderIdValue: "packer.file", IdValue: "100"}
+	_, _, _, err := p.PostProcess(context.Background(), packersdk.TestUi(t), artifact)
+	if err == nil || !strings.Contains(err.Error(), "unsupported artifact") {
+		t.Errorf("Expected an unsupported artifact error, got %v", err)
+	}
+}